@@ -1,7 +1,21 @@
 package main
 
-import "github.com/livelace/gosquito/pkg/gosquito"
+import (
+	"github.com/livelace/gosquito/pkg/gosquito"
+	"os"
+)
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			gosquito.RunReplay(os.Args[2:])
+			return
+		case "test":
+			gosquito.RunTest(os.Args[2:])
+			return
+		}
+	}
+
 	gosquito.RunApp()
 }
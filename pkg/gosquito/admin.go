@@ -0,0 +1,38 @@
+package gosquito
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+)
+
+// Administrative endpoints (replay etc.) are disabled until admin_token is set.
+var adminToken string
+
+// adminAllowed checks "Authorization: Bearer <admin_token>" header and writes error response if request isn't allowed.
+func adminAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.Error(w, core.ERROR_ADMIN_DISABLED.Error(), http.StatusForbidden)
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		http.Error(w, core.ERROR_ADMIN_TOKEN_INVALID.Error(), http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// adminHandler protects administrative endpoint with admin_token.
+func adminHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminAllowed(w, r) {
+			handler(w, r)
+		}
+	}
+}
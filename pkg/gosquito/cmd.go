@@ -1,6 +1,7 @@
 package gosquito
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
@@ -65,6 +66,22 @@ var (
 		[]string{"flow", "hash", "input_plugin", "input_values", "process_plugins", "output_plugin", "output_values"},
 	)
 
+	flowMetricSourceFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gosquito_flow_source_failures",
+			Help: "How many consecutive failures input source has.",
+		},
+		[]string{"flow", "hash", "source"},
+	)
+
+	flowMetricSourceQuarantine = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gosquito_flow_source_quarantine",
+			Help: "Is input source quarantined (1) or not (0).",
+		},
+		[]string{"flow", "hash", "source"},
+	)
+
 	flowMetricTime = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "gosquito_flow_time",
@@ -81,6 +98,8 @@ func init() {
 	prometheus.MustRegister(flowMetricReceive)
 	prometheus.MustRegister(flowMetricRun)
 	prometheus.MustRegister(flowMetricSend)
+	prometheus.MustRegister(flowMetricSourceFailures)
+	prometheus.MustRegister(flowMetricSourceQuarantine)
 	prometheus.MustRegister(flowMetricTime)
 
 	log.SetFormatter(&log.TextFormatter{
@@ -108,10 +127,18 @@ func RunApp() {
 	ll, _ := log.ParseLevel(appConfig.GetString(core.VIPER_DEFAULT_LOG_LEVEL))
 	log.SetLevel(ll)
 
+	// Administrative API.
+	if v, b := core.IsString(appConfig.GetString(core.VIPER_DEFAULT_ADMIN_TOKEN)); b {
+		adminToken = v
+	}
+
 	// Prometheus' metrics.
 	go func() {
 		http.Handle("/", promhttp.Handler())
 		http.Handle("/metrics", promhttp.Handler())
+		http.HandleFunc("/replay", adminHandler(replayHandler))
+		http.HandleFunc("/sources", adminHandler(sourcesHandler))
+		http.HandleFunc("/tap", adminHandler(tapHandler))
 		err := http.ListenAndServe(appConfig.GetString(core.VIPER_DEFAULT_EXPORTER_LISTEN), nil)
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -132,6 +159,51 @@ func RunApp() {
 		os.Exit(1)
 	}
 
+	// Coordination between instances.
+	coordinator, err := core.NewCoordinator(appConfig)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"backend": appConfig.GetString(core.VIPER_DEFAULT_COORDINATION),
+			"error":   err,
+		}).Error(core.ERROR_COORDINATION)
+		os.Exit(1)
+	}
+
+	if coordinator != nil {
+		log.WithFields(log.Fields{
+			"backend": appConfig.GetString(core.VIPER_DEFAULT_COORDINATION),
+			"id":      coordinator.ID,
+		}).Info(core.LOG_COORDINATION)
+
+		// Running flow instances mustn't send data after lease loss (other instance might run flow already).
+		coordinator.OnRevoke = func(name string) {
+			for _, flow := range flows {
+				if flow.FlowName == name {
+					flow.RevokeLease()
+				}
+			}
+		}
+
+		go coordinator.Run(context.Background())
+	}
+
+	// Flow might be run only if it is leased by this instance.
+	flowLeased := func(flow *core.Flow) bool {
+		if coordinator == nil {
+			return true
+		}
+
+		ok, err := coordinator.Acquire(flow.FlowName, flow.GetInstance() > 0)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"flow":  flow.FlowName,
+				"error": err,
+			}).Error(core.ERROR_COORDINATION)
+		}
+
+		return ok
+	}
+
 	// Main loop.
 	flowLimit := appConfig.GetInt(core.VIPER_DEFAULT_FLOW_LIMIT)
 	flowCounter := make(map[uuid.UUID]int64, len(flows))
@@ -192,6 +264,19 @@ func RunApp() {
 				flowMetricTime.With(labels).Set(float64(flow.MetricTime))
 
 				flow.ResetMetric()
+
+				// Update sources health metrics.
+				for source, v := range flow.GetHealth().Snapshot() {
+					sourceLabels := prometheus.Labels{"flow": flow.FlowName, "hash": flow.FlowHash, "source": source}
+
+					quarantined := 0.0
+					if v.Quarantined {
+						quarantined = 1.0
+					}
+
+					flowMetricSourceFailures.With(sourceLabels).Set(float64(v.Failures))
+					flowMetricSourceQuarantine.With(sourceLabels).Set(quarantined)
+				}
 			}
 
 			// Find flow candidates and save their execution counters.
@@ -201,7 +286,10 @@ func RunApp() {
 			//		flowCandidates[flow] = flowCounter[flow.FlowUUID]
 			//	}
 			//}
-			if currentTime.Unix()-lastTime.Unix() > flow.FlowInterval/1000 {
+			// Flow might be triggered by other flows (flow chaining) before its interval.
+			flowTriggered := flow.IsTriggered()
+
+			if currentTime.Unix()-lastTime.Unix() > flow.FlowInterval/1000 || flowTriggered {
 				flowCandidates[flow] = flowCounter[flow.FlowUUID]
 			}
 		}
@@ -214,6 +302,10 @@ func RunApp() {
 				for flow := range flowCandidates {
 					flowTimestamp[flow.FlowUUID] = currentTime
 
+					if !flowLeased(flow) {
+						continue
+					}
+
 					for i := flow.GetInstance(); i < flow.FlowInstance; i++ {
 						go runFlow(flow)
 						time.Sleep(1 * time.Millisecond)
@@ -246,6 +338,11 @@ func RunApp() {
 
 					if candidate.Flow.GetInstance() < candidate.Flow.FlowInstance {
 						flowTimestamp[candidate.Flow.FlowUUID] = currentTime
+
+						if !flowLeased(candidate.Flow) {
+							continue
+						}
+
 						flowRunning += 1
 
 						go runFlow(candidate.Flow)
@@ -254,6 +351,10 @@ func RunApp() {
 			}
 		} else {
 			if flowRunning == 0 {
+				if coordinator != nil {
+					_ = coordinator.Close()
+				}
+
 				log.Info("quit!")
 				os.Exit(0)
 			}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/renameio"
+)
+
+// Archive keeps input data of flow runs for replaying.
+// Every run is kept as a dedicated compressed JSONL file: <timestamp>.jsonl.gz
+type Archive struct {
+	Dir       string
+	Retention time.Duration
+}
+
+type archiveFile struct {
+	Name string
+	Time time.Time
+}
+
+func (a *Archive) files() ([]archiveFile, error) {
+	temp := make([]archiveFile, 0)
+
+	files, err := os.ReadDir(a.Dir)
+	if os.IsNotExist(err) {
+		return temp, nil
+	} else if err != nil {
+		return temp, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), DEFAULT_ARCHIVE_EXT) {
+			continue
+		}
+
+		var timestamp int64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(f.Name(), DEFAULT_ARCHIVE_EXT), "%d", &timestamp); err != nil {
+			continue
+		}
+
+		temp = append(temp, archiveFile{Name: filepath.Join(a.Dir, f.Name()), Time: time.Unix(0, timestamp).UTC()})
+	}
+
+	sort.Slice(temp, func(i, j int) bool {
+		return temp[i].Time.Before(temp[j].Time)
+	})
+
+	return temp, nil
+}
+
+// Read returns archived data of runs within time range (zero time means no limit).
+func (a *Archive) Read(from time.Time, to time.Time) ([]*Datum, error) {
+	temp := make([]*Datum, 0)
+
+	files, err := a.files()
+	if err != nil {
+		return temp, err
+	}
+
+	for _, f := range files {
+		if (!from.IsZero() && f.Time.Before(from)) || (!to.IsZero() && f.Time.After(to)) {
+			continue
+		}
+
+		data, err := readArchiveFile(f.Name)
+		if err != nil {
+			return temp, fmt.Errorf(ERROR_ARCHIVE_READ.Error(), f.Name, err)
+		}
+
+		temp = append(temp, data...)
+	}
+
+	return temp, nil
+}
+
+// Write saves run data and removes runs older than retention.
+func (a *Archive) Write(data []*Datum) error {
+	if err := CreateDirIfNotExist(a.Dir); err != nil {
+		return err
+	}
+
+	t, err := renameio.TempFile("", filepath.Join(a.Dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), DEFAULT_ARCHIVE_EXT)))
+	if err != nil {
+		return err
+	}
+	defer t.Cleanup()
+
+	w := gzip.NewWriter(t)
+	e := json.NewEncoder(w)
+
+	for _, item := range data {
+		if err := e.Encode(item); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := t.CloseAtomicallyReplace(); err != nil {
+		return err
+	}
+
+	// Retention.
+	if a.Retention > 0 {
+		files, err := a.files()
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if time.Since(f.Time) > a.Retention {
+				if err := os.Remove(f.Name); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func readArchiveFile(name string) ([]*Datum, error) {
+	temp := make([]*Datum, 0)
+
+	f, err := os.Open(name)
+	if err != nil {
+		return temp, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return temp, err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var item Datum
+
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return temp, err
+		}
+
+		temp = append(temp, &item)
+	}
+
+	return temp, scanner.Err()
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/renameio"
+	log "github.com/livelace/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	flowRegistry     = make(map[string]*Flow)
+	flowRegistryLock sync.RWMutex
+)
+
+// Queue is a durable local queue of datum batches between flows.
+// Every batch is kept as a dedicated file: <timestamp>_<source flow>.json
+// Batches are claimed by reader, so concurrent flow instances don't receive the same batches.
+type Queue struct {
+	Dir string
+
+	m       sync.Mutex
+	claimed map[string]bool
+}
+
+type QueueBatch struct {
+	File   string
+	Source string
+	Time   time.Time
+	Data   []*Datum
+}
+
+// Read claims and returns all unclaimed batches from provided sources ordered by time.
+// Batches stay in queue until they are removed explicitly, claims are dropped with Release/Remove.
+func (q *Queue) Read(sources []string) ([]*QueueBatch, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	temp := make([]*QueueBatch, 0)
+
+	files, err := os.ReadDir(q.Dir)
+	if os.IsNotExist(err) {
+		return temp, nil
+	} else if err != nil {
+		return temp, err
+	}
+
+	names := make([]string, 0)
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := strings.SplitN(strings.TrimSuffix(name, ".json"), "_", 2)
+		if len(s) != 2 || !IsValueInSlice(s[1], &sources) || q.claimed[name] {
+			continue
+		}
+
+		var timestamp int64
+		if _, err := fmt.Sscanf(s[0], "%d", &timestamp); err != nil {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(q.Dir, name))
+		if err != nil {
+			return temp, err
+		}
+
+		batch := QueueBatch{
+			File:   filepath.Join(q.Dir, name),
+			Source: s[1],
+			Time:   time.Unix(0, timestamp).UTC(),
+			Data:   make([]*Datum, 0),
+		}
+
+		// Corrupted batch is moved aside, otherwise it blocks the queue forever.
+		if err := json.Unmarshal(content, &batch.Data); err != nil {
+			log.WithFields(log.Fields{
+				"file":  batch.File,
+				"error": fmt.Errorf(ERROR_QUEUE_READ.Error(), name, err),
+			}).Error(LOG_FLOW_WARN)
+
+			if err := os.Rename(batch.File, batch.File+".bad"); err != nil {
+				return temp, err
+			}
+
+			continue
+		}
+
+		temp = append(temp, &batch)
+	}
+
+	if q.claimed == nil {
+		q.claimed = make(map[string]bool)
+	}
+
+	for _, batch := range temp {
+		q.claimed[filepath.Base(batch.File)] = true
+	}
+
+	return temp, nil
+}
+
+// Release drops batch claim, batch will be read again.
+func (q *Queue) Release(batch *QueueBatch) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	delete(q.claimed, filepath.Base(batch.File))
+}
+
+// Remove deletes batch from queue.
+func (q *Queue) Remove(batch *QueueBatch) error {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if err := os.Remove(batch.File); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	delete(q.claimed, filepath.Base(batch.File))
+
+	return nil
+}
+
+func (q *Queue) Write(source string, data []*Datum) error {
+	if err := CreateDirIfNotExist(q.Dir); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(q.Dir, fmt.Sprintf("%020d_%s.json", time.Now().UnixNano(), source))
+
+	return renameio.WriteFile(file, content, 0644)
+}
+
+// GetFlowByName returns loaded flow (nil if flow doesn't exist or disabled).
+func GetFlowByName(name string) *Flow {
+	flowRegistryLock.RLock()
+	defer flowRegistryLock.RUnlock()
+
+	return flowRegistry[name]
+}
+
+func GetFlowQueue(config *viper.Viper, flow string) *Queue {
+	return &Queue{Dir: filepath.Join(config.GetString(VIPER_DEFAULT_FLOW_DATA), flow, DEFAULT_QUEUE_DIR)}
+}
+
+func RegisterFlow(flow *Flow) {
+	flowRegistryLock.Lock()
+	defer flowRegistryLock.Unlock()
+
+	flowRegistry[flow.FlowName] = flow
+}
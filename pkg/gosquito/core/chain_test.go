@@ -0,0 +1,120 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	q := &Queue{Dir: t.TempDir()}
+
+	if err := q.Write("flow1", []*Datum{{FLOW: "flow1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Write("flow2", []*Datum{{FLOW: "flow2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := q.Read([]string{"flow1"})
+	if err != nil || len(batches) != 1 || batches[0].Source != "flow1" || batches[0].Data[0].FLOW != "flow1" {
+		t.Fatalf(`Read() = %v, %v`, batches, err)
+	}
+
+	// Claimed batch isn't read again.
+	if v, err := q.Read([]string{"flow1"}); err != nil || len(v) != 0 {
+		t.Errorf(`Read(claimed) = %v, %v`, v, err)
+	}
+
+	// Released batch is read again.
+	q.Release(batches[0])
+
+	if v, err := q.Read([]string{"flow1"}); err != nil || len(v) != 1 {
+		t.Errorf(`Read(released) = %v, %v`, v, err)
+	}
+
+	// Removed batch is gone.
+	if err := q.Remove(batches[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(batches[0].File); !os.IsNotExist(err) {
+		t.Errorf(`Remove() = %v`, err)
+	}
+
+	if v, err := q.Read([]string{"flow1"}); err != nil || len(v) != 0 {
+		t.Errorf(`Read(removed) = %v, %v`, v, err)
+	}
+}
+
+func TestQueueConcurrent(t *testing.T) {
+	q := &Queue{Dir: t.TempDir()}
+
+	for i := 0; i < 100; i++ {
+		if err := q.Write("flow1", []*Datum{{FLOW: "flow1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	claimed := make(map[string]int)
+
+	// Every batch is claimed by a single reader.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			batches, err := q.Read([]string{"flow1"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			m.Lock()
+			for _, batch := range batches {
+				claimed[batch.File] += 1
+			}
+			m.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(claimed) != 100 {
+		t.Errorf(`Read() claimed batches = %d`, len(claimed))
+	}
+
+	for file, n := range claimed {
+		if n != 1 {
+			t.Errorf(`Read() batch %s claimed %d times`, file, n)
+		}
+	}
+}
+
+func TestQueueCorrupted(t *testing.T) {
+	q := &Queue{Dir: t.TempDir()}
+
+	bad := filepath.Join(q.Dir, "00000000000000000001_flow1.json")
+	if err := os.WriteFile(bad, []byte("[{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Write("flow1", []*Datum{{FLOW: "flow1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupted batch is moved aside, the rest of batches are read.
+	batches, err := q.Read([]string{"flow1"})
+	if err != nil || len(batches) != 1 {
+		t.Fatalf(`Read() = %v, %v`, batches, err)
+	}
+
+	if _, err := os.Stat(bad + ".bad"); err != nil {
+		t.Errorf(`Read() corrupted batch isn't moved: %v`, err)
+	}
+}
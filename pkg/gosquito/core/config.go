@@ -47,6 +47,46 @@ func initAppConfig() (string, error) {
 	return userDir, nil
 }
 
+func setAppConfigDefaults(v *viper.Viper, configPath string) {
+	v.SetDefault(VIPER_DEFAULT_ADMIN_TOKEN, "")
+	v.SetDefault(VIPER_DEFAULT_COORDINATION, DEFAULT_COORDINATION)
+	v.SetDefault(VIPER_DEFAULT_COORDINATION_ID, "")
+	v.SetDefault(VIPER_DEFAULT_COORDINATION_TTL, DEFAULT_COORDINATION_TTL)
+	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION, make([]string, 0))
+	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION_DELAY, DEFAULT_EXPIRE_ACTION_DELAY)
+	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT, DEFAULT_EXPIRE_ACTION_TIMEOUT)
+	v.SetDefault(VIPER_DEFAULT_EXPIRE_INTERVAL, DEFAULT_EXPIRE_INTERVAL)
+	v.SetDefault(VIPER_DEFAULT_EXPORTER_LISTEN, DEFAULT_EXPORTER_LISTEN)
+	v.SetDefault(VIPER_DEFAULT_FLOW_ARCHIVE, DEFAULT_FLOW_ARCHIVE)
+	v.SetDefault(VIPER_DEFAULT_FLOW_ARCHIVE_RETENTION, DEFAULT_FLOW_ARCHIVE_RETENTION)
+	v.SetDefault(VIPER_DEFAULT_FLOW_CLEANUP, DEFAULT_FLOW_CLEANUP)
+	v.SetDefault(VIPER_DEFAULT_FLOW_CONF, filepath.Join(configPath, DEFAULT_FLOW_CONF_DIR))
+	v.SetDefault(VIPER_DEFAULT_FLOW_DATA, filepath.Join(configPath, DEFAULT_FLOW_DATA_DIR))
+	v.SetDefault(VIPER_DEFAULT_FLOW_ENABLE, make([]string, 0))
+	v.SetDefault(VIPER_DEFAULT_FLOW_INSTANCE, DEFAULT_FLOW_INSTANCE)
+	v.SetDefault(VIPER_DEFAULT_FLOW_INTERVAL, DEFAULT_FLOW_INTERVAL)
+	v.SetDefault(VIPER_DEFAULT_FLOW_LIMIT, DEFAULT_FLOW_LIMIT)
+	v.SetDefault(VIPER_DEFAULT_FLOW_SOURCE_BACKOFF, DEFAULT_FLOW_SOURCE_BACKOFF)
+	v.SetDefault(VIPER_DEFAULT_FLOW_SOURCE_BACKOFF_MAX, DEFAULT_FLOW_SOURCE_BACKOFF_MAX)
+	v.SetDefault(VIPER_DEFAULT_FLOW_SOURCE_HEALTH, DEFAULT_FLOW_SOURCE_HEALTH)
+	v.SetDefault(VIPER_DEFAULT_FLOW_SOURCE_PROBE, DEFAULT_FLOW_SOURCE_PROBE)
+	v.SetDefault(VIPER_DEFAULT_FLOW_SOURCE_QUARANTINE, DEFAULT_FLOW_SOURCE_QUARANTINE)
+	v.SetDefault(VIPER_DEFAULT_LOG_LEVEL, DEFAULT_LOG_LEVEL)
+	v.SetDefault(VIPER_DEFAULT_LOOP_SLEEP, DEFAULT_LOOP_SLEEP)
+	v.SetDefault(VIPER_DEFAULT_PLUGIN_INCLUDE, DEFAULT_PLUGIN_INCLUDE)
+	v.SetDefault(VIPER_DEFAULT_PLUGIN_TIMEOUT, DEFAULT_PLUGIN_TIMEOUT)
+	v.SetDefault(VIPER_DEFAULT_PROC_NUM, runtime.GOMAXPROCS(0))
+	v.SetDefault(VIPER_DEFAULT_RATE_BURST, DEFAULT_RATE_BURST)
+	v.SetDefault(VIPER_DEFAULT_RATE_LIMIT, DEFAULT_RATE_LIMIT)
+	v.SetDefault(VIPER_DEFAULT_STATE_BACKEND, DEFAULT_STATE_BACKEND)
+	v.SetDefault(VIPER_DEFAULT_STATE_REDIS_ADDR, DEFAULT_STATE_REDIS_ADDR)
+	v.SetDefault(VIPER_DEFAULT_STATE_REDIS_DB, DEFAULT_STATE_REDIS_DB)
+	v.SetDefault(VIPER_DEFAULT_STATE_REDIS_PASSWORD, "")
+	v.SetDefault(VIPER_DEFAULT_TIME_FORMAT, DEFAULT_TIME_FORMAT)
+	v.SetDefault(VIPER_DEFAULT_TIME_ZONE, DEFAULT_TIME_ZONE)
+	v.SetDefault(VIPER_DEFAULT_USER_AGENT, DEFAULT_USER_AGENT)
+}
+
 func GetAppConfig() *viper.Viper {
 	configPath, err := initAppConfig()
 
@@ -79,26 +119,7 @@ func GetAppConfig() *viper.Viper {
 	}
 
 	// Set defaults.
-	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION, make([]string, 0))
-	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION_DELAY, DEFAULT_EXPIRE_ACTION_DELAY)
-	v.SetDefault(VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT, DEFAULT_EXPIRE_ACTION_TIMEOUT)
-	v.SetDefault(VIPER_DEFAULT_EXPIRE_INTERVAL, DEFAULT_EXPIRE_INTERVAL)
-	v.SetDefault(VIPER_DEFAULT_EXPORTER_LISTEN, DEFAULT_EXPORTER_LISTEN)
-	v.SetDefault(VIPER_DEFAULT_FLOW_CLEANUP, DEFAULT_FLOW_CLEANUP)
-	v.SetDefault(VIPER_DEFAULT_FLOW_CONF, filepath.Join(configPath, DEFAULT_FLOW_CONF_DIR))
-	v.SetDefault(VIPER_DEFAULT_FLOW_DATA, filepath.Join(configPath, DEFAULT_FLOW_DATA_DIR))
-	v.SetDefault(VIPER_DEFAULT_FLOW_ENABLE, make([]string, 0))
-	v.SetDefault(VIPER_DEFAULT_FLOW_INSTANCE, DEFAULT_FLOW_INSTANCE)
-	v.SetDefault(VIPER_DEFAULT_FLOW_INTERVAL, DEFAULT_FLOW_INTERVAL)
-	v.SetDefault(VIPER_DEFAULT_FLOW_LIMIT, DEFAULT_FLOW_LIMIT)
-	v.SetDefault(VIPER_DEFAULT_LOG_LEVEL, DEFAULT_LOG_LEVEL)
-	v.SetDefault(VIPER_DEFAULT_LOOP_SLEEP, DEFAULT_LOOP_SLEEP)
-	v.SetDefault(VIPER_DEFAULT_PLUGIN_INCLUDE, DEFAULT_PLUGIN_INCLUDE)
-	v.SetDefault(VIPER_DEFAULT_PLUGIN_TIMEOUT, DEFAULT_PLUGIN_TIMEOUT)
-	v.SetDefault(VIPER_DEFAULT_PROC_NUM, runtime.GOMAXPROCS(0))
-	v.SetDefault(VIPER_DEFAULT_TIME_FORMAT, DEFAULT_TIME_FORMAT)
-	v.SetDefault(VIPER_DEFAULT_TIME_ZONE, DEFAULT_TIME_ZONE)
-	v.SetDefault(VIPER_DEFAULT_USER_AGENT, DEFAULT_USER_AGENT)
+	setAppConfigDefaults(v, configPath)
 
 	// Directories must exist for proper work.
 	workDirs := []string{
@@ -116,5 +137,35 @@ func GetAppConfig() *viper.Viper {
 		}
 	}
 
+	// State backend must be available for proper work.
+	if err := SetStateBackend(v); err != nil {
+		log.WithFields(log.Fields{
+			"backend": v.GetString(VIPER_DEFAULT_STATE_BACKEND),
+			"error":   err,
+		}).Error(LOG_CONFIG_ERROR)
+		os.Exit(1)
+	}
+
 	return v
 }
+
+// NewAppConfig reads configuration file (if provided) and sets defaults without side effects (tests etc.).
+func NewAppConfig(configFile string) (*viper.Viper, error) {
+	v := viper.New()
+	configPath := DEFAULT_CURRENT_PATH
+
+	if configFile != "" {
+		configPath = filepath.Dir(configFile)
+
+		v.SetConfigFile(configFile)
+		v.SetConfigType("toml")
+
+		if err := v.ReadInConfig(); err != nil {
+			return v, err
+		}
+	}
+
+	setAppConfigDefaults(v, configPath)
+
+	return v, nil
+}
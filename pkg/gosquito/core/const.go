@@ -9,36 +9,64 @@ var (
 const (
 	// -----------------------------------------------------------------------------------------------------------------
 
-	DEFAULT_CURRENT_PATH          = "."
-	DEFAULT_DATA_DIR              = "data"
-	DEFAULT_ETC_PATH              = "/etc/gosquito"
-	DEFAULT_EXPIRE_ACTION_DELAY   = "1d"
-	DEFAULT_EXPIRE_ACTION_TIMEOUT = 30
-	DEFAULT_EXPIRE_INTERVAL       = "7d"
-	DEFAULT_EXPORTER_LISTEN       = ":8080"
-	DEFAULT_FLOW_CLEANUP          = true
-	DEFAULT_FLOW_CONF_DIR         = "conf"
-	DEFAULT_FLOW_DATA_DIR         = "data"
-	DEFAULT_FLOW_INSTANCE         = 1
-	DEFAULT_FLOW_INTERVAL         = "5m"
-	DEFAULT_FLOW_LIMIT            = 0
-	DEFAULT_FORCE_INPUT           = false
-	DEFAULT_FORCE_COUNT           = 100
-	DEFAULT_LOG_LEVEL             = "INFO"
-	DEFAULT_LOG_TIME_FORMAT       = "02.01.2006 15:04:05.000"
-	DEFAULT_LOOP_SLEEP            = 1000
-	DEFAULT_PLUGIN_INCLUDE        = false
-	DEFAULT_PLUGIN_TIMEOUT        = 60
-	DEFAULT_STATE_DIR             = "state"
-	DEFAULT_TEMP_DIR              = "temp"
-	DEFAULT_TIME_FORMAT           = "15:04:05 02.01.2006"
-	DEFAULT_TIME_ZONE             = "UTC"
-	DEFAULT_UNIQUE_SEPARATOR      = "= == === ==== ====="
+	DEFAULT_ARCHIVE_DIR             = "archive"
+	DEFAULT_ARCHIVE_EXT             = ".jsonl.gz"
+	DEFAULT_COORDINATION            = "none"
+	DEFAULT_COORDINATION_DIR        = ".coordination"
+	DEFAULT_COORDINATION_TTL        = "30s"
+	DEFAULT_CRED_CACHE_TTL          = "5m"
+	DEFAULT_CURRENT_PATH            = "."
+	DEFAULT_DATA_DIR                = "data"
+	DEFAULT_ETC_PATH                = "/etc/gosquito"
+	DEFAULT_EXPIRE_ACTION_DELAY     = "1d"
+	DEFAULT_EXPIRE_ACTION_TIMEOUT   = 30
+	DEFAULT_EXPIRE_INTERVAL         = "7d"
+	DEFAULT_EXPORTER_LISTEN         = ":8080"
+	DEFAULT_FLOW_ARCHIVE            = false
+	DEFAULT_FLOW_ARCHIVE_RETENTION  = "7d"
+	DEFAULT_FLOW_CLEANUP            = true
+	DEFAULT_FLOW_CONF_DIR           = "conf"
+	DEFAULT_FLOW_DATA_DIR           = "data"
+	DEFAULT_FLOW_INSTANCE           = 1
+	DEFAULT_FLOW_INTERVAL           = "5m"
+	DEFAULT_FLOW_LIMIT              = 0
+	DEFAULT_FLOW_SOURCE_BACKOFF     = "1m"
+	DEFAULT_FLOW_SOURCE_BACKOFF_MAX = "1h"
+	DEFAULT_FLOW_SOURCE_HEALTH      = true
+	DEFAULT_FLOW_SOURCE_PROBE       = "6h"
+	DEFAULT_FLOW_SOURCE_QUARANTINE  = 10
+	DEFAULT_FORCE_INPUT             = false
+	DEFAULT_FORCE_COUNT             = 100
+	DEFAULT_HEALTH_DIR              = "health"
+	DEFAULT_LOG_LEVEL               = "INFO"
+	DEFAULT_LOG_TIME_FORMAT         = "02.01.2006 15:04:05.000"
+	DEFAULT_LOOP_SLEEP              = 1000
+	DEFAULT_PLUGIN_INCLUDE          = false
+	DEFAULT_PLUGIN_TIMEOUT          = 60
+	DEFAULT_QUEUE_DIR               = "queue"
+	DEFAULT_RATE_BURST              = 1
+	DEFAULT_RATE_LIMIT              = 0
+	DEFAULT_STATE_BACKEND           = "badger"
+	DEFAULT_STATE_DIR               = "state"
+	DEFAULT_STATE_REDIS_ADDR        = "127.0.0.1:6379"
+	DEFAULT_STATE_REDIS_DB          = 0
+	DEFAULT_STATE_SQLITE_FILE       = "state.sqlite"
+	DEFAULT_TAP_BUFFER              = 1000
+	DEFAULT_TAP_KEEPALIVE           = "15s"
+	DEFAULT_TEMP_DIR                = "temp"
+	DEFAULT_TIME_FORMAT             = "15:04:05 02.01.2006"
+	DEFAULT_TIME_ZONE               = "UTC"
+	DEFAULT_UNIQUE_SEPARATOR        = "= == === ==== ====="
+	DEFAULT_VAULT_K8S_MOUNT         = "kubernetes"
+	DEFAULT_VAULT_K8S_TOKEN         = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DEFAULT_VAULT_KV_VERSION        = 1
 
 	// -----------------------------------------------------------------------------------------------------------------
 
 	LOG_CONFIG_APPLY               = "config apply"
 	LOG_CONFIG_ERROR               = "config error"
+	LOG_COORDINATION               = "coordination enabled"
+	LOG_FLOW_ARCHIVE               = "flow archive"
 	LOG_FLOW_CLEANUP               = "flow cleanup"
 	LOG_FLOW_IGNORE                = "flow ignore"
 	LOG_FLOW_INVALID               = "flow invalid"
@@ -48,10 +76,12 @@ const (
 	LOG_FLOW_PROCESS               = "process data ..."
 	LOG_FLOW_READ                  = "flow read"
 	LOG_FLOW_RECEIVE               = "receive data ..."
+	LOG_FLOW_REPLAY                = "replay data ..."
 	LOG_FLOW_SEND                  = "send data ..."
 	LOG_FLOW_START                 = "--- flow start"
 	LOG_FLOW_STAT                  = "flow stat"
 	LOG_FLOW_STOP                  = "--- flow stop"
+	LOG_FLOW_TEST                  = "flow test"
 	LOG_FLOW_VALID                 = "flow valid"
 	LOG_FLOW_WARN                  = "flow warn"
 	LOG_PLUGIN_DATA                = "plugin data"
@@ -60,27 +90,44 @@ const (
 
 	// -----------------------------------------------------------------------------------------------------------------
 
-	VIPER_DEFAULT_EXPIRE_ACTION         = "default.expire_action"
-	VIPER_DEFAULT_EXPIRE_ACTION_DELAY   = "default.expire_action_delay"
-	VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT = "default.expire_action_timeout"
-	VIPER_DEFAULT_EXPIRE_INTERVAL       = "default.expire_interval"
-	VIPER_DEFAULT_EXPORTER_LISTEN       = "default.exporter_listen"
-	VIPER_DEFAULT_FLOW_CLEANUP          = "default.flow_cleanup"
-	VIPER_DEFAULT_FLOW_CONF             = "default.flow_conf"
-	VIPER_DEFAULT_FLOW_DATA             = "default.flow_data"
-	VIPER_DEFAULT_FLOW_DISABLE          = "default.flow_disable"
-	VIPER_DEFAULT_FLOW_ENABLE           = "default.flow_enable"
-	VIPER_DEFAULT_FLOW_INSTANCE         = "default.flow_instance"
-	VIPER_DEFAULT_FLOW_INTERVAL         = "default.flow_interval"
-	VIPER_DEFAULT_FLOW_LIMIT            = "default.flow_limit"
-	VIPER_DEFAULT_LOG_LEVEL             = "default.log_level"
-	VIPER_DEFAULT_LOOP_SLEEP            = "default.loop_sleep"
-	VIPER_DEFAULT_PLUGIN_INCLUDE        = "default.plugin_include"
-	VIPER_DEFAULT_PLUGIN_TIMEOUT        = "default.plugin_timeout"
-	VIPER_DEFAULT_PROC_NUM              = "default.proc_num"
-	VIPER_DEFAULT_TIME_FORMAT           = "default.time_format"
-	VIPER_DEFAULT_TIME_ZONE             = "default.time_zone"
-	VIPER_DEFAULT_USER_AGENT            = "default.user_agent"
+	VIPER_DEFAULT_ADMIN_TOKEN             = "default.admin_token"
+	VIPER_DEFAULT_COORDINATION            = "default.coordination"
+	VIPER_DEFAULT_COORDINATION_ID         = "default.coordination_id"
+	VIPER_DEFAULT_COORDINATION_TTL        = "default.coordination_ttl"
+	VIPER_DEFAULT_EXPIRE_ACTION           = "default.expire_action"
+	VIPER_DEFAULT_EXPIRE_ACTION_DELAY     = "default.expire_action_delay"
+	VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT   = "default.expire_action_timeout"
+	VIPER_DEFAULT_EXPIRE_INTERVAL         = "default.expire_interval"
+	VIPER_DEFAULT_EXPORTER_LISTEN         = "default.exporter_listen"
+	VIPER_DEFAULT_FLOW_ARCHIVE            = "default.flow_archive"
+	VIPER_DEFAULT_FLOW_ARCHIVE_RETENTION  = "default.flow_archive_retention"
+	VIPER_DEFAULT_FLOW_CLEANUP            = "default.flow_cleanup"
+	VIPER_DEFAULT_FLOW_CONF               = "default.flow_conf"
+	VIPER_DEFAULT_FLOW_DATA               = "default.flow_data"
+	VIPER_DEFAULT_FLOW_DISABLE            = "default.flow_disable"
+	VIPER_DEFAULT_FLOW_ENABLE             = "default.flow_enable"
+	VIPER_DEFAULT_FLOW_INSTANCE           = "default.flow_instance"
+	VIPER_DEFAULT_FLOW_INTERVAL           = "default.flow_interval"
+	VIPER_DEFAULT_FLOW_LIMIT              = "default.flow_limit"
+	VIPER_DEFAULT_FLOW_SOURCE_BACKOFF     = "default.flow_source_backoff"
+	VIPER_DEFAULT_FLOW_SOURCE_BACKOFF_MAX = "default.flow_source_backoff_max"
+	VIPER_DEFAULT_FLOW_SOURCE_HEALTH      = "default.flow_source_health"
+	VIPER_DEFAULT_FLOW_SOURCE_PROBE       = "default.flow_source_probe"
+	VIPER_DEFAULT_FLOW_SOURCE_QUARANTINE  = "default.flow_source_quarantine"
+	VIPER_DEFAULT_LOG_LEVEL               = "default.log_level"
+	VIPER_DEFAULT_LOOP_SLEEP              = "default.loop_sleep"
+	VIPER_DEFAULT_PLUGIN_INCLUDE          = "default.plugin_include"
+	VIPER_DEFAULT_PLUGIN_TIMEOUT          = "default.plugin_timeout"
+	VIPER_DEFAULT_PROC_NUM                = "default.proc_num"
+	VIPER_DEFAULT_RATE_BURST              = "default.rate_burst"
+	VIPER_DEFAULT_RATE_LIMIT              = "default.rate_limit"
+	VIPER_DEFAULT_STATE_BACKEND           = "default.state_backend"
+	VIPER_DEFAULT_STATE_REDIS_ADDR        = "default.state_redis_addr"
+	VIPER_DEFAULT_STATE_REDIS_DB          = "default.state_redis_db"
+	VIPER_DEFAULT_STATE_REDIS_PASSWORD    = "default.state_redis_password"
+	VIPER_DEFAULT_TIME_FORMAT             = "default.time_format"
+	VIPER_DEFAULT_TIME_ZONE               = "default.time_zone"
+	VIPER_DEFAULT_USER_AGENT              = "default.user_agent"
 
 	// -----------------------------------------------------------------------------------------------------------------
 
@@ -94,6 +141,18 @@ const (
 # s - seconds, m - minutes, h - hours, d - days.
 # Example: 10s, 120m, 48h, 365d 
 
+# Token for administrative API (replay, tap, sources), API is disabled if token isn't set.
+# Requests must have "Authorization: Bearer <admin_token>" header.
+#admin_token             = "env://GOSQUITO_ADMIN_TOKEN"
+
+# Coordination between several gosquito instances with shared flows: none, file, redis.
+# file - leases are kept inside flow_data (might be shared storage), redis - leases are kept in state_redis_addr.
+# Every flow runs only on a single instance, flows are spread across alive instances.
+# Instance is considered as dead after coordination_ttl without heartbeats, its flows are taken over.
+#coordination            = "none"
+#coordination_id         = "<hostname>-<pid>"
+#coordination_ttl        = "30s"
+
 # Set command execution for expired input plugin sources.
 # First 3 arguments always added: <flow_name> <input_source> <source_timestamp>
 #expire_action           = ["/path/to/executable", "arg4", "arg5", "arg6"]
@@ -110,6 +169,10 @@ const (
 # Bind prometheus metrics exporter.
 #exporter_listen         = ":8080"
 
+# Keep input data of flow runs for replaying (gosquito replay), runs older than retention are removed.
+#flow_archive            = false
+#flow_archive_retention  = "7d"
+
 # Should temp data be cleaned up at the end of flow execution.
 #flow_cleanup            = true
 
@@ -126,6 +189,15 @@ const (
 # How many flows may run in parallel (0 - no limits).
 #flow_limit              = 0
 
+# Input sources health (rss, resty, io): failed source is skipped with exponential backoff
+# (flow_source_backoff * 2^(failures - 1), flow_source_backoff_max at most),
+# source is quarantined after flow_source_quarantine consecutive failures (0 - never) and probed every flow_source_probe.
+#flow_source_health      = true
+#flow_source_backoff     = "1m"
+#flow_source_backoff_max = "1h"
+#flow_source_quarantine  = 10
+#flow_source_probe       = "6h"
+
 # Default number of flow instances.
 #flow_instance           = 1
 
@@ -146,6 +218,19 @@ const (
 # GOMAXPROCS.
 #proc_num                = <cpu_cores>
 
+# Process-wide rate limit for HTTP-based plugins (rss, resty, fetch, expandurl, webchela).
+# Requests per second per remote host (0 - no limits) and how many requests may be done at once.
+# Might be overridden with flow parameters.
+#rate_limit              = 0
+#rate_burst              = 1
+
+# State backend for plugins' state: badger, sqlite, redis.
+# badger and sqlite keep state inside flow data directory, redis allows sharing state between hosts.
+#state_backend           = "badger"
+#state_redis_addr        = "127.0.0.1:6379"
+#state_redis_db          = 0
+#state_redis_password    = "env://GOSQUITO_REDIS_PASSWORD"
+
 # Time settings for Datum.Timeformat (Datum.Time keeps original source time unchanged). 
 # It needs for representing Datum datetime in user-defined format.
 #time_format             = "15:04 02.01.2006"
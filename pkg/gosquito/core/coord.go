@@ -0,0 +1,397 @@
+package core
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/renameio"
+	log "github.com/livelace/logrus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// Coordinator shares flows between several gosquito instances.
+// Every flow is leased by a single instance, leases are renewed with heartbeats and taken over after expiration.
+type Coordinator struct {
+	m sync.Mutex
+
+	ID      string
+	TTL     time.Duration
+	backend coordBackend
+	owned   map[string]bool
+
+	// OnRevoke is called if lease of owned flow was lost (renew failed), running flow must be stopped.
+	OnRevoke func(flow string)
+}
+
+type coordBackend interface {
+	// acquire takes free lease or renews own lease.
+	acquire(flow string, id string, ttl time.Duration) (bool, error)
+	heartbeat(id string, ttl time.Duration) error
+	members(ttl time.Duration) ([]string, error)
+	release(flow string, id string) error
+}
+
+// Acquire returns true if flow is leased by this instance and might be run.
+// Flows are sharded across alive instances with rendezvous hashing.
+// Lease of running flow is never given back, it's released after flow finish.
+func (c *Coordinator) Acquire(flow string, running bool) (bool, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	members, err := c.backend.members(c.TTL)
+	if err != nil {
+		return false, err
+	}
+
+	preferred := getPreferredMember(flow, members)
+
+	// Give flow back to preferred instance (rebalancing after instance join).
+	// Running flow keeps its lease (renewed with heartbeats), but new instances aren't started.
+	if c.owned[flow] && preferred != "" && preferred != c.ID {
+		if running {
+			return false, nil
+		}
+
+		delete(c.owned, flow)
+		return false, c.backend.release(flow, c.ID)
+	}
+
+	if !c.owned[flow] && preferred != "" && preferred != c.ID {
+		return false, nil
+	}
+
+	ok, err := c.backend.acquire(flow, c.ID, c.TTL)
+	if err != nil {
+		if c.owned[flow] {
+			c.revoke(flow)
+		}
+		return false, err
+	}
+
+	if ok {
+		c.owned[flow] = true
+	} else if c.owned[flow] {
+		c.revoke(flow)
+	}
+
+	return ok, nil
+}
+
+// Close releases all leases, so other instances can take over flows immediately.
+func (c *Coordinator) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for flow := range c.owned {
+		if err := c.backend.release(flow, c.ID); err != nil {
+			return err
+		}
+		delete(c.owned, flow)
+	}
+
+	return nil
+}
+
+// Heartbeat keeps instance alive and renews owned leases.
+func (c *Coordinator) Heartbeat() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	// Instance might be considered as dead by others, there is no guarantee that leases are still ours.
+	if err := c.backend.heartbeat(c.ID, c.TTL); err != nil {
+		for flow := range c.owned {
+			c.revoke(flow)
+		}
+		return err
+	}
+
+	var result error
+
+	for flow := range c.owned {
+		ok, err := c.backend.acquire(flow, c.ID, c.TTL)
+		if err != nil || !ok {
+			c.revoke(flow)
+		}
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
+// revoke forgets lost lease and fences running flow.
+func (c *Coordinator) revoke(flow string) {
+	delete(c.owned, flow)
+
+	log.WithFields(log.Fields{
+		"id":   c.ID,
+		"flow": flow,
+	}).Warn(ERROR_FLOW_LEASE_LOST)
+
+	if c.OnRevoke != nil {
+		c.OnRevoke(flow)
+	}
+}
+
+// Run sends heartbeats until context is done.
+func (c *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Heartbeat(); err != nil {
+			log.WithFields(log.Fields{
+				"id":    c.ID,
+				"error": err,
+			}).Error(ERROR_COORDINATION)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+// fileCoordBackend keeps leases inside flow data directory (might be shared with NFS etc.).
+// Lease operations are serialized with flock.
+type fileCoordBackend struct {
+	dir string
+}
+
+func (b *fileCoordBackend) acquire(flow string, id string, ttl time.Duration) (bool, error) {
+	var ok bool
+
+	err := b.withLock(flow, func(leaseFile string) error {
+		owner, expire := b.readLease(leaseFile)
+
+		if owner != id && owner != "" && time.Now().Before(expire) {
+			return nil
+		}
+
+		ok = true
+		return renameio.WriteFile(leaseFile, []byte(fmt.Sprintf("%s %d", id, time.Now().Add(ttl).UnixNano())), 0644)
+	})
+
+	return ok, err
+}
+
+func (b *fileCoordBackend) heartbeat(id string, ttl time.Duration) error {
+	dir := filepath.Join(b.dir, "members")
+	if err := CreateDirIfNotExist(dir); err != nil {
+		return err
+	}
+
+	return renameio.WriteFile(filepath.Join(dir, id), []byte(strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10)), 0644)
+}
+
+func (b *fileCoordBackend) members(ttl time.Duration) ([]string, error) {
+	result := make([]string, 0)
+
+	files, err := os.ReadDir(filepath.Join(b.dir, "members"))
+	if os.IsNotExist(err) {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+
+	for _, f := range files {
+		content, err := os.ReadFile(filepath.Join(b.dir, "members", f.Name()))
+		if err != nil {
+			continue
+		}
+
+		if expire, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64); err == nil &&
+			time.Now().UnixNano() < expire {
+			result = append(result, f.Name())
+		}
+	}
+
+	return result, nil
+}
+
+func (b *fileCoordBackend) readLease(leaseFile string) (string, time.Time) {
+	content, err := os.ReadFile(leaseFile)
+	if err != nil {
+		return "", time.Time{}
+	}
+
+	s := strings.Fields(string(content))
+	if len(s) != 2 {
+		return "", time.Time{}
+	}
+
+	expire, err := strconv.ParseInt(s[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}
+	}
+
+	return s[0], time.Unix(0, expire)
+}
+
+func (b *fileCoordBackend) release(flow string, id string) error {
+	return b.withLock(flow, func(leaseFile string) error {
+		if owner, _ := b.readLease(leaseFile); owner == id {
+			return os.Remove(leaseFile)
+		}
+		return nil
+	})
+}
+
+func (b *fileCoordBackend) withLock(flow string, f func(leaseFile string) error) error {
+	dir := filepath.Join(b.dir, "leases")
+	if err := CreateDirIfNotExist(dir); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, flow+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	return f(filepath.Join(dir, flow+".lease"))
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+// redisCoordBackend keeps leases and members in redis (state_redis_* settings).
+type redisCoordBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+var (
+	redisCoordAcquire = redis.NewScript(`
+local owner = redis.call("GET", KEYS[1])
+if owner == false then
+	return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2]) and 1 or 0
+elseif owner == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+	redisCoordRelease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+func (b *redisCoordBackend) acquire(flow string, id string, ttl time.Duration) (bool, error) {
+	r, err := redisCoordAcquire.Run(context.Background(), b.client,
+		[]string{b.prefix + "lease:" + flow}, id, ttl.Milliseconds()).Int()
+
+	return r == 1, err
+}
+
+func (b *redisCoordBackend) heartbeat(id string, ttl time.Duration) error {
+	ctx := context.Background()
+	key := b.prefix + "members"
+	now := time.Now()
+
+	if err := b.client.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(ttl).UnixNano()), Member: id}).Err(); err != nil {
+		return err
+	}
+
+	return b.client.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixNano(), 10)).Err()
+}
+
+func (b *redisCoordBackend) members(ttl time.Duration) ([]string, error) {
+	return b.client.ZRangeByScore(context.Background(), b.prefix+"members", &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().UnixNano(), 10),
+		Max: "+inf",
+	}).Result()
+}
+
+func (b *redisCoordBackend) release(flow string, id string) error {
+	return redisCoordRelease.Run(context.Background(), b.client, []string{b.prefix + "lease:" + flow}, id).Err()
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+func getPreferredMember(flow string, members []string) string {
+	var preferred string
+	var score uint64
+
+	sort.Strings(members)
+
+	for _, member := range members {
+		h := sha1.Sum([]byte(member + "/" + flow))
+
+		if s := binary.BigEndian.Uint64(h[:8]); preferred == "" || s > score {
+			preferred = member
+			score = s
+		}
+	}
+
+	return preferred
+}
+
+// NewCoordinator returns nil if coordination is disabled.
+func NewCoordinator(config *viper.Viper) (*Coordinator, error) {
+	ttl, b := IsInterval(config.GetString(VIPER_DEFAULT_COORDINATION_TTL))
+	if !b || ttl < 3000 {
+		return nil, fmt.Errorf(ERROR_COORDINATION_TTL.Error(), config.GetString(VIPER_DEFAULT_COORDINATION_TTL))
+	}
+
+	id := config.GetString(VIPER_DEFAULT_COORDINATION_ID)
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	coordinator := &Coordinator{
+		ID:    id,
+		TTL:   time.Duration(ttl) * time.Millisecond,
+		owned: make(map[string]bool),
+	}
+
+	switch backend := config.GetString(VIPER_DEFAULT_COORDINATION); backend {
+	case "none", "":
+		return nil, nil
+
+	case "file":
+		coordinator.backend = &fileCoordBackend{
+			dir: filepath.Join(config.GetString(VIPER_DEFAULT_FLOW_DATA), DEFAULT_COORDINATION_DIR),
+		}
+
+	case "redis":
+		client, err := GetRedisClient(config)
+		if err != nil {
+			return nil, err
+		}
+
+		coordinator.backend = &redisCoordBackend{
+			client: client,
+			prefix: fmt.Sprintf("%s:coordination:", APP_NAME),
+		}
+
+	default:
+		return nil, fmt.Errorf(ERROR_COORDINATION_UNKNOWN.Error(), backend)
+	}
+
+	return coordinator, coordinator.Heartbeat()
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileCoordBackend(t *testing.T) {
+	b := &fileCoordBackend{dir: t.TempDir()}
+	ttl := 200 * time.Millisecond
+
+	acquire := func(id string, want bool) {
+		t.Helper()
+
+		if ok, err := b.acquire("flow", id, ttl); err != nil || ok != want {
+			t.Errorf(`acquire(%q) = %v, %v, want %v`, id, ok, err, want)
+		}
+	}
+
+	acquire("a", true)
+	acquire("b", false)
+
+	// Own lease is renewed.
+	acquire("a", true)
+
+	// Expired lease is taken over.
+	time.Sleep(ttl + 50*time.Millisecond)
+	acquire("b", true)
+	acquire("a", false)
+
+	// Lease of other instance isn't released.
+	if err := b.release("flow", "a"); err != nil {
+		t.Fatal(err)
+	}
+	acquire("a", false)
+
+	if err := b.release("flow", "b"); err != nil {
+		t.Fatal(err)
+	}
+	acquire("a", true)
+}
+
+func TestCoordinatorFencing(t *testing.T) {
+	dir := t.TempDir()
+	revoked := make([]string, 0)
+
+	a := &Coordinator{
+		ID:       "a",
+		TTL:      200 * time.Millisecond,
+		backend:  &fileCoordBackend{dir: dir},
+		owned:    make(map[string]bool),
+		OnRevoke: func(flow string) { revoked = append(revoked, flow) },
+	}
+
+	b := &Coordinator{
+		ID:      "b",
+		TTL:     time.Minute,
+		backend: &fileCoordBackend{dir: dir},
+		owned:   make(map[string]bool),
+	}
+
+	if err := a.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := a.Acquire("flow", false); err != nil || !ok {
+		t.Fatalf(`Acquire() = %v, %v`, ok, err)
+	}
+
+	// Instance "a" is considered dead (no heartbeats), its lease is taken over.
+	time.Sleep(a.TTL + 50*time.Millisecond)
+
+	if err := b.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := b.Acquire("flow", false); err != nil || !ok {
+		t.Fatalf(`Acquire() of other instance = %v, %v`, ok, err)
+	}
+
+	// Running flow of "a" is fenced after lease loss.
+	if err := a.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revoked) != 1 || revoked[0] != "flow" || a.owned["flow"] {
+		t.Errorf(`Heartbeat() revoked = %v, owned = %v`, revoked, a.owned)
+	}
+
+	if ok, err := a.Acquire("flow", true); err != nil || ok {
+		t.Errorf(`Acquire() after lease loss = %v, %v`, ok, err)
+	}
+}
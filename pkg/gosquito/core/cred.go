@@ -0,0 +1,488 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	auth "github.com/hashicorp/vault/api/auth/approle"
+	log "github.com/livelace/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	credCache      = make(map[string]credCacheItem)
+	credCacheGroup singleflight.Group
+	credCacheLock  sync.Mutex
+
+	credProviders = map[string]CredProvider{
+		"env://":  &EnvCredProvider{},
+		"file://": &FileCredProvider{},
+	}
+	credProvidersLock sync.RWMutex
+
+	vaultSessions     = make(map[string]*VaultSession)
+	vaultSessionsLock sync.Mutex
+)
+
+// CredProvider resolves credential reference (without scheme) into secret value.
+// Returned duration controls caching: 0 - never cache, -1 - default ttl, other - maximum ttl.
+type CredProvider interface {
+	GetCred(ref string) (string, time.Duration, error)
+}
+
+type credCacheItem struct {
+	Value  string
+	Expire time.Time
+}
+
+// EnvCredProvider reads secrets from environment variables: "env://NAME".
+type EnvCredProvider struct{}
+
+func (p *EnvCredProvider) GetCred(ref string) (string, time.Duration, error) {
+	return os.Getenv(ref), 0, nil
+}
+
+// FileCredProvider reads secrets from files (Docker/Kubernetes secrets): "file:///run/secrets/name".
+type FileCredProvider struct{}
+
+func (p *FileCredProvider) GetCred(ref string) (string, time.Duration, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), -1, nil
+}
+
+// VaultCredProvider reads secrets from vault: "vault://path,key".
+type VaultCredProvider struct {
+	Session *VaultSession
+}
+
+func (p *VaultCredProvider) GetCred(ref string) (string, time.Duration, error) {
+	s := strings.Split(ref, ",")
+	if len(s) < 2 {
+		return "", 0, fmt.Errorf("vault secret must be set as path,key: %s", ref)
+	}
+
+	secretPath := p.Session.SecretPath(s[0])
+	secretKey := s[1]
+
+	secret, err := p.Session.Client.Logical().Read(secretPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if secret == nil {
+		return "", 0, fmt.Errorf("vault secret not found: %s", secretPath)
+	}
+
+	data := secret.Data
+
+	// KV v2 keeps secret inside "data" with additional "metadata".
+	if _, ok := data["metadata"]; ok {
+		if m, ok := data["data"].(map[string]interface{}); ok {
+			data = m
+		}
+	}
+
+	value, ok := data[secretKey].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("vault secret key not found: %s", secretKey)
+	}
+
+	if secret.LeaseDuration > 0 {
+		return value, time.Duration(secret.LeaseDuration) * time.Second, nil
+	}
+
+	return value, -1, nil
+}
+
+// VaultSession keeps authenticated vault client and its settings.
+// Sessions are shared across plugins with the same vault configuration.
+type VaultSession struct {
+	Auth      string
+	CacheTTL  time.Duration
+	Client    *vault.Client
+	KvVersion int
+
+	login func() (*vault.Secret, error)
+}
+
+// SecretPath converts "mount/path" into "mount/data/path" for KV v2.
+func (s *VaultSession) SecretPath(path string) string {
+	if s.KvVersion != 2 {
+		return path
+	}
+
+	p := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(p) < 2 || strings.HasPrefix(p[1], "data/") {
+		return path
+	}
+
+	return p[0] + "/data/" + p[1]
+}
+
+// watch renews token lease until it is possible and logins again after (if auth method allows it).
+func (s *VaultSession) watch(secret *vault.Secret) {
+	for {
+		if secret != nil && secret.Auth != nil && secret.Auth.Renewable {
+			watcher, err := s.Client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{Secret: secret})
+			if err != nil {
+				log.WithFields(log.Fields{"auth": s.Auth, "address": s.Client.Address(), "error": err}).
+					Error("vault token renewal")
+				return
+			}
+
+			go watcher.Start()
+
+		renew:
+			for {
+				select {
+				case err := <-watcher.DoneCh():
+					if err != nil {
+						log.WithFields(log.Fields{"auth": s.Auth, "address": s.Client.Address(), "error": err}).
+							Warn("vault token renewal")
+					}
+					break renew
+				case <-watcher.RenewCh():
+					log.WithFields(log.Fields{"auth": s.Auth, "address": s.Client.Address()}).
+						Debug("vault token renewed")
+				}
+			}
+
+			watcher.Stop()
+
+		} else if secret != nil && secret.Auth != nil && secret.Auth.LeaseDuration > 0 {
+			time.Sleep(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3)
+
+		} else {
+			return
+		}
+
+		if s.login == nil {
+			return
+		}
+
+		var err error
+		for secret, err = s.login(); err != nil; secret, err = s.login() {
+			log.WithFields(log.Fields{"auth": s.Auth, "address": s.Client.Address(), "error": err}).
+				Error("vault login")
+			time.Sleep(time.Duration(DEFAULT_LOOP_SLEEP) * time.Millisecond * 10)
+		}
+	}
+}
+
+func GetCredValue(cred string, vault *vault.Client) string {
+	var provider CredProvider
+	var ref string
+	var ttl time.Duration
+
+	if c := strings.SplitN(cred, "vault://", 2); len(c) > 1 {
+		session := getVaultSession(vault)
+		if session == nil {
+			return cred
+		}
+
+		provider = &VaultCredProvider{Session: session}
+		ref = c[1]
+		ttl = session.CacheTTL
+
+	} else {
+		credProvidersLock.RLock()
+		for scheme, p := range credProviders {
+			if c := strings.SplitN(cred, scheme, 2); len(c) > 1 {
+				provider = p
+				ref = c[1]
+				break
+			}
+		}
+		credProvidersLock.RUnlock()
+
+		if provider == nil {
+			return cred
+		}
+
+		ttl, _ = time.ParseDuration(DEFAULT_CRED_CACHE_TTL)
+	}
+
+	key := cred
+	if vault != nil {
+		key = fmt.Sprintf("%p,%s", vault, cred)
+	}
+
+	credCacheLock.Lock()
+	item, ok := credCache[key]
+	credCacheLock.Unlock()
+
+	if ok && time.Now().Before(item.Expire) {
+		return item.Value
+	}
+
+	// Concurrent lookups of the same credential share one provider call, other lookups aren't blocked.
+	value, _, _ := credCacheGroup.Do(key, func() (interface{}, error) {
+		value, leaseTTL, err := provider.GetCred(ref)
+		if err != nil {
+			log.WithFields(log.Fields{"cred": cred, "error": err}).Error("cannot get credential")
+			return "", nil
+		}
+
+		// Provider decides if value can be cached: 0 - never, -1 - default ttl, lease - no longer than lease.
+		if leaseTTL == 0 {
+			return value, nil
+		}
+
+		if leaseTTL > 0 && leaseTTL < ttl {
+			ttl = leaseTTL
+		}
+
+		credCacheLock.Lock()
+		credCache[key] = credCacheItem{Value: value, Expire: time.Now().Add(ttl)}
+		credCacheLock.Unlock()
+
+		return value, nil
+	})
+
+	return value.(string)
+}
+
+func GetVault(m map[string]interface{}) (*vault.Client, error) {
+	if len(m) > 0 {
+		var address string
+		var authMethod string
+		var cacheTTL = DEFAULT_CRED_CACHE_TTL
+		var kvVersion = DEFAULT_VAULT_KV_VERSION
+
+		if v, b := IsString(m["address"]); b {
+			if address = GetVarFromEnv(v); address == "" {
+				return nil, fmt.Errorf("vault address env not set: %v", v)
+			}
+		} else {
+			return nil, fmt.Errorf("vault address must be set: %v", m["address"])
+		}
+
+		if v, b := IsString(m["auth"]); b {
+			authMethod = v
+		} else if _, b := m["token"]; b {
+			authMethod = "token"
+		} else if _, b := m["k8s_role"]; b {
+			authMethod = "kubernetes"
+		} else {
+			authMethod = "approle"
+		}
+
+		if v, ok := m["cache_ttl"]; ok {
+			if s, b := IsString(v); b {
+				cacheTTL = s
+			} else {
+				return nil, fmt.Errorf("vault cache_ttl must be string: %v", v)
+			}
+		}
+
+		ttl, err := time.ParseDuration(cacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("vault cache_ttl wrong: %v", err)
+		}
+
+		if v, ok := m["kv_version"]; ok {
+			if i, b := IsInt(v); b && (i == 1 || i == 2) {
+				kvVersion = i
+			} else {
+				return nil, fmt.Errorf("vault kv_version must be 1 or 2: %v", v)
+			}
+		}
+
+		// Reuse already authenticated session.
+		sessionKey := getVaultSessionKey(m)
+
+		vaultSessionsLock.Lock()
+		defer vaultSessionsLock.Unlock()
+
+		if session, ok := vaultSessions[sessionKey]; ok {
+			return session.Client, nil
+		}
+
+		vaultConfig := vault.DefaultConfig()
+		vaultConfig.Address = address
+
+		vaultClient, err := vault.NewClient(vaultConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize vault client: %v", err)
+		}
+
+		session := &VaultSession{
+			Auth:      authMethod,
+			CacheTTL:  ttl,
+			Client:    vaultClient,
+			KvVersion: kvVersion,
+		}
+
+		var secret *vault.Secret
+
+		switch authMethod {
+		case "approle":
+			var app_role string
+			var app_secret string
+
+			if v, b := IsString(m["app_role"]); b {
+				if app_role = GetVarFromEnv(v); app_role == "" {
+					return nil, fmt.Errorf("vault app_role env not set: %v", v)
+				}
+			} else {
+				return nil, fmt.Errorf("vault app_role must be set: %v", m["app_role"])
+			}
+
+			if v, b := IsString(m["app_secret"]); b {
+				if app_secret = GetCredValue(v, nil); app_secret == "" {
+					return nil, fmt.Errorf("vault app_secret env not set: %v", v)
+				}
+			} else {
+				return nil, fmt.Errorf("vault app_secret must be set: %v", m["app_secret"])
+			}
+
+			appRoleAuth, err := auth.NewAppRoleAuth(
+				app_role,
+				&auth.SecretID{FromString: app_secret},
+			)
+			if err != nil {
+				return nil, fmt.Errorf("cannot initialize app role auth: %v", err)
+			}
+
+			session.login = func() (*vault.Secret, error) {
+				authInfo, err := vaultClient.Auth().Login(context.TODO(), appRoleAuth)
+				if err != nil {
+					return nil, fmt.Errorf("cannot login with app role: %v", err)
+				}
+				if authInfo == nil {
+					return nil, fmt.Errorf("no auth info for app role")
+				}
+				return authInfo, nil
+			}
+
+		case "kubernetes":
+			var k8s_role string
+			var k8s_mount = DEFAULT_VAULT_K8S_MOUNT
+			var k8s_token = DEFAULT_VAULT_K8S_TOKEN
+
+			if v, b := IsString(m["k8s_role"]); b {
+				if k8s_role = GetVarFromEnv(v); k8s_role == "" {
+					return nil, fmt.Errorf("vault k8s_role env not set: %v", v)
+				}
+			} else {
+				return nil, fmt.Errorf("vault k8s_role must be set: %v", m["k8s_role"])
+			}
+
+			if v, b := IsString(m["k8s_mount"]); b {
+				k8s_mount = GetVarFromEnv(v)
+			}
+
+			if v, b := IsString(m["k8s_token"]); b {
+				k8s_token = GetVarFromEnv(v)
+			}
+
+			session.login = func() (*vault.Secret, error) {
+				jwt, err := os.ReadFile(k8s_token)
+				if err != nil {
+					return nil, fmt.Errorf("cannot read kubernetes service account token: %v", err)
+				}
+
+				authInfo, err := vaultClient.Logical().Write(fmt.Sprintf("auth/%s/login", k8s_mount), map[string]interface{}{
+					"jwt":  strings.TrimSpace(string(jwt)),
+					"role": k8s_role,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("cannot login with kubernetes: %v", err)
+				}
+				if authInfo == nil || authInfo.Auth == nil {
+					return nil, fmt.Errorf("no auth info for kubernetes")
+				}
+
+				vaultClient.SetToken(authInfo.Auth.ClientToken)
+
+				return authInfo, nil
+			}
+
+		case "token":
+			var token string
+
+			if v, b := IsString(m["token"]); b {
+				if token = GetCredValue(v, nil); token == "" {
+					return nil, fmt.Errorf("vault token env not set: %v", v)
+				}
+			} else {
+				return nil, fmt.Errorf("vault token must be set: %v", m["token"])
+			}
+
+			vaultClient.SetToken(token)
+
+			// Static token cannot be obtained again, only renewed.
+			self, err := vaultClient.Auth().Token().LookupSelf()
+			if err != nil {
+				return nil, fmt.Errorf("cannot lookup vault token: %v", err)
+			}
+
+			if renewable, _ := self.TokenIsRenewable(); renewable {
+				if secret, err = vaultClient.Auth().Token().RenewSelf(0); err != nil {
+					return nil, fmt.Errorf("cannot renew vault token: %v", err)
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("vault auth unknown: %v", authMethod)
+		}
+
+		if session.login != nil {
+			if secret, err = session.login(); err != nil {
+				return nil, err
+			}
+		}
+
+		go session.watch(secret)
+
+		vaultSessions[sessionKey] = session
+
+		return vaultClient, nil
+	}
+
+	return nil, nil
+}
+
+// RegisterCredProvider adds (or replaces) credential provider for scheme (e.g. "file://").
+func RegisterCredProvider(scheme string, provider CredProvider) {
+	credProvidersLock.Lock()
+	defer credProvidersLock.Unlock()
+
+	credProviders[scheme] = provider
+}
+
+func getVaultSession(client *vault.Client) *VaultSession {
+	if client == nil {
+		return nil
+	}
+
+	vaultSessionsLock.Lock()
+	defer vaultSessionsLock.Unlock()
+
+	for _, session := range vaultSessions {
+		if session.Client == client {
+			return session
+		}
+	}
+
+	return nil
+}
+
+func getVaultSessionKey(m map[string]interface{}) string {
+	keys := make([]string, 0)
+	for k, v := range m {
+		keys = append(keys, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
@@ -3,6 +3,12 @@ package core
 import "errors"
 
 var (
+	ERROR_ADMIN_DISABLED               = errors.New("admin api disabled, admin_token isn't set")
+	ERROR_ADMIN_TOKEN_INVALID          = errors.New("admin token invalid")
+	ERROR_ARCHIVE_READ                 = errors.New("archive read error: %s %v")
+	ERROR_COORDINATION                 = errors.New("coordination error")
+	ERROR_COORDINATION_TTL             = errors.New("coordination ttl must be interval (3s minimum): %s")
+	ERROR_COORDINATION_UNKNOWN         = errors.New("coordination backend unknown: %s")
 	ERROR_DATA_FIELD_KEY               = errors.New("datum field key must be string: %v")
 	ERROR_DATA_FIELD_NOT_SLICE         = errors.New("datum field not slice: %s")
 	ERROR_DATA_FIELD_NOT_STRING        = errors.New("datum field not string: %s")
@@ -14,10 +20,17 @@ var (
 	ERROR_FLOW_DISABLED                = errors.New("flow disabled")
 	ERROR_FLOW_ENABLE_DISABLE_CONFLICT = errors.New("default.flow_disable & default.flow_enable are mutual exclusive!")
 	ERROR_FLOW_EXPIRE                  = errors.New("flow expire")
+	ERROR_FLOW_INSTANCE_COMMIT         = errors.New("flow instance must be 1 for input plugin with confirmations: %d")
+	ERROR_FLOW_LEASE_LOST              = errors.New("flow lease lost")
+	ERROR_FLOW_LOCKED                  = errors.New("flow is running: %s")
 	ERROR_FLOW_NAME_COMPAT             = errors.New("flow name must be compatible: %s")
 	ERROR_FLOW_NAME_UNIQUE             = errors.New("flow name must be unique: %s")
+	ERROR_FLOW_NOT_FOUND               = errors.New("flow not found: %s")
 	ERROR_FLOW_PARSE                   = errors.New("flow parse error")
 	ERROR_FLOW_SOURCE_FAIL             = errors.New("flow contains failed sources")
+	ERROR_FLOW_VAR_REQUIRED            = errors.New("flow variable required: %s")
+	ERROR_FLOW_VAR_UNCLOSED            = errors.New("flow variable not closed: %s")
+	ERROR_GOLDEN_MISMATCH              = errors.New("golden mismatch: %s\n%s")
 	ERROR_INTERVAL_FORMAT_UNKNOWN      = errors.New("interval format unknown")
 	ERROR_NO_NEW_DATA                  = errors.New("no new data")
 	ERROR_NO_VALID_FLOW                = errors.New("no valid flow")
@@ -32,8 +45,15 @@ var (
 	ERROR_PLUGIN_REQUIRED_PARAM        = errors.New("required parameter wrong or not set: %s")
 	ERROR_PLUGIN_SAVE_DATA             = errors.New("plugin save data error: %s")
 	ERROR_PLUGIN_UNKNOWN               = errors.New("plugin unknown")
+	ERROR_QUEUE_READ                   = errors.New("queue read error: %s %v")
+	ERROR_REPLAY_TIME                  = errors.New("replay time must be RFC3339 or interval: %s")
 	ERROR_SEND_FAIL                    = errors.New("sending finished with errors")
 	ERROR_SIZE_FORMAT_UNKNOWN          = errors.New("size format unknown")
 	ERROR_SIZE_MISMATCH                = errors.New("size mismatch")
+	ERROR_SOURCE_BACKOFF               = errors.New("source skipped, next retry: %s")
+	ERROR_SOURCE_NOT_FOUND             = errors.New("source not found: %s")
+	ERROR_STATE_BACKEND_UNKNOWN        = errors.New("state backend unknown: %s")
+	ERROR_STATE_REDIS_UNAVAILABLE      = errors.New("state redis unavailable")
 	ERROR_SYMLINK_ERROR                = errors.New("cannot create symlink: %s")
+	ERROR_TAP_PARAM                    = errors.New("tap parameter invalid: %s")
 )
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/livelace/logrus"
+)
+
+// ExpireCheck checks if input sources were updated within expire interval.
+// Expired sources are reported (not more often than action delay) with:
+// 1. Command execution with args: <flow_name> <input_source> <source_timestamp> <expire_action args>.
+// 2. Synthetic datums, they are sent with "expire" plugin of flow (if set).
+type ExpireCheck struct {
+	m    sync.Mutex
+	last int64
+
+	Action        []string
+	ActionDelay   int64
+	ActionTimeout int
+	Interval      int64
+}
+
+// Check logs expired sources, executes action and queues notifications. Returns true if any source is expired.
+func (e *ExpireCheck) Check(flow *Flow, logFields log.Fields, sources []string, states map[string]time.Time) bool {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	currentTime := time.Now().UTC()
+	sourcesExpired := make([]string, 0)
+
+	for _, source := range sources {
+		sourceTime := states[source]
+
+		if (currentTime.Unix() - sourceTime.Unix()) > e.Interval/1000 {
+			sourcesExpired = append(sourcesExpired, source)
+
+			LogInputPlugin(logFields, source, fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
+		}
+	}
+
+	if len(sourcesExpired) == 0 {
+		return false
+	}
+
+	// Report expired sources if expire delay exceeded.
+	// last keeps last report timestamp.
+	if (currentTime.Unix() - e.last) <= e.ActionDelay/1000 {
+		return true
+	}
+
+	e.last = currentTime.Unix()
+
+	for _, source := range sourcesExpired {
+		sourceTime := states[source]
+
+		// Execute command with args.
+		// We don't worry about command return code.
+		if len(e.Action) > 0 {
+			cmd := e.Action[0]
+			args := []string{flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
+			args = append(args, e.Action[1:]...)
+
+			output, err := ExecWithTimeout(cmd, args, e.ActionTimeout)
+
+			LogInputPlugin(logFields, source, fmt.Sprintf(
+				"source expired action: command: %s, arguments: %v, output: %s, error: %v",
+				cmd, args, output, err))
+		}
+
+		// Notify with "expire" plugin.
+		if flow.ExpirePlugin != nil {
+			var u, _ = uuid.NewRandom()
+
+			flow.AddExpired(&Datum{
+				FLOW:       flow.FlowName,
+				PLUGIN:     fmt.Sprintf("%v", logFields["plugin"]),
+				SOURCE:     source,
+				TIME:       sourceTime,
+				TIMEFORMAT: sourceTime.Format(DEFAULT_TIME_FORMAT),
+				TIMEZONE:   time.UTC,
+				TIMEZONEA:  time.UTC,
+				TIMEZONEB:  time.UTC,
+				TIMEZONEC:  time.UTC,
+				UUID:       u,
+
+				EXPIRE: Expire{
+					AGE:      currentTime.Sub(sourceTime).Round(time.Second).String(),
+					INTERVAL: (time.Duration(e.Interval) * time.Millisecond).String(),
+					LAST:     sourceTime.Format(time.RFC3339),
+				},
+
+				VARS: flow.FlowVars,
+
+				WARNINGS: make([]string, 0),
+			})
+		}
+	}
+
+	return true
+}
+
+// NewExpireCheck sets expire parameters of input plugin (app config, template, plugin params).
+func NewExpireCheck(pluginConfig *PluginConfig, template string, availableParams map[string]int, logFields log.Fields) *ExpireCheck {
+	expire := &ExpireCheck{}
+
+	// expire_action.
+	setExpireAction := func(p interface{}) {
+		if v, b := IsSliceOfString(p); b {
+			availableParams["expire_action"] = 0
+			expire.Action = v
+		}
+	}
+	setExpireAction(pluginConfig.AppConfig.GetStringSlice(VIPER_DEFAULT_EXPIRE_ACTION))
+	setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
+	setExpireAction((*pluginConfig.PluginParams)["expire_action"])
+	ShowPluginParam(logFields, "expire_action", expire.Action)
+
+	// expire_action_delay.
+	setExpireActionDelay := func(p interface{}) {
+		if v, b := IsInterval(p); b {
+			availableParams["expire_action_delay"] = 0
+			expire.ActionDelay = v
+		}
+	}
+	setExpireActionDelay(pluginConfig.AppConfig.GetString(VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
+	setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
+	setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
+	ShowPluginParam(logFields, "expire_action_delay", expire.ActionDelay)
+
+	// expire_action_timeout.
+	setExpireActionTimeout := func(p interface{}) {
+		if v, b := IsInt(p); b {
+			availableParams["expire_action_timeout"] = 0
+			expire.ActionTimeout = v
+		}
+	}
+	setExpireActionTimeout(pluginConfig.AppConfig.GetInt(VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
+	setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
+	setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
+	ShowPluginParam(logFields, "expire_action_timeout", expire.ActionTimeout)
+
+	// expire_interval.
+	setExpireInterval := func(p interface{}) {
+		if v, b := IsInterval(p); b {
+			availableParams["expire_interval"] = 0
+			expire.Interval = v
+		}
+	}
+	setExpireInterval(pluginConfig.AppConfig.GetString(VIPER_DEFAULT_EXPIRE_INTERVAL))
+	setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
+	setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
+	ShowPluginParam(logFields, "expire_interval", expire.Interval)
+
+	return expire
+}
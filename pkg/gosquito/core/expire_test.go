@@ -0,0 +1,56 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	log "github.com/livelace/logrus"
+)
+
+type expireOutput struct{}
+
+func (p *expireOutput) FlowLog(message interface{}) {}
+
+func (p *expireOutput) GetName() string { return "expire" }
+
+func (p *expireOutput) GetOutput() []string { return []string{} }
+
+func (p *expireOutput) Send(d []*Datum) error { return nil }
+
+func TestExpireCheck(t *testing.T) {
+	flow := &Flow{FlowName: "expire", ExpirePlugin: &expireOutput{}}
+	e := &ExpireCheck{Interval: 60 * 1000, ActionDelay: 60 * 60 * 1000}
+	logFields := log.Fields{"plugin": "rss"}
+
+	states := map[string]time.Time{
+		"a": time.Now().UTC().Add(-2 * time.Minute),
+		"b": time.Now().UTC(),
+		"c": time.Now().UTC().Add(-time.Hour),
+	}
+
+	if e.Check(flow, logFields, []string{"b"}, states) || len(flow.PopExpired()) != 0 {
+		t.Errorf(`Check() without expired sources = true`)
+	}
+
+	// All expired sources (including unknown) are reported.
+	if !e.Check(flow, logFields, []string{"a", "b", "c", "d"}, states) {
+		t.Errorf(`Check() = false`)
+	}
+
+	sources := make([]string, 0)
+	for _, item := range flow.PopExpired() {
+		sources = append(sources, item.SOURCE)
+	}
+	sort.Strings(sources)
+
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf(`Check() expired = %v, want %v`, sources, want)
+	}
+
+	// Expired sources aren't reported again within action delay.
+	if !e.Check(flow, logFields, []string{"a"}, states) || len(flow.PopExpired()) != 0 {
+		t.Errorf(`Check() within action delay reported sources`)
+	}
+}
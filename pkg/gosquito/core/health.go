@@ -0,0 +1,260 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SourceHealth keeps failures of single input source.
+type SourceHealth struct {
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error"`
+	LastFailure time.Time `json:"last_failure"`
+	LastSuccess time.Time `json:"last_success"`
+	NextRetry   time.Time `json:"next_retry"`
+	Quarantined bool      `json:"quarantined"`
+}
+
+// Health tracks input sources of flow:
+// 1. Failed source is skipped with exponential backoff (Backoff * 2^(failures-1), BackoffMax at most).
+// 2. Source is quarantined after Quarantine consecutive failures (0 - never), quarantined source is probed every Probe.
+// 3. Successful fetch resets source failures and quarantine.
+type Health struct {
+	m       sync.Mutex
+	changed bool
+	loaded  bool
+	sources map[string]*SourceHealth
+
+	Dir        string
+	Enable     bool
+	Backoff    time.Duration
+	BackoffMax time.Duration
+	Probe      time.Duration
+	Quarantine int
+}
+
+func (h *Health) get(source string) *SourceHealth {
+	if v, ok := h.sources[source]; ok {
+		return v
+	}
+
+	v := &SourceHealth{}
+	h.sources[source] = v
+
+	return v
+}
+
+func (h *Health) load() error {
+	if h.loaded {
+		return nil
+	}
+
+	h.sources = make(map[string]*SourceHealth)
+
+	db, err := OpenStateStore(h.Dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.Iterate(func(source string, value []byte) error {
+		var v SourceHealth
+
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+
+		h.sources[source] = &v
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	h.loaded = true
+
+	return nil
+}
+
+// Allow returns true if source might be fetched now (no backoff, quarantine probe is due).
+func (h *Health) Allow(source string) bool {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if !h.Enable {
+		return true
+	}
+
+	// Don't block sources if health states are unavailable.
+	if err := h.load(); err != nil {
+		return true
+	}
+
+	if v, ok := h.sources[source]; ok {
+		return !time.Now().Before(v.NextRetry)
+	}
+
+	return true
+}
+
+// Fail registers source failure and calculates next retry time.
+func (h *Health) Fail(source string, err error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if !h.Enable {
+		return
+	}
+
+	if e := h.load(); e != nil {
+		return
+	}
+
+	currentTime := time.Now().UTC()
+
+	v := h.get(source)
+	v.Failures += 1
+	v.LastError = fmt.Sprintf("%v", err)
+	v.LastFailure = currentTime
+
+	if h.Quarantine > 0 && v.Failures >= h.Quarantine {
+		v.Quarantined = true
+		v.NextRetry = currentTime.Add(h.Probe)
+
+	} else {
+		backoff := h.Backoff
+		for i := 1; i < v.Failures && backoff < h.BackoffMax; i++ {
+			backoff *= 2
+		}
+
+		if backoff > h.BackoffMax {
+			backoff = h.BackoffMax
+		}
+
+		v.NextRetry = currentTime.Add(backoff)
+	}
+
+	h.changed = true
+}
+
+// Load reads health states (states are read on first access otherwise).
+func (h *Health) Load() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	return h.load()
+}
+
+// NextRetry returns time when source might be fetched again.
+func (h *Health) NextRetry(source string) time.Time {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if v, ok := h.sources[source]; ok {
+		return v.NextRetry
+	}
+
+	return time.Time{}
+}
+
+// Release resets source failures and quarantine.
+func (h *Health) Release(source string) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if err := h.load(); err != nil {
+		return err
+	}
+
+	v, ok := h.sources[source]
+	if !ok {
+		return fmt.Errorf(ERROR_SOURCE_NOT_FOUND.Error(), source)
+	}
+
+	v.Failures = 0
+	v.NextRetry = time.Time{}
+	v.Quarantined = false
+
+	h.changed = true
+
+	return h.save()
+}
+
+// Save persists changed health states.
+func (h *Health) Save() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	return h.save()
+}
+
+func (h *Health) save() error {
+	if !h.changed {
+		return nil
+	}
+
+	db, err := OpenStateStore(h.Dir)
+	if err != nil {
+		return err
+	}
+
+	for source, v := range h.sources {
+		b, err := json.Marshal(v)
+		if err != nil {
+			_ = db.Close()
+			return err
+		}
+
+		if err := db.Put(source, b, 0); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+
+	h.changed = false
+
+	return db.Close()
+}
+
+// Snapshot returns copy of loaded health states (states aren't loaded until first flow run).
+func (h *Health) Snapshot() map[string]SourceHealth {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	temp := make(map[string]SourceHealth, len(h.sources))
+
+	for source, v := range h.sources {
+		temp[source] = *v
+	}
+
+	return temp
+}
+
+// Success resets source failures and quarantine.
+func (h *Health) Success(source string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if !h.Enable {
+		return
+	}
+
+	if err := h.load(); err != nil {
+		return
+	}
+
+	v := h.get(source)
+
+	// Healthy sources aren't saved every run.
+	if v.Failures > 0 || v.Quarantined || v.LastSuccess.IsZero() {
+		h.changed = true
+	}
+
+	v.Failures = 0
+	v.LastSuccess = time.Now().UTC()
+	v.NextRetry = time.Time{}
+	v.Quarantined = false
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	dir := t.TempDir()
+
+	h := &Health{
+		Dir:        dir,
+		Enable:     true,
+		Backoff:    time.Minute,
+		BackoffMax: 4 * time.Minute,
+		Probe:      time.Hour,
+		Quarantine: 5,
+	}
+
+	// Backoff is doubled up to maximum, source is quarantined after consecutive failures.
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute, time.Hour} {
+		h.Fail("a", errors.New("fail"))
+
+		v := h.Snapshot()["a"]
+
+		if got := v.NextRetry.Sub(v.LastFailure); got != want || v.Failures != i+1 || v.Quarantined != (i == 4) {
+			t.Errorf(`Fail() #%d = %v, %d, %v, want %v`, i+1, got, v.Failures, v.Quarantined, want)
+		}
+	}
+
+	if h.Allow("a") || !h.Allow("b") {
+		t.Errorf(`Allow() = %v, %v`, h.Allow("a"), h.Allow("b"))
+	}
+
+	// Health states are kept across restarts.
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	h = &Health{Dir: dir, Enable: true}
+
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := h.Snapshot()["a"]; h.Allow("a") || !v.Quarantined {
+		t.Errorf(`Allow() after restart = %v, %v`, h.Allow("a"), v)
+	}
+
+	// Successful fetch resets failures and quarantine.
+	h.Success("a")
+
+	if v := h.Snapshot()["a"]; !h.Allow("a") || v.Failures != 0 || v.Quarantined {
+		t.Errorf(`Success() = %v, %v`, h.Allow("a"), v)
+	}
+
+	// Disabled health tracking doesn't block sources.
+	h = &Health{Dir: t.TempDir(), Backoff: time.Minute, BackoffMax: time.Minute}
+	h.Fail("a", errors.New("fail"))
+
+	if !h.Allow("a") || len(h.Snapshot()) != 0 {
+		t.Errorf(`Allow() of disabled health = %v, %v`, h.Allow("a"), h.Snapshot())
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	rateLimiters     = make(map[string]*rate.Limiter)
+	rateLimitersLock sync.Mutex
+)
+
+// GetURLHost returns lower-cased host (without port) or empty string for non-URL values.
+func GetURLHost(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// RateLimitWait blocks until request to the URL host is allowed by process-wide token bucket.
+// Bucket is shared between all flows/plugins with the same host, limit <= 0 disables limiting.
+// If flows have different limits for the same host, the strictest limit and burst are applied.
+func RateLimitWait(ctx context.Context, u string, limit float64, burst int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	host := GetURLHost(u)
+	if host == "" {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	rateLimitersLock.Lock()
+	limiter, ok := rateLimiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		rateLimiters[host] = limiter
+	}
+	if rate.Limit(limit) < limiter.Limit() {
+		limiter.SetLimit(rate.Limit(limit))
+	}
+	if burst < limiter.Burst() {
+		limiter.SetBurst(burst)
+	}
+	rateLimitersLock.Unlock()
+
+	return limiter.Wait(ctx)
+}
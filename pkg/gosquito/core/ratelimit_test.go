@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestGetURLHost(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.COM:8443/feed": "example.com",
+		" http://example.com/feed ":     "example.com",
+		"news":                          "",
+		"://invalid":                    "",
+	}
+
+	for u, want := range tests {
+		if got := GetURLHost(u); got != want {
+			t.Errorf(`GetURLHost(%q) = %q, want %q`, u, got, want)
+		}
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	ctx := context.Background()
+
+	limiter := func(host string) *rate.Limiter {
+		rateLimitersLock.Lock()
+		defer rateLimitersLock.Unlock()
+
+		return rateLimiters[host]
+	}
+
+	// Limiting is disabled.
+	if err := RateLimitWait(ctx, "https://disabled.example.com/", 0, 1); err != nil || limiter("disabled.example.com") != nil {
+		t.Errorf(`RateLimitWait() without limit = %v`, err)
+	}
+
+	// The strictest limit and burst of the host are applied.
+	for _, v := range []struct {
+		u     string
+		limit float64
+		burst int
+	}{
+		{"https://strict.example.com/a", 1000, 10},
+		{"http://STRICT.example.com:8080/b", 500, 20},
+		{"https://strict.example.com/c", 2000, 5},
+	} {
+		if err := RateLimitWait(ctx, v.u, v.limit, v.burst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if l := limiter("strict.example.com"); l == nil || l.Limit() != 500 || l.Burst() != 5 {
+		t.Errorf(`RateLimitWait() limiter = %v`, l)
+	}
+}
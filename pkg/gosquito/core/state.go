@@ -0,0 +1,404 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/livelace/logrus"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+var (
+	stateBackend     = DEFAULT_STATE_BACKEND
+	stateBackendLock sync.RWMutex
+	stateRedis       *redis.Client
+	stateRoot        string
+)
+
+// StateStore is a key/value storage for plugins' state.
+// Every store is bound to a namespace (flow/plugin state directory).
+type StateStore interface {
+	Close() error
+	Delete(key string) error
+	Get(key string) ([]byte, bool, error)
+	Iterate(f func(key string, value []byte) error) error
+	Put(key string, value []byte, ttl time.Duration) error
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+type BadgerStateStore struct {
+	db *badger.DB
+}
+
+func (s *BadgerStateStore) Close() error {
+	// Garbage collection.
+	if err := s.db.RunValueLogGC(0.5); err != nil && !errors.Is(err, badger.ErrNoRewrite) && !errors.Is(err, badger.ErrRejected) {
+		_ = s.db.Close()
+		return err
+	}
+
+	return s.db.Close()
+}
+
+func (s *BadgerStateStore) Delete(key string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+func (s *BadgerStateStore) Get(key string) ([]byte, bool, error) {
+	var value []byte
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+
+	return value, err == nil, err
+}
+
+func (s *BadgerStateStore) Iterate(f func(key string, value []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = 100
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+
+			err := item.Value(func(value []byte) error {
+				return f(string(item.KeyCopy(nil)), value)
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BadgerStateStore) Put(key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		e := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			e = e.WithTTL(ttl)
+		}
+		return txn.SetEntry(e)
+	})
+}
+
+// PutMany writes all values within as few transactions as possible.
+func (s *BadgerStateStore) PutMany(data map[string][]byte, ttl time.Duration) error {
+	txn := s.db.NewTransaction(true)
+
+	for key, value := range data {
+		e := badger.NewEntry([]byte(key), value)
+		if ttl > 0 {
+			e = e.WithTTL(ttl)
+		}
+
+		if err := txn.SetEntry(e); errors.Is(err, badger.ErrTxnTooBig) {
+			if err := txn.Commit(); err != nil {
+				return err
+			}
+			txn = s.db.NewTransaction(true)
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		} else if err != nil {
+			txn.Discard()
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+func NewBadgerStateStore(database string) (*BadgerStateStore, error) {
+	// Disable logging.
+	opts := badger.DefaultOptions(database)
+	opts.Logger = nil
+	opts.SyncWrites = true
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BadgerStateStore{db: db}, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+type RedisStateStore struct {
+	client    *redis.Client
+	namespace string
+}
+
+func (s *RedisStateStore) Close() error {
+	// Client is shared across stores.
+	return nil
+}
+
+func (s *RedisStateStore) Delete(key string) error {
+	return s.client.Del(context.Background(), s.namespace+key).Err()
+}
+
+func (s *RedisStateStore) Get(key string) ([]byte, bool, error) {
+	value, err := s.client.Get(context.Background(), s.namespace+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+
+	return value, err == nil, err
+}
+
+func (s *RedisStateStore) Iterate(f func(key string, value []byte) error) error {
+	ctx := context.Background()
+	iter := s.client.Scan(ctx, 0, s.namespace+"*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		value, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := f(strings.TrimPrefix(iter.Val(), s.namespace), value); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+func (s *RedisStateStore) Put(key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(context.Background(), s.namespace+key, value, ttl).Err()
+}
+
+// NewRedisStateStore keeps keys under "gosquito:<namespace>:", namespace must be the same on all hosts.
+func NewRedisStateStore(client *redis.Client, namespace string) *RedisStateStore {
+	return &RedisStateStore{
+		client:    client,
+		namespace: fmt.Sprintf("%s:%s:", APP_NAME, namespace),
+	}
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+type SqliteStateStore struct {
+	db *sql.DB
+}
+
+func (s *SqliteStateStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SqliteStateStore) Delete(key string) error {
+	_, err := s.db.Exec("DELETE FROM state WHERE key = ?", key)
+	return err
+}
+
+func (s *SqliteStateStore) Get(key string) ([]byte, bool, error) {
+	var value []byte
+
+	err := s.db.QueryRow("SELECT value FROM state WHERE key = ? AND (expire = 0 OR expire > ?)",
+		key, time.Now().Unix()).Scan(&value)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+
+	return value, err == nil, err
+}
+
+func (s *SqliteStateStore) Iterate(f func(key string, value []byte) error) error {
+	rows, err := s.db.Query("SELECT key, value FROM state WHERE expire = 0 OR expire > ?", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+
+		if err := f(key, value); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *SqliteStateStore) Put(key string, value []byte, ttl time.Duration) error {
+	return s.PutMany(map[string][]byte{key: value}, ttl)
+}
+
+// PutMany writes all values within single transaction and removes expired values.
+func (s *SqliteStateStore) PutMany(data map[string][]byte, ttl time.Duration) error {
+	var expire int64
+	if ttl > 0 {
+		expire = time.Now().Add(ttl).Unix()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO state (key, value, expire) VALUES (?, ?, ?)")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for key, value := range data {
+		if _, err := stmt.Exec(key, value, expire); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM state WHERE expire > 0 AND expire <= ?", time.Now().Unix()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func NewSqliteStateStore(database string) (*SqliteStateStore, error) {
+	if err := CreateDirIfNotExist(database); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(database, DEFAULT_STATE_SQLITE_FILE)+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS state (key TEXT PRIMARY KEY, value BLOB, expire INTEGER)")
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SqliteStateStore{db: db}, nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+// GetRedisClient returns redis client (state_redis_* settings) shared across state stores and coordination.
+func GetRedisClient(config *viper.Viper) (*redis.Client, error) {
+	stateBackendLock.Lock()
+	defer stateBackendLock.Unlock()
+
+	return getRedisClient(config)
+}
+
+func getRedisClient(config *viper.Viper) (*redis.Client, error) {
+	if stateRedis != nil {
+		return stateRedis, nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.GetString(VIPER_DEFAULT_STATE_REDIS_ADDR),
+		DB:       config.GetInt(VIPER_DEFAULT_STATE_REDIS_DB),
+		Password: GetCredValue(config.GetString(VIPER_DEFAULT_STATE_REDIS_PASSWORD), nil),
+	})
+
+	// Client reconnects automatically, state operations fail until redis is available.
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		log.WithFields(log.Fields{
+			"addr":  config.GetString(VIPER_DEFAULT_STATE_REDIS_ADDR),
+			"error": err,
+		}).Warn(ERROR_STATE_REDIS_UNAVAILABLE)
+	}
+
+	stateRedis = client
+
+	return client, nil
+}
+
+// getStateNamespace returns database path relative to flow data directory ("<FLOW_NAME>/state"),
+// so hosts with different flow data paths share the same keys.
+func getStateNamespace(database string) string {
+	if rel, err := filepath.Rel(stateRoot, database); err == nil && stateRoot != "" && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+
+	return database
+}
+
+// OpenStateStore opens state store for namespace (usually flow/plugin state directory) with configured backend.
+func OpenStateStore(database string) (StateStore, error) {
+	stateBackendLock.RLock()
+	defer stateBackendLock.RUnlock()
+
+	switch stateBackend {
+	case "badger":
+		return NewBadgerStateStore(database)
+	case "redis":
+		return NewRedisStateStore(stateRedis, getStateNamespace(database)), nil
+	case "sqlite":
+		return NewSqliteStateStore(database)
+	}
+
+	return nil, fmt.Errorf(ERROR_STATE_BACKEND_UNKNOWN.Error(), stateBackend)
+}
+
+// SetStateBackend configures state backend for all plugins.
+func SetStateBackend(config *viper.Viper) error {
+	stateBackendLock.Lock()
+	defer stateBackendLock.Unlock()
+
+	backend := config.GetString(VIPER_DEFAULT_STATE_BACKEND)
+
+	switch backend {
+	case "badger", "sqlite":
+		break
+
+	case "redis":
+		if _, err := getRedisClient(config); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf(ERROR_STATE_BACKEND_UNKNOWN.Error(), backend)
+	}
+
+	stateBackend = backend
+	stateRoot = config.GetString(VIPER_DEFAULT_FLOW_DATA)
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateStore(t *testing.T) {
+	stores := map[string]func(string) (StateStore, error){
+		"badger": func(dir string) (StateStore, error) { return NewBadgerStateStore(dir) },
+		"sqlite": func(dir string) (StateStore, error) { return NewSqliteStateStore(dir) },
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			db, err := open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := db.Put("a", []byte("1"), 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := db.Put("b", []byte("2"), time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := db.Put("c", []byte("3"), 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := db.Delete("c"); err != nil {
+				t.Fatal(err)
+			}
+
+			iterate := func() map[string]string {
+				temp := make(map[string]string)
+
+				err := db.Iterate(func(key string, value []byte) error {
+					temp[key] = string(value)
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return temp
+			}
+
+			if got, want := iterate(), map[string]string{"a": "1", "b": "2"}; !reflect.DeepEqual(got, want) {
+				t.Errorf(`Iterate() = %v, want %v`, got, want)
+			}
+
+			if v, ok, err := db.Get("b"); err != nil || !ok || string(v) != "2" {
+				t.Errorf(`Get("b") = %q, %v, %v`, v, ok, err)
+			}
+
+			// Values with TTL are expired.
+			time.Sleep(2 * time.Second)
+
+			if v, ok, err := db.Get("b"); err != nil || ok {
+				t.Errorf(`Get("b") after TTL = %q, %v, %v`, v, ok, err)
+			}
+
+			if got, want := iterate(), map[string]string{"a": "1"}; !reflect.DeepEqual(got, want) {
+				t.Errorf(`Iterate() after TTL = %v, want %v`, got, want)
+			}
+
+			// Values are kept across reopening.
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if db, err = open(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if v, ok, err := db.Get("a"); err != nil || !ok || string(v) != "1" {
+				t.Errorf(`Get("a") after reopen = %q, %v, %v`, v, ok, err)
+			}
+		})
+	}
+}
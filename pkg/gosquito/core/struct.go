@@ -1,9 +1,11 @@
 package core
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,6 +23,13 @@ type InputPlugin interface {
 	Receive() ([]*Datum, error)
 }
 
+// CommitPlugin is implemented by input plugins which confirm received data after flow run.
+// Pending data of a single flow run is kept by plugin, so such flows can't run several instances in parallel.
+type CommitPlugin interface {
+	Commit() error
+	Rollback() error
+}
+
 type ProcessPlugin interface {
 	FlowLog(message interface{})
 
@@ -54,26 +63,43 @@ type PluginConfig struct {
 
 type Flow struct {
 	m        sync.Mutex
+	expired  []*Datum
+	health   *Health
 	instance int
+	lease    int64
+	trigger  int32
 
 	FlowUUID  uuid.UUID
 	FlowHash  string
 	FlowName  string
 	FlowRunID int64
 
-	FlowFile     string
-	FlowDataDir  string
-	FlowStateDir string
-	FlowTempDir  string
-
-	FlowCleanup  bool
-	FlowInstance int
-	FlowInterval int64
+	FlowFile       string
+	FlowArchiveDir string
+	FlowDataDir    string
+	FlowHealthDir  string
+	FlowStateDir   string
+	FlowTempDir    string
+
+	FlowArchive          bool
+	FlowArchiveRetention int64
+	FlowCleanup          bool
+	FlowInstance         int
+	FlowInterval         int64
+	FlowRateBurst        int
+	FlowRateLimit        float64
+	FlowSourceBackoff    int64
+	FlowSourceBackoffMax int64
+	FlowSourceHealth     bool
+	FlowSourceProbe      int64
+	FlowSourceQuarantine int
+	FlowVars             map[string]string
 
 	InputPlugin         InputPlugin
 	ProcessPlugins      map[int]ProcessPlugin
 	ProcessPluginsNames []string
 	OutputPlugin        OutputPlugin
+	ExpirePlugin        OutputPlugin
 
 	MetricError   int64
 	MetricExpire  int64
@@ -84,6 +110,37 @@ type Flow struct {
 	MetricTime    int64
 }
 
+// AddExpired queues notification about expired source.
+func (f *Flow) AddExpired(item *Datum) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.expired = append(f.expired, item)
+}
+
+func (f *Flow) GetArchive() *Archive {
+	return &Archive{Dir: f.FlowArchiveDir, Retention: time.Duration(f.FlowArchiveRetention) * time.Millisecond}
+}
+
+// GetHealth returns health tracker of flow input sources (shared across flow runs).
+func (f *Flow) GetHealth() *Health {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.health == nil {
+		f.health = &Health{
+			Dir:        f.FlowHealthDir,
+			Enable:     f.FlowSourceHealth,
+			Backoff:    time.Duration(f.FlowSourceBackoff) * time.Millisecond,
+			BackoffMax: time.Duration(f.FlowSourceBackoffMax) * time.Millisecond,
+			Probe:      time.Duration(f.FlowSourceProbe) * time.Millisecond,
+			Quarantine: f.FlowSourceQuarantine,
+		}
+	}
+
+	return f.health
+}
+
 func (f *Flow) GetInstance() int {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -91,10 +148,35 @@ func (f *Flow) GetInstance() int {
 	return f.instance
 }
 
+// GetLease returns lease generation, generation changes if flow lease was lost (coordination).
+func (f *Flow) GetLease() int64 {
+	return atomic.LoadInt64(&f.lease)
+}
+
 func (f *Flow) GetRunID() int64 {
 	return f.FlowRunID
 }
 
+// IsTriggered returns true (once) if flow was triggered to run before its interval.
+func (f *Flow) IsTriggered() bool {
+	return atomic.CompareAndSwapInt32(&f.trigger, 1, 0)
+}
+
+// PopExpired returns queued notifications about expired sources.
+func (f *Flow) PopExpired() []*Datum {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	temp := f.expired
+	f.expired = nil
+
+	return temp
+}
+
+func (f *Flow) RateLimitWait(ctx context.Context, url string) error {
+	return RateLimitWait(ctx, url, f.FlowRateLimit, f.FlowRateBurst)
+}
+
 func (f *Flow) ResetMetric() {
 	f.MetricError = 0
 	f.MetricExpire = 0
@@ -118,6 +200,15 @@ func (f *Flow) Lock() bool {
 	return false
 }
 
+// RevokeLease fences running flow instances, they mustn't send data after lease loss.
+func (f *Flow) RevokeLease() {
+	atomic.AddInt64(&f.lease, 1)
+}
+
+func (f *Flow) Trigger() {
+	atomic.StoreInt32(&f.trigger, 1)
+}
+
 func (f *Flow) Unlock() bool {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -141,6 +232,8 @@ type FlowUnmarshal struct {
 
 		Params map[interface{}]interface{} `yaml:"params"`
 
+		Vars map[interface{}]interface{} `yaml:"vars"`
+
 		Input struct {
 			Plugin string                      `yaml:"plugin"`
 			Params map[interface{}]interface{} `yaml:"params"`
@@ -152,6 +245,11 @@ type FlowUnmarshal struct {
 			Plugin string                      `yaml:"plugin"`
 			Params map[interface{}]interface{} `yaml:"params"`
 		}
+
+		Expire struct {
+			Plugin string                      `yaml:"plugin"`
+			Params map[interface{}]interface{} `yaml:"params"`
+		}
 	}
 }
 
@@ -277,17 +375,75 @@ type Data struct {
 	TEXTZ string
 }
 
+type Expire struct {
+	AGE      string
+	INTERVAL string
+	LAST     string
+}
+
 type Iter struct {
 	INDEX int
 	VALUE string
 }
 
+type Amqp struct {
+	CONTENTTYPE string
+	EXCHANGE    string
+	HEADERS     []string
+	MESSAGEID   string
+	PAYLOAD     string
+	REDELIVERED string
+	ROUTINGKEY  string
+}
+
+type Imap struct {
+	ATTACHMENTS []string
+	CC          []string
+	FLAGS       []string
+	FROM        string
+	HTML        string
+	MAILBOX     string
+	MESSAGEID   string
+	SUBJECT     string
+	TEXT        string
+	TO          []string
+	UID         string
+}
+
 type Io struct {
+	MIME  string
 	MTIME string
+	PATH  string
+	SIZE  string
 	SPLIT []string
 	TEXT  string
 }
 
+type Kafka struct {
+	HEADERS   []string
+	KEY       string
+	OFFSET    string
+	PARTITION string
+	TIMESTAMP string
+}
+
+type Mqtt struct {
+	MESSAGEID string
+	PAYLOAD   string
+	QOS       string
+	RETAINED  string
+	TOPIC     string
+}
+
+type Nats struct {
+	HEADERS  []string
+	PAYLOAD  string
+	REPLY    string
+	SEQUENCE string
+	STREAM   string
+	SUBJECT  string
+}
+
 type Resty struct {
 	BODY       string
 	PROTO      string
@@ -296,12 +452,28 @@ type Resty struct {
 }
 
 type Rss struct {
+	AUTHORS     []string
 	CATEGORIES  []string
 	CONTENT     string
 	DESCRIPTION string
+	ENCLOSURES  []string
+	FEEDIMAGE   string
+	FEEDLINK    string
+	FEEDTITLE   string
 	GUID        string
+	IMAGE       string
 	LINK        string
+	MEDIA       []string
+	PUBLISHED   string
 	TITLE       string
+	UPDATED     string
+}
+
+type Sql struct {
+	COLUMNS []string
+	CURSOR  string
+	ROW     string
+	VALUES  []string
 }
 
 type Telegram struct {
@@ -365,6 +537,15 @@ type Twitter struct {
 	URLS  []string
 }
 
+type Webhook struct {
+	BODY    string
+	HEADERS []string
+	METHOD  string
+	PATH    string
+	QUERY   string
+	REMOTE  string
+}
+
 type Datum struct {
 	FLOW        string
 	PLUGIN      string
@@ -380,14 +561,23 @@ type Datum struct {
 	TIMEZONEC   *time.Location
 	UUID        uuid.UUID
 
-	DATA Data
-	ITER Iter
+	DATA   Data
+	EXPIRE Expire
+	ITER   Iter
+	VARS   map[string]string
 
+	AMQP     Amqp
+	IMAP     Imap
 	IO       Io
+	KAFKA    Kafka
+	MQTT     Mqtt
+	NATS     Nats
 	RESTY    Resty
 	RSS      Rss
+	SQL      Sql
 	TELEGRAM Telegram
 	TWITTER  Twitter
+	WEBHOOK  Webhook
 
 	WARNINGS []string
 }
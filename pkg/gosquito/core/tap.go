@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	tapCount       int32
+	tapSubscribers = make(map[*TapSubscriber]bool)
+	tapLock        sync.RWMutex
+)
+
+// TapEvent describes datum passing through flow chain (debugging).
+type TapEvent struct {
+	Time      time.Time              `json:"time"`
+	Flow      string                 `json:"flow"`
+	Run       int64                  `json:"run"`
+	Stage     string                 `json:"stage"`
+	Plugin    string                 `json:"plugin"`
+	PluginID  int                    `json:"id"`
+	Direction string                 `json:"direction"`
+	Datum     map[string]interface{} `json:"datum"`
+}
+
+// TapSubscriber receives encoded events of single flow.
+// Events are dropped if subscriber doesn't keep up.
+type TapSubscriber struct {
+	C       chan []byte
+	Dropped int64
+
+	Fields   []string
+	Flow     string
+	PluginID int
+	Sample   float64
+	Stage    string
+}
+
+func (s *TapSubscriber) GetDropped() int64 {
+	return atomic.LoadInt64(&s.Dropped)
+}
+
+func (s *TapSubscriber) match(stage string, pluginID int) bool {
+	if s.Stage != "" && s.Stage != stage {
+		return false
+	}
+
+	if s.Stage == "process" && s.PluginID >= 0 && s.PluginID != pluginID {
+		return false
+	}
+
+	return true
+}
+
+// TapEnabled returns true if flow has subscribers (fast path for running flows).
+func TapEnabled(flow string) bool {
+	if atomic.LoadInt32(&tapCount) == 0 {
+		return false
+	}
+
+	tapLock.RLock()
+	defer tapLock.RUnlock()
+
+	for s := range tapSubscribers {
+		if s.Flow == flow {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TapPublish sends datums to flow subscribers. Datums are encoded immediately,
+// because plugins modify datums in place.
+func TapPublish(flow *Flow, stage string, plugin string, pluginID int, direction string, data []*Datum) {
+	tapLock.RLock()
+	defer tapLock.RUnlock()
+
+	for s := range tapSubscribers {
+		if s.Flow != flow.FlowName || !s.match(stage, pluginID) {
+			continue
+		}
+
+		for _, item := range data {
+			if s.Sample < 1 && rand.Float64() >= s.Sample {
+				continue
+			}
+
+			event := TapEvent{
+				Time:      time.Now().UTC(),
+				Flow:      flow.FlowName,
+				Run:       flow.GetRunID(),
+				Stage:     stage,
+				Plugin:    plugin,
+				PluginID:  pluginID,
+				Direction: direction,
+			}
+
+			if len(s.Fields) > 0 {
+				event.Datum = make(map[string]interface{}, len(s.Fields))
+
+				for _, field := range s.Fields {
+					if v, err := ReflectDatumField(item, field); err == nil {
+						event.Datum[strings.ToUpper(field)] = v.Interface()
+					}
+				}
+			} else {
+				event.Datum, _ = PruneDatum(item)
+			}
+
+			b, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case s.C <- b:
+			default:
+				atomic.AddInt64(&s.Dropped, 1)
+			}
+		}
+	}
+}
+
+func TapSubscribe(s *TapSubscriber, size int) {
+	tapLock.Lock()
+	defer tapLock.Unlock()
+
+	s.C = make(chan []byte, size)
+	tapSubscribers[s] = true
+
+	atomic.AddInt32(&tapCount, 1)
+}
+
+func TapUnsubscribe(s *TapSubscriber) {
+	tapLock.Lock()
+	defer tapLock.Unlock()
+
+	if tapSubscribers[s] {
+		delete(tapSubscribers, s)
+		atomic.AddInt32(&tapCount, -1)
+	}
+}
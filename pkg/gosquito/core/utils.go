@@ -7,7 +7,6 @@ import (
 	b64 "encoding/base64"
 	"encoding/gob"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -23,11 +22,8 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
-	"github.com/dgraph-io/badger/v3"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/google/renameio"
-	vault "github.com/hashicorp/vault/api"
-	auth "github.com/hashicorp/vault/api/auth/approle"
 	"github.com/itchyny/gojq"
 	log "github.com/livelace/logrus"
 	"github.com/spf13/viper"
@@ -39,6 +35,7 @@ var (
 		"ToEscape":   JsonEscape,
 		"ToBase64":   Base64Encode,
 		"ToLower":    strings.ToLower,
+		"ToSha1":     Sha1Encode,
 		"ToUpper":    strings.ToUpper,
 	}
 )
@@ -414,35 +411,6 @@ func GenUID() string {
 	return string(b)
 }
 
-func GetCredValue(cred string, vault *vault.Client) string {
-	// Get from environment variable.
-	if strings.Contains(cred, "env://") {
-		return GetVarFromEnv(cred)
-	}
-
-	// Get from vault secret.
-	if c := strings.Split(cred, "vault://"); len(c) > 1 && vault != nil {
-		if s := strings.Split(c[1], ","); len(s) > 1 {
-			secretPath := s[0]
-			secretKey := s[1]
-
-			secret, err := vault.Logical().Read(secretPath)
-			if err != nil {
-				return ""
-			}
-
-			value, ok := secret.Data[secretKey].(string)
-			if !ok {
-				return ""
-			}
-
-			return value
-		}
-	}
-
-	return cred
-}
-
 func GetDatumFieldType(field interface{}) (reflect.Kind, error) {
 	if f, ok := field.(string); ok {
 		rv, err := ReflectDatumField(&Datum{}, f)
@@ -478,6 +446,27 @@ func GetFileNameAndExtension(file string) (string, string) {
 	return fileName, fileExtension
 }
 
+func GetFlowVars(m map[interface{}]interface{}) (map[string]string, error) {
+	temp := make(map[string]string, len(m))
+
+	// Flow variables may refer only to environment variables.
+	for k, v := range m {
+		ks, ok := k.(string)
+		if !ok {
+			return temp, ERROR_PARAM_KEY_MUST_STRING
+		}
+
+		value, err := InterpolateString(fmt.Sprintf("%v", v), nil)
+		if err != nil {
+			return temp, err
+		}
+
+		temp[ks] = value
+	}
+
+	return temp, nil
+}
+
 func GetLinesFromFile(file string) ([]string, error) {
 	s, err := GetStringFromFile(file)
 	if err != nil {
@@ -506,68 +495,100 @@ func GetVarFromEnv(v string) string {
 	return v
 }
 
-func GetVault(m map[string]interface{}) (*vault.Client, error) {
-	if len(m) > 0 {
-		var address string
-		var app_role string
-		var app_secret string
+func HashString(s *string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(*s)))
+}
 
-		if v, b := IsString(m["address"]); b {
-			if address = GetVarFromEnv(v); address == "" {
-				return nil, fmt.Errorf("vault address env not set: %v", v)
-			}
-		} else {
-			return nil, fmt.Errorf("vault address must be set: %v", m["address"])
+func InterpolateString(s string, vars map[string]string) (string, error) {
+	var b strings.Builder
+
+	// Supported forms:
+	// 1. ${NAME} - flow variable or environment variable, empty if not set.
+	// 2. ${NAME:-default} - default value if variable is not set or empty.
+	// 3. ${NAME:?message} - variable is required, fail with message otherwise.
+	// 4. $${NAME} - escaped, kept as "${NAME}".
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 2
+			continue
 		}
 
-		if v, b := IsString(m["app_role"]); b {
-			if app_role = GetVarFromEnv(v); app_role == "" {
-				return nil, fmt.Errorf("vault app_role env not set: %v", v)
-			}
-		} else {
-			return nil, fmt.Errorf("vault app_role must be set: %v", m["app_role"])
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			continue
 		}
 
-		if v, b := IsString(m["app_secret"]); b {
-			if app_secret = GetVarFromEnv(v); app_secret == "" {
-				return nil, fmt.Errorf("vault app_secret env not set: %v", v)
-			}
-		} else {
-			return nil, fmt.Errorf("vault app_secret must be set: %v", m["app_secret"])
+		end := strings.IndexByte(s[i:], '}')
+		if end == -1 {
+			return s, fmt.Errorf(ERROR_FLOW_VAR_UNCLOSED.Error(), s[i:])
 		}
 
-		vaultConfig := vault.DefaultConfig()
-		vaultConfig.Address = address
+		expr := s[i+2 : i+end]
+		name, op, arg := expr, "", ""
 
-		vaultClient, err := vault.NewClient(vaultConfig)
-		if err != nil {
-			return nil, fmt.Errorf("cannot initialize vault client: %v", err)
+		if n := strings.Index(expr, ":"); n != -1 && n+1 < len(expr) && (expr[n+1] == '-' || expr[n+1] == '?') {
+			name, op, arg = expr[:n], expr[n:n+2], expr[n+2:]
 		}
 
-		appRoleAuth, err := auth.NewAppRoleAuth(
-			app_role,
-			&auth.SecretID{FromString: app_secret},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("cannot initialize app role auth: %v", err)
+		value, ok := vars[name]
+		if !ok {
+			value = os.Getenv(name)
 		}
 
-		authInfo, err := vaultClient.Auth().Login(context.TODO(), appRoleAuth)
-		if err != nil {
-			return nil, fmt.Errorf("cannot login with app role: %v", err)
-		}
-		if authInfo == nil {
-			return nil, fmt.Errorf("no auth info for app role")
+		if value == "" {
+			switch op {
+			case ":-":
+				value = arg
+			case ":?":
+				if arg == "" {
+					arg = name
+				}
+				return s, fmt.Errorf(ERROR_FLOW_VAR_REQUIRED.Error(), arg)
+			}
 		}
 
-		return vaultClient, nil
+		b.WriteString(value)
+		i += end
 	}
 
-	return nil, nil
+	return b.String(), nil
 }
 
-func HashString(s *string) string {
-	return fmt.Sprintf("%x", sha1.Sum([]byte(*s)))
+func InterpolateValue(i interface{}, vars map[string]string) (interface{}, error) {
+	switch v := i.(type) {
+	case string:
+		return InterpolateString(v, vars)
+
+	case []interface{}:
+		for k, e := range v {
+			r, err := InterpolateValue(e, vars)
+			if err != nil {
+				return i, err
+			}
+			v[k] = r
+		}
+
+	case map[interface{}]interface{}:
+		for k, e := range v {
+			r, err := InterpolateValue(e, vars)
+			if err != nil {
+				return i, err
+			}
+			v[k] = r
+		}
+
+	case map[string]interface{}:
+		for k, e := range v {
+			r, err := InterpolateValue(e, vars)
+			if err != nil {
+				return i, err
+			}
+			v[k] = r
+		}
+	}
+
+	return i, nil
 }
 
 func IntervalToMilliseconds(s string) (int64, error) {
@@ -1122,44 +1143,20 @@ func PluginLoadData(database string, data interface{}) error {
 }
 
 func PluginLoadState(database string, data *map[string]time.Time) error {
-	// Disable logging.
-	opts := badger.DefaultOptions(database)
-	opts.Logger = nil
-
 	// Open database.
-	db, err := badger.Open(opts)
+	db, err := OpenStateStore(database)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
 	// Read database.
-	err = db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 100
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
+	return db.Iterate(func(signature string, value []byte) error {
+		timestamp, err := time.Parse(time.RFC3339, string(value))
+		(*data)[signature] = timestamp
 
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-
-			err := item.Value(func(value []byte) error {
-				signature := fmt.Sprintf("%s", item.Key())
-				timestamp, err := time.Parse(time.RFC3339, fmt.Sprintf("%s", value))
-				(*data)[signature] = timestamp
-
-				return err
-			})
-
-			if err != nil {
-				return err
-			}
-		}
-		return nil
+		return err
 	})
-
-	return err
 }
 
 func PluginSaveData(database string, data interface{}) error {
@@ -1180,39 +1177,87 @@ func PluginSaveData(database string, data interface{}) error {
 }
 
 func PluginSaveState(database string, data *map[string]time.Time, ttl time.Duration) error {
-	// Disable logging.
-	opts := badger.DefaultOptions(database)
-	opts.Logger = nil
-	opts.SyncWrites = true
-
 	// Open database.
-	db, err := badger.Open(opts)
+	db, err := OpenStateStore(database)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
 	// Save data.
-	txn := db.NewTransaction(true)
+	values := make(map[string][]byte, len(*data))
 	for signature, timestamp := range *data {
-		e := badger.NewEntry([]byte(signature), []byte(timestamp.Format(time.RFC3339))).WithTTL(ttl)
-		if err := txn.SetEntry(e); errors.Is(err, badger.ErrTxnTooBig) {
-			_ = txn.Commit()
-			txn = db.NewTransaction(true)
-			_ = txn.Set([]byte(signature), []byte(timestamp.Format(time.RFC3339)))
+		values[signature] = []byte(timestamp.Format(time.RFC3339))
+	}
+
+	if bulk, ok := db.(interface {
+		PutMany(map[string][]byte, time.Duration) error
+	}); ok {
+		err = bulk.PutMany(values, ttl)
+	} else {
+		for signature, value := range values {
+			if err = db.Put(signature, value, ttl); err != nil {
+				break
+			}
 		}
 	}
-	if err = txn.Commit(); err != nil {
+
+	if err != nil {
+		_ = db.Close()
 		return err
 	}
 
-	// Garbage collection.
-	err = db.RunValueLogGC(0.5)
-	if errors.Is(err, badger.ErrNoRewrite) {
-		return nil
+	return db.Close()
+}
+
+// PruneDatum converts datum into map without empty fields (human-readable representation).
+func PruneDatum(item *Datum) (map[string]interface{}, error) {
+	temp := make(map[string]interface{}, 0)
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		return temp, err
 	}
 
-	return err
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return temp, err
+	}
+
+	pruneValue(temp)
+
+	return temp, nil
+}
+
+func pruneValue(i interface{}) interface{} {
+	switch v := i.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if p := pruneValue(e); p == nil {
+				delete(v, k)
+			} else {
+				v[k] = p
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+
+	case float64:
+		if v == 0 {
+			return nil
+		}
+
+	case string:
+		if v == "" || v == (time.Time{}).Format(time.RFC3339) {
+			return nil
+		}
+	}
+
+	return i
 }
 
 func ReflectDatumField(item *Datum, i interface{}) (reflect.Value, error) {
@@ -1299,6 +1344,10 @@ func SizeToBytes(s string) (int64, error) {
 	}
 }
 
+func Sha1Encode(s string) string {
+	return HashString(&s)
+}
+
 func SliceStringToUpper(s *[]string) {
 	for i, v := range *s {
 		(*s)[i] = strings.ToUpper(v)
@@ -11,3 +11,11 @@ func TestIsBool(t *testing.T) {
 		t.Error(`IsBool("true") = false`)
 	}
 }
+
+func TestInterpolateString(t *testing.T) {
+	s, err := InterpolateString("${A}-${B:-b}-$${C}", map[string]string{"A": "a"})
+
+	if err != nil || s != "a-b-${C}" {
+		t.Errorf(`InterpolateString() = %q, %v`, s, err)
+	}
+}
@@ -4,10 +4,17 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
+	imapIn "github.com/livelace/gosquito/pkg/gosquito/plugins/input/imap"
 	rssIn "github.com/livelace/gosquito/pkg/gosquito/plugins/input/rss"
+	webhookIn "github.com/livelace/gosquito/pkg/gosquito/plugins/input/webhook"
+	amqpMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/amqp"
+	flowMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/flow"
 	ioMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/io"
 	kafkaMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/kafka"
+	mqttMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/mqtt"
+	natsMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/nats"
 	restyMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/resty"
+	sqlMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/sql"
 	telegramMulti "github.com/livelace/gosquito/pkg/gosquito/plugins/multi/telegram"
 	mattermostOut "github.com/livelace/gosquito/pkg/gosquito/plugins/output/mattermost"
 	slackOut "github.com/livelace/gosquito/pkg/gosquito/plugins/output/slack"
@@ -39,6 +46,76 @@ import (
 	"time"
 )
 
+// interpolateFlow interpolates variables into flow string values, only flows with "vars" section are interpolated
+// (literal "${" in flows without variables, e.g. regexp, jq, templates, is kept as is).
+func interpolateFlow(flowBody *core.FlowUnmarshal, vars map[string]string) error {
+	var err error
+
+	if flowBody.Flow.Vars == nil {
+		return nil
+	}
+
+	if flowBody.Flow.Name, err = core.InterpolateString(flowBody.Flow.Name, vars); err != nil {
+		return err
+	}
+
+	if _, err = core.InterpolateValue(flowBody.Flow.Params, vars); err != nil {
+		return err
+	}
+
+	if _, err = core.InterpolateValue(flowBody.Flow.Input.Params, vars); err != nil {
+		return err
+	}
+
+	for _, item := range flowBody.Flow.Process {
+		if _, err = core.InterpolateValue(item, vars); err != nil {
+			return err
+		}
+	}
+
+	if _, err = core.InterpolateValue(flowBody.Flow.Output.Params, vars); err != nil {
+		return err
+	}
+
+	if _, err = core.InterpolateValue(flowBody.Flow.Expire.Params, vars); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// initOutputPlugin initializes "output" plugin, "expire" plugin of flow is "output" plugin too.
+func initOutputPlugin(plugin string, pluginConfig *core.PluginConfig) (core.OutputPlugin, error) {
+	switch plugin {
+	case "amqp":
+		return amqpMulti.Init(pluginConfig)
+	case "flow":
+		return flowMulti.Init(pluginConfig)
+	case "io":
+		return ioMulti.Init(pluginConfig)
+	case "kafka":
+		return kafkaMulti.Init(pluginConfig)
+	case "mqtt":
+		return mqttMulti.Init(pluginConfig)
+	case "nats":
+		return natsMulti.Init(pluginConfig)
+	case "mattermost":
+		return mattermostOut.Init(pluginConfig)
+	case "resty":
+		return restyMulti.Init(pluginConfig)
+	case "slack":
+		return slackOut.Init(pluginConfig)
+	case "smtp":
+		return smtpOut.Init(pluginConfig)
+	case "sql":
+		return sqlMulti.Init(pluginConfig)
+	case "telegram":
+		return telegramMulti.Init(pluginConfig)
+	}
+
+	return nil, fmt.Errorf("%s: %s", core.ERROR_PLUGIN_UNKNOWN, plugin)
+}
+
 func readFlow(dir string) ([]string, error) {
 	temp := make([]string, 0)
 
@@ -64,7 +141,19 @@ func readFlow(dir string) ([]string, error) {
 	return temp, err
 }
 
+// flowPlugins selects plugins initialized during flow loading.
+// Replayed and tested flows don't need input plugins (listeners, consumers etc.) and real output plugins.
+type flowPlugins struct {
+	Input  bool
+	Output bool
+	Expire bool
+}
+
 func getFlow(appConfig *viper.Viper) []*core.Flow {
+	return loadFlow(appConfig, flowPlugins{Input: true, Output: true, Expire: true})
+}
+
+func loadFlow(appConfig *viper.Viper, plugins flowPlugins) []*core.Flow {
 	var flows []*core.Flow
 
 	// -----------------------------------------------------------------------------------------------------------------
@@ -129,9 +218,18 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 		var flowName string
 		var flowRunID = int64(0)
 
+		var flowArchive bool
+		var flowArchiveRetention int64
 		var flowCleanup bool
 		var flowInstance int
 		var flowInterval int64
+		var flowRateBurst int
+		var flowRateLimit float64
+		var flowSourceBackoff int64
+		var flowSourceBackoffMax int64
+		var flowSourceHealth bool
+		var flowSourceProbe int64
+		var flowSourceQuarantine int
 
 		var flowParams map[string]interface{}
 
@@ -157,6 +255,19 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			continue
 		}
 
+		// Skip flow if we cannot resolve flow variables.
+		// Variables must be resolved before plugins initialization.
+		flowVars, err := core.GetFlowVars(flowBody.Flow.Vars)
+		if err != nil {
+			logFlowFileError(err)
+			continue
+		}
+
+		if err := interpolateFlow(&flowBody, flowVars); err != nil {
+			logFlowFileError(err)
+			continue
+		}
+
 		// Flow name must be compatible.
 		if !core.IsFlowNameValid(flowBody.Flow.Name) {
 			logFlowFileError(fmt.Errorf(core.ERROR_FLOW_NAME_COMPAT.Error(), flowBody.Flow.Name))
@@ -229,9 +340,18 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 
 		// Every flow has these parameters.
 		flowParamsAvailable := map[string]int{
-			"cleanup":  -1,
-			"instance": -1,
-			"interval": -1,
+			"archive":            -1,
+			"archive_retention":  -1,
+			"cleanup":            -1,
+			"instance":           -1,
+			"interval":           -1,
+			"rate_burst":         -1,
+			"rate_limit":         -1,
+			"source_backoff":     -1,
+			"source_backoff_max": -1,
+			"source_health":      -1,
+			"source_probe":       -1,
+			"source_quarantine":  -1,
 		}
 
 		// Flow parameters may be not specified (use defaults).
@@ -248,6 +368,24 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			continue
 		}
 
+		// Set flow archive.
+		if v, b := core.IsBool(flowParams["archive"]); b {
+			flowArchive = v
+			logFlowParam("archive", v)
+		} else {
+			flowArchive = appConfig.GetBool(core.VIPER_DEFAULT_FLOW_ARCHIVE)
+			logFlowParam("archive", flowArchive)
+		}
+
+		// Set flow archive retention.
+		if v, b := core.IsInterval(flowParams["archive_retention"]); b {
+			flowArchiveRetention = v
+			logFlowParam("archive_retention", v)
+		} else {
+			flowArchiveRetention, _ = core.IsInterval(appConfig.GetString(core.VIPER_DEFAULT_FLOW_ARCHIVE_RETENTION))
+			logFlowParam("archive_retention", flowArchiveRetention)
+		}
+
 		// Set flow cleanup.
 		if v, b := core.IsBool(flowParams["cleanup"]); b {
 			flowCleanup = v
@@ -275,6 +413,69 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			logFlowParam("interval", flowInterval)
 		}
 
+		// Set flow rate limit.
+		if v, b := core.IsFloat(flowParams["rate_limit"], true); b {
+			flowRateLimit = float64(v)
+			logFlowParam("rate_limit", v)
+		} else {
+			flowRateLimit = appConfig.GetFloat64(core.VIPER_DEFAULT_RATE_LIMIT)
+			logFlowParam("rate_limit", flowRateLimit)
+		}
+
+		// Set flow rate burst.
+		if v, b := core.IsInt(flowParams["rate_burst"]); b {
+			flowRateBurst = v
+			logFlowParam("rate_burst", v)
+		} else {
+			flowRateBurst = appConfig.GetInt(core.VIPER_DEFAULT_RATE_BURST)
+			logFlowParam("rate_burst", flowRateBurst)
+		}
+
+		// Set flow sources health.
+		if v, b := core.IsBool(flowParams["source_health"]); b {
+			flowSourceHealth = v
+			logFlowParam("source_health", v)
+		} else {
+			flowSourceHealth = appConfig.GetBool(core.VIPER_DEFAULT_FLOW_SOURCE_HEALTH)
+			logFlowParam("source_health", flowSourceHealth)
+		}
+
+		// Set flow sources backoff.
+		if v, b := core.IsInterval(flowParams["source_backoff"]); b {
+			flowSourceBackoff = v
+			logFlowParam("source_backoff", v)
+		} else {
+			flowSourceBackoff, _ = core.IsInterval(appConfig.GetString(core.VIPER_DEFAULT_FLOW_SOURCE_BACKOFF))
+			logFlowParam("source_backoff", flowSourceBackoff)
+		}
+
+		// Set flow sources maximum backoff.
+		if v, b := core.IsInterval(flowParams["source_backoff_max"]); b {
+			flowSourceBackoffMax = v
+			logFlowParam("source_backoff_max", v)
+		} else {
+			flowSourceBackoffMax, _ = core.IsInterval(appConfig.GetString(core.VIPER_DEFAULT_FLOW_SOURCE_BACKOFF_MAX))
+			logFlowParam("source_backoff_max", flowSourceBackoffMax)
+		}
+
+		// Set flow sources quarantine probe.
+		if v, b := core.IsInterval(flowParams["source_probe"]); b {
+			flowSourceProbe = v
+			logFlowParam("source_probe", v)
+		} else {
+			flowSourceProbe, _ = core.IsInterval(appConfig.GetString(core.VIPER_DEFAULT_FLOW_SOURCE_PROBE))
+			logFlowParam("source_probe", flowSourceProbe)
+		}
+
+		// Set flow sources quarantine threshold.
+		if v, b := core.IsInt(flowParams["source_quarantine"]); b {
+			flowSourceQuarantine = v
+			logFlowParam("source_quarantine", v)
+		} else {
+			flowSourceQuarantine = appConfig.GetInt(core.VIPER_DEFAULT_FLOW_SOURCE_QUARANTINE)
+			logFlowParam("source_quarantine", flowSourceQuarantine)
+		}
+
 		// ---------------------------------------------------------------------------------------------------------
 		// Create flow.
 
@@ -284,14 +485,26 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			FlowName:  flowName,
 			FlowRunID: flowRunID,
 
-			FlowFile:     fileName,
-			FlowDataDir:  filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_DATA_DIR),
-			FlowStateDir: filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_STATE_DIR),
-			FlowTempDir:  filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_TEMP_DIR),
-
-			FlowCleanup:  flowCleanup,
-			FlowInstance: flowInstance,
-			FlowInterval: flowInterval,
+			FlowFile:       fileName,
+			FlowArchiveDir: filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_ARCHIVE_DIR),
+			FlowDataDir:    filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_DATA_DIR),
+			FlowHealthDir:  filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_HEALTH_DIR),
+			FlowStateDir:   filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_STATE_DIR),
+			FlowTempDir:    filepath.Join(appConfig.GetString(core.VIPER_DEFAULT_FLOW_DATA), flowName, core.DEFAULT_TEMP_DIR),
+
+			FlowArchive:          flowArchive,
+			FlowArchiveRetention: flowArchiveRetention,
+			FlowCleanup:          flowCleanup,
+			FlowInstance:         flowInstance,
+			FlowInterval:         flowInterval,
+			FlowRateBurst:        flowRateBurst,
+			FlowRateLimit:        flowRateLimit,
+			FlowSourceBackoff:    flowSourceBackoff,
+			FlowSourceBackoffMax: flowSourceBackoffMax,
+			FlowSourceHealth:     flowSourceHealth,
+			FlowSourceProbe:      flowSourceProbe,
+			FlowSourceQuarantine: flowSourceQuarantine,
+			FlowVars:             flowVars,
 		}
 
 		// ---------------------------------------------------------------------------------------------------------
@@ -314,21 +527,42 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 		}
 
 		// Available "input" plugins.
-		switch flowBody.Flow.Input.Plugin {
-		case "io":
-			inputPlugin, err = ioMulti.Init(&inputPluginConfig)
-		case "kafka":
-			inputPlugin, err = kafkaMulti.Init(&inputPluginConfig)
-		case "resty":
-			inputPlugin, err = restyMulti.Init(&inputPluginConfig)
-		case "rss":
-			inputPlugin, err = rssIn.Init(&inputPluginConfig)
-		case "telegram":
-			inputPlugin, err = telegramMulti.Init(&inputPluginConfig)
-		//case "twitter":
-		//	inputPlugin, err = twitterIn.Init(&inputPluginConfig)
-		default:
-			err = core.ERROR_PLUGIN_UNKNOWN
+		if plugins.Input {
+			switch flowBody.Flow.Input.Plugin {
+			case "amqp":
+				inputPlugin, err = amqpMulti.Init(&inputPluginConfig)
+			case "flow":
+				inputPlugin, err = flowMulti.Init(&inputPluginConfig)
+			case "imap":
+				inputPlugin, err = imapIn.Init(&inputPluginConfig)
+			case "io":
+				inputPlugin, err = ioMulti.Init(&inputPluginConfig)
+			case "kafka":
+				inputPlugin, err = kafkaMulti.Init(&inputPluginConfig)
+			case "mqtt":
+				inputPlugin, err = mqttMulti.Init(&inputPluginConfig)
+			case "nats":
+				inputPlugin, err = natsMulti.Init(&inputPluginConfig)
+			case "resty":
+				inputPlugin, err = restyMulti.Init(&inputPluginConfig)
+			case "rss":
+				inputPlugin, err = rssIn.Init(&inputPluginConfig)
+			case "sql":
+				inputPlugin, err = sqlMulti.Init(&inputPluginConfig)
+			case "telegram":
+				inputPlugin, err = telegramMulti.Init(&inputPluginConfig)
+			//case "twitter":
+			//	inputPlugin, err = twitterIn.Init(&inputPluginConfig)
+			case "webhook":
+				inputPlugin, err = webhookIn.Init(&inputPluginConfig)
+			default:
+				err = core.ERROR_PLUGIN_UNKNOWN
+			}
+		}
+
+		// Confirmed input plugins keep pending data of a single flow run (see core.CommitPlugin).
+		if _, ok := inputPlugin.(core.CommitPlugin); ok && flowInstance > 1 {
+			err = fmt.Errorf(core.ERROR_FLOW_INSTANCE_COMMIT.Error(), flowInstance)
 		}
 
 		// Skip flow if we cannot initialize "input" plugin.
@@ -448,7 +682,7 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 		// Map "output" plugin.
 
 		outputParams, b := core.IsMapWithStringAsKey(flowBody.Flow.Output.Params)
-		if b {
+		if b && plugins.Output {
 			// Assemble plugin configuration.
 			outputPluginConfig := core.PluginConfig{
 				AppConfig:    appConfig,
@@ -458,22 +692,7 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			}
 
 			// Available "output" plugins.
-			switch flowBody.Flow.Output.Plugin {
-			case "kafka":
-				outputPlugin, err = kafkaMulti.Init(&outputPluginConfig)
-			case "mattermost":
-				outputPlugin, err = mattermostOut.Init(&outputPluginConfig)
-			case "resty":
-				outputPlugin, err = restyMulti.Init(&outputPluginConfig)
-			case "slack":
-				outputPlugin, err = slackOut.Init(&outputPluginConfig)
-			case "smtp":
-				outputPlugin, err = smtpOut.Init(&outputPluginConfig)
-			case "telegram":
-				outputPlugin, err = telegramMulti.Init(&outputPluginConfig)
-			default:
-				err = fmt.Errorf("%s: %s", core.ERROR_PLUGIN_UNKNOWN, flowBody.Flow.Output.Plugin)
-			}
+			outputPlugin, err = initOutputPlugin(flowBody.Flow.Output.Plugin, &outputPluginConfig)
 
 			// Skip flow if we cannot initialize "output" plugin.
 			if err != nil {
@@ -483,6 +702,32 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 			}
 		}
 
+		// ---------------------------------------------------------------------------------------------------------
+		// Map "expire" plugin.
+
+		if flowBody.Flow.Expire.Plugin != "" && plugins.Expire {
+			// Plugin parameters may be not specified (use defaults).
+			expireParams, _ := core.IsMapWithStringAsKey(flowBody.Flow.Expire.Params)
+
+			// Assemble plugin configuration.
+			expirePluginConfig := core.PluginConfig{
+				AppConfig:    appConfig,
+				Flow:         flow,
+				PluginParams: &expireParams,
+				PluginType:   "output",
+			}
+
+			// Any "output" plugin might notify about expired sources.
+			flow.ExpirePlugin, err = initOutputPlugin(flowBody.Flow.Expire.Plugin, &expirePluginConfig)
+
+			// Skip flow if we cannot initialize "expire" plugin.
+			if err != nil {
+				logInputOutputPluginError(flowBody.Flow.Expire.Plugin, "expire", core.LOG_PLUGIN_INIT, err)
+				logFlowInvalid(flowName)
+				continue
+			}
+		}
+
 		// ---------------------------------------------------------------------------------------------------------
 		// Finish flow creation.
 
@@ -492,6 +737,7 @@ func getFlow(appConfig *viper.Viper) []*core.Flow {
 		flow.OutputPlugin = outputPlugin
 
 		flows = append(flows, flow)
+		core.RegisterFlow(flow)
 
 		logFlowValid(flowName)
 	}
@@ -503,9 +749,11 @@ func runFlow(flow *core.Flow) {
 	// -----------------------------------------------------------------------------------------------------------------
 	var err error
 	var flowLogFields log.Fields
+	var flowLease int64
 	var startTime time.Time
 
 	if flow.Lock() {
+		flowLease = flow.GetLease()
 		flowLogFields = log.Fields{
 			"hash": flow.FlowHash,
 			"run":  flow.GetRunID(),
@@ -535,6 +783,21 @@ func runFlow(flow *core.Flow) {
 		log.WithFields(flowLogFields).Info(core.LOG_FLOW_STOP)
 	}
 
+	// Confirm received data only if flow run is successful.
+	flowCommit := func(err error) {
+		if plugin, ok := flow.InputPlugin.(core.CommitPlugin); ok {
+			if err == nil {
+				err = plugin.Commit()
+			} else {
+				err = plugin.Rollback()
+			}
+
+			if err != nil {
+				flow.InputPlugin.FlowLog(err)
+			}
+		}
+	}
+
 	// -----------------------------------------------------------------------------------------------------------------
 	// Input plugin.
 
@@ -548,6 +811,20 @@ func runFlow(flow *core.Flow) {
 	inputData, err := flow.InputPlugin.Receive()
 	flow.InputPlugin.FlowLog(len(inputData))
 
+	// Keep sources health between flow runs.
+	if err := flow.GetHealth().Save(); err != nil {
+		flow.InputPlugin.FlowLog(err)
+	}
+
+	// Notify about expired sources.
+	if expired := flow.PopExpired(); len(expired) > 0 && flow.ExpirePlugin != nil {
+		if err := flow.ExpirePlugin.Send(expired); err != nil {
+			flow.ExpirePlugin.FlowLog(err)
+		} else {
+			flow.ExpirePlugin.FlowLog(fmt.Sprintf("expired sources: %d", len(expired)))
+		}
+	}
+
 	// Process data if flow sources are expired/failed.
 	// Skip flow if we have other problems.
 	if err == core.ERROR_FLOW_EXPIRE {
@@ -561,6 +838,7 @@ func runFlow(flow *core.Flow) {
 	} else if err != nil {
 		atomic.AddInt64(&flow.MetricError, 1)
 		flow.InputPlugin.FlowLog(err)
+		flowCommit(err)
 		flowStop()
 		return
 	}
@@ -569,12 +847,50 @@ func runFlow(flow *core.Flow) {
 	if len(inputData) == 0 {
 		atomic.AddInt64(&flow.MetricNoData, 1)
 		flow.InputPlugin.FlowLog(core.ERROR_NO_NEW_DATA)
+		flowCommit(nil)
 		flowStop()
 		return
 	} else {
 		atomic.AddInt64(&flow.MetricReceive, int64(len(inputData)))
 	}
 
+	// Expose flow variables to templates.
+	for _, item := range inputData {
+		item.VARS = flow.FlowVars
+	}
+
+	// Keep input data for replaying.
+	if flow.FlowArchive {
+		if err := flow.GetArchive().Write(inputData); err != nil {
+			log.WithFields(log.Fields{
+				"hash":  flow.FlowHash,
+				"run":   flow.GetRunID(),
+				"flow":  flow.FlowName,
+				"error": err,
+			}).Warn(core.LOG_FLOW_ARCHIVE)
+		}
+	}
+
+	// Process and send data.
+	flowCommit(sendFlow(flow, inputData, flowLease))
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Cleanup at the end.
+
+	flowStop()
+
+	// -----------------------------------------------------------------------------------------------------------------
+}
+
+// sendFlow passes data through "process" and "output" plugins.
+// Errors are logged and counted with flow metrics, the first error stops processing.
+// Data isn't sent if flow lease was lost after flow start (see core.Coordinator).
+func sendFlow(flow *core.Flow, inputData []*core.Datum, lease int64) error {
+	var err error
+
+	// Stream data to debug subscribers (see tapHandler).
+	tap := core.TapEnabled(flow.FlowName)
+
 	// -------------------------------------------------------------------------------------------------------------
 	// Process plugins.
 
@@ -600,6 +916,10 @@ func runFlow(flow *core.Flow) {
 			// 1. It's the first "process" plugin in the chain.
 			// 2 "require" is not set for plugin.
 			if pluginID == 0 || len(pluginRequire) == 0 {
+				if tap {
+					core.TapPublish(flow, "process", flow.ProcessPluginsNames[pluginID], pluginID, "in", inputData)
+				}
+
 				pluginResult, err = plugin.Process(inputData)
 
 			} else {
@@ -618,6 +938,10 @@ func runFlow(flow *core.Flow) {
 					}
 				}
 
+				if tap {
+					core.TapPublish(flow, "process", flow.ProcessPluginsNames[pluginID], pluginID, "in", combinedResult)
+				}
+
 				pluginResult, err = plugin.Process(combinedResult)
 			}
 
@@ -626,12 +950,15 @@ func runFlow(flow *core.Flow) {
 			if err != nil {
 				plugin.FlowLog(err)
 				atomic.AddInt64(&flow.MetricError, 1)
-				flowStop()
-				return
+				return err
 
 			} else {
 				plugin.FlowLog(len(pluginResult))
 				processResults[pluginID] = pluginResult
+
+				if tap {
+					core.TapPublish(flow, "process", flow.ProcessPluginsNames[pluginID], pluginID, "out", pluginResult)
+				}
 			}
 		}
 	}
@@ -665,14 +992,17 @@ func runFlow(flow *core.Flow) {
 					if len(pluginData) > 0 {
 						dataExist = true
 
-						err = flow.OutputPlugin.Send(pluginData)
+						if tap {
+							core.TapPublish(flow, "output", flow.OutputPlugin.GetName(), pluginID, "in", pluginData)
+						}
+
+						err = sendOutput(flow, pluginData, lease)
 
 						// Skip flow if there are problems with sending.
 						if err != nil {
 							atomic.AddInt64(&flow.MetricError, 1)
 							flow.OutputPlugin.FlowLog(err)
-							flowStop()
-							return
+							return err
 
 						} else {
 							atomic.AddInt64(&flow.MetricSend, int64(len(pluginData)))
@@ -692,14 +1022,17 @@ func runFlow(flow *core.Flow) {
 			}
 
 		} else if len(flow.ProcessPlugins) == 0 && len(inputData) > 0 {
-			err = flow.OutputPlugin.Send(inputData)
+			if tap {
+				core.TapPublish(flow, "output", flow.OutputPlugin.GetName(), -1, "in", inputData)
+			}
+
+			err = sendOutput(flow, inputData, lease)
 
 			// Skip flow if there are problems with sending.
 			if err != nil {
 				atomic.AddInt64(&flow.MetricError, 1)
 				flow.OutputPlugin.FlowLog(err)
-				flowStop()
-				return
+				return err
 
 			} else {
 				atomic.AddInt64(&flow.MetricSend, int64(len(inputData)))
@@ -711,10 +1044,14 @@ func runFlow(flow *core.Flow) {
 		}
 	}
 
-	// -----------------------------------------------------------------------------------------------------------------
-	// Cleanup at the end.
+	return nil
+}
 
-	flowStop()
+// sendOutput sends data through "output" plugin if flow still holds its lease.
+func sendOutput(flow *core.Flow, data []*core.Datum, lease int64) error {
+	if flow.GetLease() != lease {
+		return core.ERROR_FLOW_LEASE_LOST
+	}
 
-	// -----------------------------------------------------------------------------------------------------------------
+	return flow.OutputPlugin.Send(data)
 }
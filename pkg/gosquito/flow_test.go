@@ -0,0 +1,36 @@
+package gosquito
+
+import (
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"testing"
+)
+
+func TestInterpolateFlow(t *testing.T) {
+	// Flows without variables are kept as is.
+	flowBody := core.FlowUnmarshal{}
+	flowBody.Flow.Process = []map[interface{}]interface{}{{"regexp": []interface{}{"^\\${[A-Z]+}$"}}}
+
+	if err := interpolateFlow(&flowBody, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := flowBody.Flow.Process[0]["regexp"].([]interface{})[0]; v != "^\\${[A-Z]+}$" {
+		t.Errorf(`interpolateFlow(no vars) = %v`, v)
+	}
+
+	// Escaped values are kept with variables.
+	flowBody.Flow.Vars = map[interface{}]interface{}{"name": "news"}
+	flowBody.Flow.Process = []map[interface{}]interface{}{{"regexp": []interface{}{"^\\$${[A-Z]+}$"}, "channel": "${name}"}}
+
+	if err := interpolateFlow(&flowBody, map[string]string{"name": "news"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := flowBody.Flow.Process[0]["regexp"].([]interface{})[0]; v != "^\\${[A-Z]+}$" {
+		t.Errorf(`interpolateFlow(escaped) = %v`, v)
+	}
+
+	if v := flowBody.Flow.Process[0]["channel"]; v != "news" {
+		t.Errorf(`interpolateFlow(vars) = %v`, v)
+	}
+}
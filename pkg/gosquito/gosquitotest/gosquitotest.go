@@ -0,0 +1,27 @@
+package gosquitotest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/livelace/gosquito/pkg/gosquito"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"github.com/spf13/viper"
+)
+
+// AssertFlowTest is a helper for Go tests, golden files are updated with GOSQUITO_UPDATE_GOLDEN=1:
+//
+//	func TestFlows(t *testing.T) {
+//		appConfig, _ := core.NewAppConfig("testdata/config.toml")
+//		gosquitotest.AssertFlowTest(t, appConfig, "testdata/flow1.test.yaml")
+//	}
+func AssertFlowTest(t testing.TB, appConfig *viper.Viper, specFile string) {
+	t.Helper()
+
+	// Flows' states must not be shared with real flows and other tests.
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_DATA, t.TempDir())
+
+	if err := gosquito.RunFlowTestSpec(appConfig, specFile, os.Getenv("GOSQUITO_UPDATE_GOLDEN") == "1"); err != nil {
+		t.Errorf("%s: %v", specFile, err)
+	}
+}
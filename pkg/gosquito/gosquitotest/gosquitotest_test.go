@@ -0,0 +1,17 @@
+package gosquitotest
+
+import (
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"testing"
+)
+
+func TestAssertFlowTest(t *testing.T) {
+	appConfig, err := core.NewAppConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_CONF, "../testdata/conf")
+
+	AssertFlowTest(t, appConfig, "../testdata/harness.test.yaml")
+}
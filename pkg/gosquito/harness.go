@@ -0,0 +1,322 @@
+package gosquito
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FlowTestSpec describes flow test:
+// 1. Fixture datums are passed through "process" plugins of flow (input plugin isn't involved).
+// 2. Data which would be sent with "output" plugin are recorded and compared with golden file.
+type FlowTestSpec struct {
+	Flow    string        `yaml:"flow"`
+	Fixture string        `yaml:"fixture"`
+	Data    []interface{} `yaml:"data"`
+	Golden  string        `yaml:"golden"`
+}
+
+// RecordOutput is a fake "output" plugin, it records data instead of sending.
+type RecordOutput struct {
+	m sync.Mutex
+
+	Data []interface{}
+}
+
+func (p *RecordOutput) FlowLog(message interface{}) {
+	log.WithFields(log.Fields{
+		"plugin": p.GetName(),
+		"type":   "output",
+		"data":   fmt.Sprintf("%v", message),
+	}).Debug(core.LOG_FLOW_STAT)
+}
+
+func (p *RecordOutput) GetName() string {
+	return "record"
+}
+
+func (p *RecordOutput) GetOutput() []string {
+	return []string{}
+}
+
+// Send keeps datums in their current state (process plugins may change datums later).
+func (p *RecordOutput) Send(data []*core.Datum) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, item := range data {
+		v, err := core.PruneDatum(item)
+		if err != nil {
+			return err
+		}
+
+		// UUID is volatile.
+		delete(v, "UUID")
+
+		p.Data = append(p.Data, v)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+func convertYAML(i interface{}) interface{} {
+	switch v := i.(type) {
+	case map[interface{}]interface{}:
+		temp := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			temp[fmt.Sprintf("%v", k)] = convertYAML(e)
+		}
+		return temp
+
+	case []interface{}:
+		temp := make([]interface{}, len(v))
+		for k, e := range v {
+			temp[k] = convertYAML(e)
+		}
+		return temp
+	}
+
+	return i
+}
+
+func diffLines(expected string, actual string) string {
+	e := strings.Split(expected, "\n")
+	a := strings.Split(actual, "\n")
+
+	for i := 0; i < len(e) || i < len(a); i++ {
+		if i < len(e) && i < len(a) && e[i] == a[i] {
+			continue
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("line %d:\n", i+1))
+
+		for j := i; j < i+5 && j < len(e); j++ {
+			sb.WriteString(fmt.Sprintf("- %s\n", e[j]))
+		}
+
+		for j := i; j < i+5 && j < len(a); j++ {
+			sb.WriteString(fmt.Sprintf("+ %s\n", a[j]))
+		}
+
+		return sb.String()
+	}
+
+	return ""
+}
+
+// -----------------------------------------------------------------------------------------------------------------
+
+// CompareGolden compares data with golden file, golden file is (re)written if update is true.
+func CompareGolden(file string, data []byte, update bool) error {
+	if update {
+		if err := core.CreateDirIfNotExist(filepath.Dir(file)); err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(file, data, 0644)
+	}
+
+	golden, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(golden, data) {
+		return fmt.Errorf(core.ERROR_GOLDEN_MISMATCH.Error(), file, diffLines(string(golden), string(data)))
+	}
+
+	return nil
+}
+
+// LoadFixture reads datums from YAML/JSON file. Field names are case-insensitive: "rss: {title: ...}".
+func LoadFixture(file string) ([]*core.Datum, error) {
+	var data []interface{}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+
+	return decodeFixture(data)
+}
+
+func decodeFixture(data []interface{}) ([]*core.Datum, error) {
+	temp := make([]*core.Datum, 0)
+
+	b, err := json.Marshal(convertYAML(data))
+	if err != nil {
+		return temp, err
+	}
+
+	if err := json.Unmarshal(b, &temp); err != nil {
+		return temp, err
+	}
+
+	return temp, nil
+}
+
+// LoadTestFlow loads single flow from flow configuration directory.
+// Input, output and expire plugins aren't initialized (only "process" plugins are involved).
+func LoadTestFlow(appConfig *viper.Viper, name string) (*core.Flow, error) {
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_DISABLE, []string{})
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_ENABLE, []string{name})
+
+	for _, flow := range loadFlow(appConfig, flowPlugins{}) {
+		if flow.FlowName == name {
+			return flow, nil
+		}
+	}
+
+	return nil, fmt.Errorf(core.ERROR_FLOW_NOT_FOUND.Error(), name)
+}
+
+// RunFlowTest passes fixture datums through "process" plugins of flow and returns recorded data as JSON.
+func RunFlowTest(flow *core.Flow, fixtures []*core.Datum) ([]byte, error) {
+	record := &RecordOutput{Data: make([]interface{}, 0)}
+
+	outputPlugin := flow.OutputPlugin
+	flow.OutputPlugin = record
+	defer func() {
+		flow.OutputPlugin = outputPlugin
+	}()
+
+	for _, item := range fixtures {
+		if item.FLOW == "" {
+			item.FLOW = flow.FlowName
+		}
+
+		for _, tz := range []**time.Location{&item.TIMEZONE, &item.TIMEZONEA, &item.TIMEZONEB, &item.TIMEZONEC} {
+			if *tz == nil {
+				*tz = time.UTC
+			}
+		}
+
+		item.VARS = flow.FlowVars
+	}
+
+	if err := sendFlow(flow, fixtures, flow.GetLease()); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(record.Data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// RunFlowTestSpec runs flow test described in spec file. Fixture and golden paths are relative to spec file.
+func RunFlowTestSpec(appConfig *viper.Viper, specFile string, update bool) error {
+	var spec FlowTestSpec
+
+	content, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return err
+	}
+
+	specDir := filepath.Dir(specFile)
+
+	if spec.Golden == "" {
+		spec.Golden = strings.TrimSuffix(specFile, filepath.Ext(specFile)) + ".golden.json"
+	} else if !filepath.IsAbs(spec.Golden) {
+		spec.Golden = filepath.Join(specDir, spec.Golden)
+	}
+
+	// Fixture might be inline or in a dedicated file.
+	var fixtures []*core.Datum
+
+	if spec.Fixture != "" {
+		if !filepath.IsAbs(spec.Fixture) {
+			spec.Fixture = filepath.Join(specDir, spec.Fixture)
+		}
+		fixtures, err = LoadFixture(spec.Fixture)
+	} else {
+		fixtures, err = decodeFixture(spec.Data)
+	}
+	if err != nil {
+		return err
+	}
+
+	flow, err := LoadTestFlow(appConfig, spec.Flow)
+	if err != nil {
+		return err
+	}
+
+	data, err := RunFlowTest(flow, fixtures)
+	if err != nil {
+		return err
+	}
+
+	return CompareGolden(spec.Golden, data, update)
+}
+
+// RunTest runs flow tests from spec files:
+// gosquito test [-update] flow1.test.yaml flow2.test.yaml
+func RunTest(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	update := fs.Bool("update", false, "update golden files")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Get app config.
+	appConfig := core.GetAppConfig()
+
+	// Set log level.
+	ll, _ := log.ParseLevel(appConfig.GetString(core.VIPER_DEFAULT_LOG_LEVEL))
+	log.SetLevel(ll)
+
+	// Flows' states must not be shared with real flows.
+	flowData, err := ioutil.TempDir("", core.APP_NAME)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error(core.LOG_FLOW_TEST)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(flowData)
+
+	failed := 0
+
+	for _, specFile := range fs.Args() {
+		appConfig.Set(core.VIPER_DEFAULT_FLOW_DATA, filepath.Join(flowData, filepath.Base(specFile)))
+
+		if err := RunFlowTestSpec(appConfig, specFile, *update); err != nil {
+			failed += 1
+			fmt.Printf("FAIL %s: %v\n", specFile, err)
+		} else {
+			fmt.Printf("ok   %s\n", specFile)
+		}
+	}
+
+	if failed > 0 {
+		_ = os.RemoveAll(flowData)
+		os.Exit(1)
+	}
+}
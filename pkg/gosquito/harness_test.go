@@ -0,0 +1,84 @@
+package gosquito
+
+import (
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareGolden(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "golden", "test.json")
+
+	if err := CompareGolden(file, []byte("a\nb\n"), true); err != nil {
+		t.Fatalf(`CompareGolden(update) = %v`, err)
+	}
+
+	if err := CompareGolden(file, []byte("a\nb\n"), false); err != nil {
+		t.Errorf(`CompareGolden() = %v`, err)
+	}
+
+	err := CompareGolden(file, []byte("a\nc\n"), false)
+	if err == nil || !strings.Contains(err.Error(), "line 2:\n- b\n") || !strings.Contains(err.Error(), "+ c\n") {
+		t.Errorf(`CompareGolden(mismatch) = %v`, err)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fixture.yaml")
+
+	content := "- rss:\n    title: \"Hello\"\n  data:\n    array0: [\"a\", \"b\"]\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := LoadFixture(file)
+
+	if err != nil || len(data) != 1 || data[0].RSS.TITLE != "Hello" || len(data[0].DATA.ARRAY0) != 2 {
+		t.Errorf(`LoadFixture() = %v, %v`, data, err)
+	}
+}
+
+func TestLoadTestFlow(t *testing.T) {
+	appConfig, err := core.NewAppConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_CONF, "testdata/conf")
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_DATA, t.TempDir())
+
+	flow, err := LoadTestFlow(appConfig, "harness")
+
+	if err != nil || flow.InputPlugin != nil || flow.OutputPlugin != nil || len(flow.ProcessPlugins) != 1 {
+		t.Errorf(`LoadTestFlow() = %v, %v`, flow, err)
+	}
+}
+
+func TestRecordOutput(t *testing.T) {
+	record := &RecordOutput{}
+
+	item := &core.Datum{FLOW: "flow1"}
+	item.DATA.TEXT0 = "a"
+
+	if err := record.Send([]*core.Datum{item}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recorded data mustn't change with datum.
+	item.DATA.TEXT0 = "b"
+
+	v, ok := record.Data[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf(`RecordOutput.Data = %v`, record.Data)
+	}
+
+	if _, ok := v["UUID"]; ok {
+		t.Errorf(`RecordOutput.Data contains UUID: %v`, v)
+	}
+
+	if data, _ := v["DATA"].(map[string]interface{}); data["TEXT0"] != "a" {
+		t.Errorf(`RecordOutput.Data = %v`, v)
+	}
+}
@@ -0,0 +1,43 @@
+package gosquito
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"net/http"
+)
+
+// sourcesHandler shows flow sources health and releases quarantined sources (sources and errors might contain
+// credentials, so admin_token is required):
+// curl -H "Authorization: Bearer <admin_token>" "http://127.0.0.1:8080/sources?flow=flow1"
+// curl -X POST -H "Authorization: Bearer <admin_token>" "http://127.0.0.1:8080/sources?flow=flow1&source=https://example.com/feed.xml"
+func sourcesHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("flow")
+
+	flow := core.GetFlowByName(name)
+	if flow == nil {
+		http.Error(w, fmt.Sprintf(core.ERROR_FLOW_NOT_FOUND.Error(), name), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := flow.GetHealth().Load(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+	case http.MethodPost:
+		if err := flow.GetHealth().Release(r.URL.Query().Get("source")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"flow": name, "sources": flow.GetHealth().Snapshot()})
+}
@@ -0,0 +1,1050 @@
+package imapIn
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+	"github.com/google/uuid"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+)
+
+const (
+	PLUGIN_NAME = "imap"
+
+	DEFAULT_ATTACHMENT     = "attachment"
+	DEFAULT_ATTACHMENTS    = true
+	DEFAULT_FETCH_COUNT    = 100
+	DEFAULT_IDLE           = false
+	DEFAULT_IDLE_RETRY     = "1m"
+	DEFAULT_IMAP_PORT      = 993
+	DEFAULT_MARK_SEEN      = false
+	DEFAULT_MESSAGE_BUFFER = 10
+	DEFAULT_SSL_ENABLE     = true
+	DEFAULT_SSL_VERIFY     = true
+	DEFAULT_STATE_DIVIDER  = ":"
+	DEFAULT_STATE_PREFIX   = "uid:"
+	DEFAULT_UNSEEN         = false
+	DEFAULT_UPDATE_BUFFER  = 10
+)
+
+var (
+	ERROR_BODY_EMPTY    = errors.New("message body is empty: %d")
+	ERROR_STATE_INVALID = errors.New("uid state invalid: %s")
+)
+
+// mailboxState keeps the last processed message of mailbox.
+// Messages are identified by UID, UIDs are valid until mailbox UIDVALIDITY is changed.
+type mailboxState struct {
+	uid      uint32
+	validity uint32
+}
+
+// mailboxPending keeps mailbox state and received messages until flow run is finished (see Commit).
+type mailboxPending struct {
+	state mailboxState
+	uids  []uint32
+}
+
+func addressList(header *mail.Header, key string) []string {
+	temp := make([]string, 0)
+
+	addresses, err := header.AddressList(key)
+	if err != nil {
+		return temp
+	}
+
+	for _, address := range addresses {
+		if address.Name != "" {
+			temp = append(temp, fmt.Sprintf("%s <%s>", address.Name, address.Address))
+		} else {
+			temp = append(temp, address.Address)
+		}
+	}
+
+	return temp
+}
+
+func connect(p *Plugin) (*client.Client, error) {
+	var c *client.Client
+	var err error
+
+	addr := net.JoinHostPort(p.OptionServer, strconv.Itoa(p.OptionPort))
+	dialer := &net.Dialer{Timeout: time.Duration(p.OptionTimeout) * time.Second}
+
+	if p.OptionSSL {
+		c, err = client.DialWithDialerTLS(dialer, addr,
+			&tls.Config{ServerName: p.OptionServer, InsecureSkipVerify: !p.OptionSSLVerify})
+	} else {
+		c, err = client.DialWithDialer(dialer, addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.Timeout = time.Duration(p.OptionTimeout) * time.Second
+
+	if err := c.Login(p.OptionUsername, p.OptionPassword); err != nil {
+		_ = c.Logout()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// fetchMailbox returns new messages, updated mailbox state and UIDs of received (parsed) messages.
+func fetchMailbox(p *Plugin, c *client.Client, mailbox string, state mailboxState) ([]*core.Datum, mailboxState, []uint32, int, error) {
+	temp := make([]*core.Datum, 0)
+	parsed := make([]uint32, 0)
+
+	status, err := c.Select(mailbox, true)
+	if err != nil {
+		return temp, state, parsed, 0, err
+	}
+
+	// UIDs from previous runs aren't valid anymore.
+	if status.UidValidity != state.validity {
+		if state.validity != 0 {
+			core.LogInputPlugin(p.LogFields, mailbox,
+				fmt.Sprintf("uid validity changed: %d -> %d", state.validity, status.UidValidity))
+		}
+
+		state = mailboxState{validity: status.UidValidity}
+	}
+
+	// Search new messages.
+	criteria := imap.NewSearchCriteria()
+
+	if !p.OptionForce {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(state.uid+1, 0)
+	}
+
+	if p.OptionUnseen {
+		criteria.WithoutFlags = []string{imap.SeenFlag}
+	}
+
+	found, err := c.UidSearch(criteria)
+	if err != nil {
+		return temp, state, parsed, 0, err
+	}
+
+	// "N:*" always matches the last message, even if its UID is lower than N.
+	uids := make([]uint32, 0)
+	for _, uid := range found {
+		if uid > state.uid || p.OptionForce {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	// Process only specific amount of the newest messages if force = true.
+	// Process the oldest messages first otherwise, the rest will be processed next runs.
+	if p.OptionForce && len(uids) > p.OptionForceCount {
+		uids = uids[len(uids)-p.OptionForceCount:]
+	} else if !p.OptionForce && len(uids) > p.OptionFetchCount {
+		uids = uids[:p.OptionFetchCount]
+	}
+
+	if len(uids) == 0 {
+		return temp, state, parsed, 0, nil
+	}
+
+	// Fetch messages.
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchFlags, imap.FetchInternalDate, imap.FetchUid, section.FetchItem()}
+
+	messages := make(chan *imap.Message, DEFAULT_MESSAGE_BUFFER)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.UidFetch(seqset, items, messages)
+	}()
+
+	// Messages which cannot be parsed are skipped, but they aren't marked/moved (they stay in mailbox as is).
+	for message := range messages {
+		datum, err := parseMessage(p, mailbox, message, section)
+		if err != nil {
+			core.LogInputPlugin(p.LogFields, mailbox, fmt.Errorf("uid: %d, %v", message.Uid, err))
+		} else {
+			temp = append(temp, datum)
+			parsed = append(parsed, message.Uid)
+		}
+
+		if message.Uid > state.uid {
+			state.uid = message.Uid
+		}
+	}
+
+	if err := <-done; err != nil {
+		return temp, state, parsed, len(uids), err
+	}
+
+	return temp, state, parsed, len(uids), nil
+}
+
+// markMailbox marks/moves processed messages.
+func markMailbox(p *Plugin, c *client.Client, mailbox string, uids []uint32) {
+	if _, err := c.Select(mailbox, false); err != nil {
+		core.LogInputPlugin(p.LogFields, mailbox, err)
+		return
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+
+	if p.OptionMarkSeen {
+		if err := c.UidStore(seqset, imap.FormatFlagsOp(imap.AddFlags, true),
+			[]interface{}{imap.SeenFlag}, nil); err != nil {
+			core.LogInputPlugin(p.LogFields, mailbox, fmt.Errorf("mark seen: %v", err))
+		}
+	}
+
+	if p.OptionMove != "" {
+		if err := c.UidMove(seqset, p.OptionMove); err != nil {
+			core.LogInputPlugin(p.LogFields, mailbox, fmt.Errorf("move: %s, %v", p.OptionMove, err))
+		}
+	}
+}
+
+func parseMessage(p *Plugin, mailbox string, message *imap.Message, section *imap.BodySectionName) (*core.Datum, error) {
+	var u, _ = uuid.NewRandom()
+
+	body := message.GetBody(section)
+	if body == nil {
+		return nil, fmt.Errorf(ERROR_BODY_EMPTY.Error(), message.Uid)
+	}
+
+	reader, err := mail.CreateReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Message date has higher priority over server receive time.
+	itemTime, err := reader.Header.Date()
+	if err != nil || itemTime.IsZero() {
+		itemTime = message.InternalDate
+	}
+	itemTime = itemTime.UTC()
+
+	subject, _ := reader.Header.Subject()
+	messageID, _ := reader.Header.MessageID()
+
+	from := ""
+	if v := addressList(&reader.Header, "From"); len(v) > 0 {
+		from = v[0]
+	}
+
+	attachments := make([]string, 0)
+	html := make([]string, 0)
+	text := make([]string, 0)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch header := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ := header.ContentType()
+
+			b, err := io.ReadAll(part.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			switch contentType {
+			case "text/html":
+				html = append(html, string(b))
+			case "text/plain", "":
+				text = append(text, string(b))
+			}
+
+		case *mail.AttachmentHeader:
+			if !p.OptionAttachments {
+				continue
+			}
+
+			filename, _ := header.Filename()
+			if filename = filepath.Base(filename); filename == "." || filename == "/" {
+				filename = DEFAULT_ATTACHMENT
+			}
+
+			outputDir := filepath.Join(p.Flow.FlowTempDir, p.PluginType, p.PluginName, u.String())
+			if err := core.CreateDirIfNotExist(outputDir); err != nil {
+				return nil, err
+			}
+
+			outputFile := filepath.Join(outputDir, filename)
+			if err := saveAttachment(outputFile, part.Body); err != nil {
+				return nil, err
+			}
+
+			attachments = append(attachments, outputFile)
+		}
+	}
+
+	return &core.Datum{
+		FLOW:        p.Flow.FlowName,
+		PLUGIN:      p.PluginName,
+		SOURCE:      mailbox,
+		TIME:        itemTime,
+		TIMEFORMAT:  itemTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+		TIMEFORMATA: itemTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+		TIMEFORMATB: itemTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+		TIMEFORMATC: itemTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+		UUID:        u,
+
+		IMAP: core.Imap{
+			ATTACHMENTS: attachments,
+			CC:          addressList(&reader.Header, "Cc"),
+			FLAGS:       message.Flags,
+			FROM:        from,
+			HTML:        strings.Join(html, "\n"),
+			MAILBOX:     mailbox,
+			MESSAGEID:   messageID,
+			SUBJECT:     subject,
+			TEXT:        strings.Join(text, "\n"),
+			TO:          addressList(&reader.Header, "To"),
+			UID:         strconv.FormatUint(uint64(message.Uid), 10),
+		},
+
+		WARNINGS: make([]string, 0),
+	}, nil
+}
+
+func saveAttachment(file string, r io.Reader) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// watchMailbox waits for mailbox changes (IDLE) and runs flow immediately.
+func watchMailbox(p *Plugin, mailbox string) {
+	retry, _ := core.IsInterval(DEFAULT_IDLE_RETRY)
+
+	for {
+		err := idleMailbox(p, mailbox)
+		core.LogInputPlugin(p.LogFields, mailbox, fmt.Errorf("idle: %v", err))
+
+		time.Sleep(time.Duration(retry) * time.Millisecond)
+	}
+}
+
+func idleMailbox(p *Plugin, mailbox string) error {
+	c, err := connect(p)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	updates := make(chan client.Update, DEFAULT_UPDATE_BUFFER)
+	c.Updates = updates
+
+	if _, err := c.Select(mailbox, true); err != nil {
+		return err
+	}
+
+	// IDLE command lasts until mailbox is changed.
+	c.Timeout = 0
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.Idle(stop, nil)
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			if _, ok := update.(*client.MailboxUpdate); ok {
+				core.LogInputPlugin(p.LogFields, mailbox, "idle: mailbox updated")
+				p.Flow.Trigger()
+			}
+
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	pending map[string]mailboxPending
+	uids    map[string]mailboxState
+
+	OptionAttachments bool
+	OptionFetchCount  int
+	OptionForce       bool
+	OptionForceCount  int
+	OptionIdle        bool
+	OptionInput       []string
+	OptionMarkSeen    bool
+	OptionMove        string
+	OptionPassword    string
+	OptionPort        int
+	OptionSSL         bool
+	OptionSSLVerify   bool
+	OptionServer      string
+	OptionTimeFormat  string
+	OptionTimeFormatA string
+	OptionTimeFormatB string
+	OptionTimeFormatC string
+	OptionTimeZone    *time.Location
+	OptionTimeZoneA   *time.Location
+	OptionTimeZoneB   *time.Location
+	OptionTimeZoneC   *time.Location
+	OptionTimeout     int
+	OptionUnseen      bool
+	OptionUsername    string
+}
+
+// Commit marks/moves received messages and saves mailboxes' UIDs.
+// Messages are considered as processed even if marking/moving failed (data were sent).
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	pending := p.pending
+	p.pending = make(map[string]mailboxPending, 0)
+	p.m.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if p.OptionMarkSeen || p.OptionMove != "" {
+		c, err := connect(p)
+		if err != nil {
+			core.LogInputPlugin(p.LogFields, "all", err)
+		} else {
+			for mailbox, v := range pending {
+				if len(v.uids) > 0 {
+					markMailbox(p, c, mailbox, v.uids)
+				}
+			}
+			_ = c.Logout()
+		}
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	db, err := core.OpenStateStore(p.Flow.FlowStateDir)
+	if err != nil {
+		return err
+	}
+
+	for mailbox, v := range pending {
+		value := fmt.Sprintf("%d%s%d", v.state.validity, DEFAULT_STATE_DIVIDER, v.state.uid)
+
+		if err := db.Put(DEFAULT_STATE_PREFIX+mailbox, []byte(value), 0); err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+
+	return db.Close()
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+// LoadState reads sources' timestamps and mailboxes' UIDs (prefixed keys).
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+	p.uids = make(map[string]mailboxState, 0)
+
+	db, err := core.OpenStateStore(p.Flow.FlowStateDir)
+	if err != nil {
+		return data, err
+	}
+	defer db.Close()
+
+	err = db.Iterate(func(key string, value []byte) error {
+		if mailbox, ok := strings.CutPrefix(key, DEFAULT_STATE_PREFIX); ok {
+			var state mailboxState
+
+			if _, err := fmt.Sscanf(string(value), "%d"+DEFAULT_STATE_DIVIDER+"%d", &state.validity, &state.uid); err != nil {
+				return fmt.Errorf(ERROR_STATE_INVALID.Error(), key)
+			}
+
+			p.uids[mailbox] = state
+
+			return nil
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, string(value))
+		data[key] = timestamp
+
+		return err
+	})
+
+	return data, err
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	var c *client.Client
+
+	failedSources := make([]string, 0)
+	temp := make([]*core.Datum, 0)
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+	core.LogInputPlugin(p.LogFields, "all", fmt.Sprintf("states loaded: %d", len(flowStates)))
+
+	// Fetch data from sources.
+	for _, source := range p.OptionInput {
+		var sourceLastTime time.Time
+
+		// Skip sources with backoff/quarantine.
+		if !p.Flow.GetHealth().Allow(source) {
+			core.LogInputPlugin(p.LogFields, source,
+				fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+			continue
+		}
+
+		// Check if we work with source first time.
+		if v, ok := flowStates[source]; ok {
+			sourceLastTime = v
+		} else {
+			sourceLastTime = time.Unix(0, 0)
+		}
+
+		// Single connection is shared across mailboxes.
+		if c == nil {
+			if c, err = connect(p); err != nil {
+				c = nil
+				failedSources = append(failedSources, source)
+				p.Flow.GetHealth().Fail(source, err)
+				core.LogInputPlugin(p.LogFields, source, err)
+				continue
+			}
+		}
+
+		// Try to fetch new messages.
+		data, state, parsed, received, err := fetchMailbox(p, c, source, p.uids[source])
+		if err != nil {
+			failedSources = append(failedSources, source)
+			p.Flow.GetHealth().Fail(source, err)
+			core.LogInputPlugin(p.LogFields, source, err)
+
+			// Connection might be broken.
+			_ = c.Logout()
+			c = nil
+		} else {
+			p.Flow.GetHealth().Success(source)
+		}
+
+		// Messages are received even if marking/moving failed.
+		for _, item := range data {
+			if item.TIME.Unix() > sourceLastTime.Unix() {
+				sourceLastTime = item.TIME
+			}
+		}
+
+		temp = append(temp, data...)
+
+		// Messages are marked/moved and UIDs are saved after successful flow run.
+		p.m.Lock()
+		p.pending[source] = mailboxPending{state: state, uids: parsed}
+		p.m.Unlock()
+
+		// always update source timestamp.
+		flowStates[source] = sourceLastTime
+
+		core.LogInputPlugin(p.LogFields, source, fmt.Sprintf("last update: %s, last uid: %d, received data: %d, new data: %d",
+			sourceLastTime, state.uid, received, len(data)))
+	}
+
+	if c != nil {
+		_ = c.Logout()
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
+
+	// Inform about sources failures.
+	if len(failedSources) > 0 {
+		return temp, core.ERROR_FLOW_SOURCE_FAIL
+	}
+
+	// Inform about expiration.
+	if sourcesExpired {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback forgets received messages, they will be received again.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.pending = make(map[string]mailboxPending, 0)
+
+	return nil
+}
+
+// SaveState writes sources' timestamps, mailboxes' UIDs are saved with Commit (UIDs never expire).
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		pending:    make(map[string]mailboxPending, 0),
+		uids:       make(map[string]mailboxState, 0),
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// Will be set to "0" if parameter is set somehow (defaults, template, config).
+
+	availableParams := map[string]int{
+		"cred":                  -1,
+		"expire_action":         -1,
+		"expire_action_delay":   -1,
+		"expire_action_timeout": -1,
+		"expire_interval":       -1,
+		"force":                 -1,
+		"force_count":           -1,
+		"template":              -1,
+		"time_format":           -1,
+		"time_format_a":         -1,
+		"time_format_b":         -1,
+		"time_format_c":         -1,
+		"time_zone":             -1,
+		"time_zone_a":           -1,
+		"time_zone_b":           -1,
+		"time_zone_c":           -1,
+		"timeout":               -1,
+
+		"attachments": -1,
+		"fetch_count": -1,
+		"idle":        -1,
+		"input":       1,
+		"mark_seen":   -1,
+		"move":        -1,
+		"password":    1,
+		"port":        -1,
+		"server":      1,
+		"ssl":         -1,
+		"ssl_verify":  -1,
+		"unseen":      -1,
+		"username":    1,
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// password.
+	setPassword := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["password"] = 0
+			plugin.OptionPassword = core.GetCredValue(v, vault)
+		}
+	}
+	setPassword(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.password", cred)))
+	setPassword((*pluginConfig.PluginParams)["password"])
+
+	// username.
+	setUsername := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["username"] = 0
+			plugin.OptionUsername = core.GetCredValue(v, vault)
+		}
+	}
+	setUsername(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.username", cred)))
+	setUsername((*pluginConfig.PluginParams)["username"])
+	core.ShowPluginParam(plugin.LogFields, "username", plugin.OptionUsername)
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// attachments.
+	setAttachments := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["attachments"] = 0
+			plugin.OptionAttachments = v
+		}
+	}
+	setAttachments(DEFAULT_ATTACHMENTS)
+	setAttachments(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.attachments", template)))
+	setAttachments((*pluginConfig.PluginParams)["attachments"])
+	core.ShowPluginParam(plugin.LogFields, "attachments", plugin.OptionAttachments)
+
+	// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+	plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+	// fetch_count.
+	setFetchCount := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["fetch_count"] = 0
+			plugin.OptionFetchCount = v
+		}
+	}
+	setFetchCount(DEFAULT_FETCH_COUNT)
+	setFetchCount(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.fetch_count", template)))
+	setFetchCount((*pluginConfig.PluginParams)["fetch_count"])
+	core.ShowPluginParam(plugin.LogFields, "fetch_count", plugin.OptionFetchCount)
+
+	// force.
+	setForce := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["force"] = 0
+			plugin.OptionForce = v
+		}
+	}
+	setForce(core.DEFAULT_FORCE_INPUT)
+	setForce(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.force", template)))
+	setForce((*pluginConfig.PluginParams)["force"])
+	core.ShowPluginParam(plugin.LogFields, "force", plugin.OptionForce)
+
+	// force_count.
+	setForceCount := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["force_count"] = 0
+			plugin.OptionForceCount = v
+		}
+	}
+	setForceCount(core.DEFAULT_FORCE_COUNT)
+	setForceCount(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.force_count", template)))
+	setForceCount((*pluginConfig.PluginParams)["force_count"])
+	core.ShowPluginParam(plugin.LogFields, "force_count", plugin.OptionForceCount)
+
+	// idle.
+	setIdle := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["idle"] = 0
+			plugin.OptionIdle = v
+		}
+	}
+	setIdle(DEFAULT_IDLE)
+	setIdle(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.idle", template)))
+	setIdle((*pluginConfig.PluginParams)["idle"])
+	core.ShowPluginParam(plugin.LogFields, "idle", plugin.OptionIdle)
+
+	// input.
+	setInput := func(p interface{}) {
+		if v, b := core.IsSliceOfString(p); b {
+			availableParams["input"] = 0
+			plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+		}
+	}
+	setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+	setInput((*pluginConfig.PluginParams)["input"])
+	core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+	// mark_seen.
+	setMarkSeen := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["mark_seen"] = 0
+			plugin.OptionMarkSeen = v
+		}
+	}
+	setMarkSeen(DEFAULT_MARK_SEEN)
+	setMarkSeen(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.mark_seen", template)))
+	setMarkSeen((*pluginConfig.PluginParams)["mark_seen"])
+	core.ShowPluginParam(plugin.LogFields, "mark_seen", plugin.OptionMarkSeen)
+
+	// move.
+	setMove := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["move"] = 0
+			plugin.OptionMove = v
+		}
+	}
+	setMove(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.move", template)))
+	setMove((*pluginConfig.PluginParams)["move"])
+	core.ShowPluginParam(plugin.LogFields, "move", plugin.OptionMove)
+
+	// port.
+	setPort := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["port"] = 0
+			plugin.OptionPort = v
+		}
+	}
+	setPort(DEFAULT_IMAP_PORT)
+	setPort(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.port", template)))
+	setPort((*pluginConfig.PluginParams)["port"])
+	core.ShowPluginParam(plugin.LogFields, "port", plugin.OptionPort)
+
+	// server.
+	setServer := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["server"] = 0
+			plugin.OptionServer = v
+		}
+	}
+	setServer(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.server", template)))
+	setServer((*pluginConfig.PluginParams)["server"])
+	core.ShowPluginParam(plugin.LogFields, "server", plugin.OptionServer)
+
+	// ssl.
+	setSSL := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["ssl"] = 0
+			plugin.OptionSSL = v
+		}
+	}
+	setSSL(DEFAULT_SSL_ENABLE)
+	setSSL(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ssl", template)))
+	setSSL((*pluginConfig.PluginParams)["ssl"])
+	core.ShowPluginParam(plugin.LogFields, "ssl", plugin.OptionSSL)
+
+	// ssl_verify.
+	setSSLVerify := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["ssl_verify"] = 0
+			plugin.OptionSSLVerify = v
+		}
+	}
+	setSSLVerify(DEFAULT_SSL_VERIFY)
+	setSSLVerify(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ssl_verify", template)))
+	setSSLVerify((*pluginConfig.PluginParams)["ssl_verify"])
+	core.ShowPluginParam(plugin.LogFields, "ssl_verify", plugin.OptionSSLVerify)
+
+	// time_format.
+	setTimeFormat := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format"] = 0
+			plugin.OptionTimeFormat = v
+		}
+	}
+	setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+	setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+	core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+	// time_format_a.
+	setTimeFormatA := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_a"] = 0
+			plugin.OptionTimeFormatA = v
+		}
+	}
+	setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+	setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+	// time_format_b.
+	setTimeFormatB := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_b"] = 0
+			plugin.OptionTimeFormatB = v
+		}
+	}
+	setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+	setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+	// time_format_c.
+	setTimeFormatC := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_c"] = 0
+			plugin.OptionTimeFormatC = v
+		}
+	}
+	setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+	setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+	// time_zone.
+	setTimeZone := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone"] = 0
+			plugin.OptionTimeZone = v
+		}
+	}
+	setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+	setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+	// time_zone_a.
+	setTimeZoneA := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_a"] = 0
+			plugin.OptionTimeZoneA = v
+		}
+	}
+	setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+	setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+	// time_zone_b.
+	setTimeZoneB := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_b"] = 0
+			plugin.OptionTimeZoneB = v
+		}
+	}
+	setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+	setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+	// time_zone_c.
+	setTimeZoneC := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_c"] = 0
+			plugin.OptionTimeZoneC = v
+		}
+	}
+	setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+	setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+	// timeout.
+	setTimeout := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["timeout"] = 0
+			plugin.OptionTimeout = v
+		}
+	}
+	setTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_PLUGIN_TIMEOUT))
+	setTimeout(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.timeout", template)))
+	setTimeout((*pluginConfig.PluginParams)["timeout"])
+	core.ShowPluginParam(plugin.LogFields, "timeout", plugin.OptionTimeout)
+
+	// unseen.
+	setUnseen := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["unseen"] = 0
+			plugin.OptionUnseen = v
+		}
+	}
+	setUnseen(DEFAULT_UNSEEN)
+	setUnseen(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.unseen", template)))
+	setUnseen((*pluginConfig.PluginParams)["unseen"])
+	core.ShowPluginParam(plugin.LogFields, "unseen", plugin.OptionUnseen)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Watch mailboxes.
+
+	if plugin.OptionIdle {
+		for _, mailbox := range plugin.OptionInput {
+			go watchMailbox(&plugin, mailbox)
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
@@ -3,15 +3,23 @@ package rssIn
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"github.com/antchfx/htmlquery"
 	"github.com/google/uuid"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
 	log "github.com/livelace/logrus"
 	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
 	"github.com/qiniu/iconv"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,18 +27,154 @@ import (
 const (
 	PLUGIN_NAME = "rss"
 
+	DEFAULT_CACHE_DIR       = "cache"
+	DEFAULT_DISCOVER        = false
 	DEFAULT_INPUT_ENCODING  = "utf-8"
 	DEFAULT_OUTPUT_ENCODING = "utf-8"
 	DEFAULT_MATCH_TTL       = "1d"
+	DEFAULT_OPML_REFRESH    = "1h"
 	DEFAULT_SSL_VERIFY      = true
 )
 
 var (
-	ERROR_NO_DATA       = errors.New("no data from source")
-	ERROR_PROXY_INVALID = errors.New("proxy invalid: %s")
+	ERROR_FEED_NOT_FOUND = errors.New("feed not found: %s")
+	ERROR_NO_DATA        = errors.New("no data from source")
+	ERROR_PROXY_INVALID  = errors.New("proxy invalid: %s")
 )
 
-func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
+// Feed types for autodiscovery (<link rel="alternate" type="...">).
+var discoverTypes = map[string]bool{
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rss+xml":   true,
+}
+
+// feedCache keeps validators of the last fetched feed version (conditional GET)
+// and feed url discovered from site page.
+type feedCache struct {
+	ETag         string
+	Feed         string
+	LastModified string
+}
+
+type opmlOutline struct {
+	Outlines []opmlOutline `xml:"outline"`
+	XMLURL   string        `xml:"xmlUrl,attr"`
+}
+
+type opmlDocument struct {
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+func getClient(p *Plugin) *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(p.OptionTimeout) * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(p.OptionProxyURL),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !p.OptionSSLVerify},
+		},
+	}
+}
+
+// getDocument reads local file or downloads remote document.
+func getDocument(p *Plugin, location string) (io.ReadCloser, error) {
+	if u, err := url.Parse(location); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return os.Open(location)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.OptionTimeout)*time.Second)
+	defer cancel()
+
+	// Respect rate limits for remote host.
+	if err := p.Flow.RateLimitWait(ctx, location); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.OptionUserAgent)
+
+	res, err := getClient(p).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("http error: %s", res.Status)
+	}
+
+	return res.Body, nil
+}
+
+// discoverFeed finds feed url inside site page: <link rel="alternate" type="application/rss+xml" href="...">.
+func discoverFeed(p *Plugin, site string) (string, error) {
+	base, err := url.Parse(site)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := getDocument(p, site)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	doc, err := htmlquery.Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	for _, node := range htmlquery.Find(doc, "//link[@href]") {
+		rel := strings.ToLower(htmlquery.SelectAttr(node, "rel"))
+		kind := strings.ToLower(htmlquery.SelectAttr(node, "type"))
+
+		if !strings.Contains(rel, "alternate") || !discoverTypes[kind] {
+			continue
+		}
+
+		if href, err := url.Parse(strings.TrimSpace(htmlquery.SelectAttr(node, "href"))); err == nil {
+			return base.ResolveReference(href).String(), nil
+		}
+	}
+
+	return "", fmt.Errorf(ERROR_FEED_NOT_FOUND.Error(), site)
+}
+
+// loadOPML returns feeds of OPML file (local path or url), nested outlines are included.
+func loadOPML(p *Plugin, location string) ([]string, error) {
+	temp := make([]string, 0)
+
+	body, err := getDocument(p, location)
+	if err != nil {
+		return temp, err
+	}
+	defer body.Close()
+
+	var doc opmlDocument
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return temp, err
+	}
+
+	var walk func(outlines []opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, outline := range outlines {
+			if v := strings.TrimSpace(outline.XMLURL); v != "" {
+				temp = append(temp, v)
+			}
+			walk(outline.Outlines)
+		}
+	}
+
+	walk(doc.Outlines)
+
+	return temp, nil
+}
+
+func fetchFeed(p *Plugin, url string, cache *feedCache) (*gofeed.Feed, bool, error) {
+	notModified := false
 	temp := &gofeed.Feed{}
 
 	// context.
@@ -46,13 +190,29 @@ func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
 	client := http.Client{Transport: transport}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, notModified, err
 	}
 	req.Header.Set("Accept-Charset", "utf-8")
 	req.Header.Set("User-Agent", p.OptionUserAgent)
 
+	// Download feed only if it was changed (forced sources are always downloaded).
+	if !p.OptionForce {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
 	// background.
 	go func() {
+		// Respect rate limits for remote host.
+		if err := p.Flow.RateLimitWait(ctx, url); err != nil {
+			c <- err
+			return
+		}
+
 		// toxiproxy is neat, btw :)
 		res, err := client.Do(req)
 		if err != nil {
@@ -70,6 +230,12 @@ func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
 			}()
 		}
 
+		if res.StatusCode == http.StatusNotModified {
+			notModified = true
+			c <- nil
+			return
+		}
+
 		if res.StatusCode < 200 || res.StatusCode >= 300 {
 			c <- fmt.Errorf("http error: %s", res.Status)
 			return
@@ -78,9 +244,9 @@ func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
 		if p.OptionInputEncoding == DEFAULT_INPUT_ENCODING {
 			temp, err = gofeed.NewParser().Parse(res.Body)
 		} else {
-			cd, err := iconv.Open(DEFAULT_OUTPUT_ENCODING, p.OptionInputEncoding)
-			if err != nil {
-				c <- err
+			cd, cdErr := iconv.Open(DEFAULT_OUTPUT_ENCODING, p.OptionInputEncoding)
+			if cdErr != nil {
+				c <- cdErr
 				return
 			}
 			defer cd.Close()
@@ -88,16 +254,20 @@ func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
 			temp, err = gofeed.NewParser().Parse(iconv.NewReader(cd, res.Body, 4096))
 		}
 
-		if temp == nil {
-			c <- fmt.Errorf("%s, %d", ERROR_NO_DATA, res.StatusCode)
+		// Parsing errors are checked first, site page (not a feed) might be used for autodiscovery.
+		if err != nil {
+			c <- err
 			return
 		}
 
-		if err != nil {
-			c <- err
+		if temp == nil {
+			c <- fmt.Errorf("%s, %d", ERROR_NO_DATA, res.StatusCode)
 			return
 		}
 
+		cache.ETag = res.Header.Get("ETag")
+		cache.LastModified = res.Header.Get("Last-Modified")
+
 		c <- nil
 	}()
 
@@ -106,13 +276,71 @@ func fetchFeed(p *Plugin, url string) (*gofeed.Feed, error) {
 	case <-ctx.Done():
 	case err := <-c:
 		if err != nil {
-			return temp, fmt.Errorf("error: %s, %s", url, err)
+			return temp, notModified, fmt.Errorf("error: %s, %w", url, err)
 		}
 	case <-time.After(time.Duration(p.OptionTimeout) * time.Second):
-		return temp, fmt.Errorf("timeout: %s", url)
+		return temp, notModified, fmt.Errorf("timeout: %s", url)
 	}
 
-	return temp, nil
+	return temp, notModified, nil
+}
+
+func getAuthors(persons []*gofeed.Person) []string {
+	temp := make([]string, 0)
+
+	for _, person := range persons {
+		switch {
+		case person.Name != "" && person.Email != "":
+			temp = append(temp, fmt.Sprintf("%s <%s>", person.Name, person.Email))
+		case person.Name != "":
+			temp = append(temp, person.Name)
+		case person.Email != "":
+			temp = append(temp, person.Email)
+		}
+	}
+
+	return temp
+}
+
+func getEnclosures(enclosures []*gofeed.Enclosure) []string {
+	temp := make([]string, 0)
+
+	for _, enclosure := range enclosures {
+		if enclosure.URL != "" {
+			temp = append(temp, enclosure.URL)
+		}
+	}
+
+	return temp
+}
+
+func getImage(image *gofeed.Image) string {
+	if image != nil {
+		return image.URL
+	}
+
+	return ""
+}
+
+// getMedia returns urls of Media RSS elements (media:content, media:thumbnail, also inside media:group).
+func getMedia(extensions ext.Extensions) []string {
+	temp := make([]string, 0)
+
+	var walk func(elements map[string][]ext.Extension)
+	walk = func(elements map[string][]ext.Extension) {
+		for _, name := range []string{"content", "thumbnail", "group"} {
+			for _, element := range elements[name] {
+				if v := element.Attrs["url"]; v != "" {
+					temp = append(temp, v)
+				}
+				walk(element.Children)
+			}
+		}
+	}
+
+	walk(extensions["media"])
+
+	return temp
 }
 
 type Plugin struct {
@@ -120,35 +348,40 @@ type Plugin struct {
 
 	Flow *core.Flow
 
+	CacheDir string
+
+	Expire *core.ExpireCheck
+
 	LogFields log.Fields
 
 	PluginName string
 	PluginType string
 
-	OptionExpireAction        []string
-	OptionExpireActionDelay   int64
-	OptionExpireActionTimeout int
-	OptionExpireInterval      int64
-	OptionExpireLast          int64
-	OptionForce               bool
-	OptionForceCount          int
-	OptionInput               []string
-	OptionInputEncoding       string
-	OptionMatchSignature      []string
-	OptionMatchTTL            time.Duration
-	OptionProxy               string
-	OptionProxyURL            *url.URL
-	OptionSSLVerify           bool
-	OptionTimeFormat          string
-	OptionTimeFormatA         string
-	OptionTimeFormatB         string
-	OptionTimeFormatC         string
-	OptionTimeZone            *time.Location
-	OptionTimeZoneA           *time.Location
-	OptionTimeZoneB           *time.Location
-	OptionTimeZoneC           *time.Location
-	OptionTimeout             int
-	OptionUserAgent           string
+	opmlFeeds map[string][]string
+	opmlTime  map[string]time.Time
+
+	OptionDiscover       bool
+	OptionForce          bool
+	OptionForceCount     int
+	OptionInput          []string
+	OptionInputEncoding  string
+	OptionMatchSignature []string
+	OptionMatchTTL       time.Duration
+	OptionOPML           []string
+	OptionOPMLRefresh    time.Duration
+	OptionProxy          string
+	OptionProxyURL       *url.URL
+	OptionSSLVerify      bool
+	OptionTimeFormat     string
+	OptionTimeFormatA    string
+	OptionTimeFormatB    string
+	OptionTimeFormatC    string
+	OptionTimeZone       *time.Location
+	OptionTimeZoneA      *time.Location
+	OptionTimeZoneB      *time.Location
+	OptionTimeZoneC      *time.Location
+	OptionTimeout        int
+	OptionUserAgent      string
 }
 
 func (p *Plugin) FlowLog(message interface{}) {
@@ -169,14 +402,80 @@ func (p *Plugin) FlowLog(message interface{}) {
 	}
 }
 
+// GetInput returns feeds and feeds of loaded OPML files.
 func (p *Plugin) GetInput() []string {
-	return p.OptionInput
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	temp := make([]string, 0)
+	temp = append(temp, p.OptionInput...)
+
+	for _, location := range p.OptionOPML {
+		temp = append(temp, p.opmlFeeds[location]...)
+	}
+
+	return core.UniqueSliceValues(&temp)
 }
 
 func (p *Plugin) GetName() string {
 	return p.PluginName
 }
 
+// LoadCache reads feeds' validators and discovered feeds (state store, might be shared between instances).
+func (p *Plugin) LoadCache() (map[string]feedCache, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]feedCache, 0)
+
+	db, err := core.OpenStateStore(p.CacheDir)
+	if err != nil {
+		return data, err
+	}
+	defer db.Close()
+
+	err = db.Iterate(func(key string, value []byte) error {
+		var v feedCache
+
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+
+		data[key] = v
+
+		return nil
+	})
+
+	return data, err
+}
+
+// LoadOPML returns feeds of OPML file, files are read again after opml_refresh.
+// Previously loaded feeds are returned if OPML file is unavailable.
+func (p *Plugin) LoadOPML(location string) ([]string, error) {
+	p.m.Lock()
+	feeds := p.opmlFeeds[location]
+	loaded, ok := p.opmlTime[location]
+	p.m.Unlock()
+
+	if ok && time.Since(loaded) < p.OptionOPMLRefresh {
+		return feeds, nil
+	}
+
+	temp, err := loadOPML(p, location)
+	if err != nil {
+		return feeds, err
+	}
+
+	p.m.Lock()
+	p.opmlFeeds[location] = temp
+	p.opmlTime[location] = time.Now()
+	p.m.Unlock()
+
+	core.LogInputPlugin(p.LogFields, location, fmt.Sprintf("opml feeds: %d", len(temp)))
+
+	return temp, nil
+}
+
 func (p *Plugin) LoadState() (map[string]time.Time, error) {
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -203,13 +502,42 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	}
 	core.LogInputPlugin(p.LogFields, "all", fmt.Sprintf("states loaded: %d", len(flowStates)))
 
+	// Load feeds' validators.
+	cache, err := p.LoadCache()
+	if err != nil {
+		return temp, err
+	}
+
 	// Source stat.
 	sourceNewStat := make(map[string]int32)
 
+	// Sources: feeds and feeds from OPML files.
+	sources := make([]string, 0)
+	sources = append(sources, p.OptionInput...)
+
+	for _, location := range p.OptionOPML {
+		feeds, err := p.LoadOPML(location)
+		if err != nil {
+			failedSources = append(failedSources, location)
+			core.LogInputPlugin(p.LogFields, location, err)
+		}
+
+		sources = append(sources, feeds...)
+	}
+
+	sources = core.UniqueSliceValues(&sources)
+
 	// Fetch data from sources.
-	for _, source := range p.OptionInput {
+	for _, source := range sources {
 		var sourceLastTime time.Time
 
+		// Skip sources with backoff/quarantine.
+		if !p.Flow.GetHealth().Allow(source) {
+			core.LogInputPlugin(p.LogFields, source,
+				fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+			continue
+		}
+
 		// Check if we work with source first time.
 		if v, ok := flowStates[source]; ok {
 			sourceLastTime = v
@@ -218,12 +546,41 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		}
 
 		// Try to fetch new articles.
-		feeds, err := fetchFeed(p, source)
+		// Site url might be used instead of feed url, feed url is discovered once.
+		sourceCache := cache[source]
+		sourceFeed := source
+
+		if sourceCache.Feed != "" {
+			sourceFeed = sourceCache.Feed
+		}
+
+		feeds, notModified, err := fetchFeed(p, sourceFeed, &sourceCache)
+
+		if errors.Is(err, gofeed.ErrFeedTypeNotDetected) && p.OptionDiscover && sourceCache.Feed == "" {
+			if sourceCache.Feed, err = discoverFeed(p, source); err == nil {
+				core.LogInputPlugin(p.LogFields, source, fmt.Sprintf("feed discovered: %s", sourceCache.Feed))
+				feeds, notModified, err = fetchFeed(p, sourceCache.Feed, &sourceCache)
+			}
+		}
+
 		if feeds == nil || err != nil {
 			failedSources = append(failedSources, source)
+			p.Flow.GetHealth().Fail(source, err)
 			core.LogInputPlugin(p.LogFields, source, err)
+
+			// Discover feed again next time.
+			cache[source] = feedCache{}
+
 			continue
 		}
+		p.Flow.GetHealth().Success(source)
+
+		if notModified {
+			core.LogInputPlugin(p.LogFields, source, fmt.Sprintf("last update: %s, not modified", sourceLastTime))
+			continue
+		}
+
+		cache[source] = sourceCache
 
 		// Process only specific amount of articles from every source if force = true.
 		var start = 0
@@ -313,12 +670,21 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 					UUID:        u,
 
 					RSS: core.Rss{
+						AUTHORS:     getAuthors(item.Authors),
 						CATEGORIES:  item.Categories,
 						CONTENT:     item.Content,
 						DESCRIPTION: item.Description,
+						ENCLOSURES:  getEnclosures(item.Enclosures),
+						FEEDIMAGE:   getImage(feeds.Image),
+						FEEDLINK:    feeds.Link,
+						FEEDTITLE:   feeds.Title,
 						GUID:        item.GUID,
+						IMAGE:       getImage(item.Image),
 						LINK:        item.Link,
+						MEDIA:       getMedia(item.Extensions),
+						PUBLISHED:   item.Published,
 						TITLE:       item.Title,
+						UPDATED:     item.Updated,
 					},
 
 					WARNINGS: make([]string, 0),
@@ -340,41 +706,14 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		return temp, err
 	}
 
-	// Check every source for expiration.
-	sourcesExpired := false
-
-	// Check if any source is expired.
-	for _, source := range p.OptionInput {
-		sourceTime := flowStates[source]
-
-		if (currentTime.Unix() - sourceTime.Unix()) > p.OptionExpireInterval/1000 {
-			sourcesExpired = true
-
-			core.LogInputPlugin(p.LogFields, source,
-				fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
-
-			// Execute command if expire delay exceeded.
-			// ExpireLast keeps last execution timestamp.
-			if (currentTime.Unix() - p.OptionExpireLast) > p.OptionExpireActionDelay/1000 {
-				p.OptionExpireLast = currentTime.Unix()
-
-				// Execute command with args.
-				// We don't worry about command return code.
-				if len(p.OptionExpireAction) > 0 {
-					cmd := p.OptionExpireAction[0]
-					args := []string{p.Flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
-					args = append(args, p.OptionExpireAction[1:]...)
-
-					output, err := core.ExecWithTimeout(cmd, args, p.OptionExpireActionTimeout)
-
-					core.LogInputPlugin(p.LogFields, source, fmt.Sprintf(
-						"source expired action: command: %s, arguments: %v, output: %s, error: %v",
-						cmd, args, output, err))
-				}
-			}
-		}
+	// Save feeds' validators.
+	if err := p.SaveCache(cache); err != nil {
+		return temp, err
 	}
 
+	// Check every source for expiration.
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, sources, flowStates)
+
 	// Inform about sources failures.
 	if len(failedSources) > 0 {
 		return temp, core.ERROR_FLOW_SOURCE_FAIL
@@ -388,6 +727,35 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	return temp, nil
 }
 
+// SaveCache writes feeds' validators and discovered feeds, empty values are removed.
+func (p *Plugin) SaveCache(data map[string]feedCache) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	db, err := core.OpenStateStore(p.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	for source, v := range data {
+		if v == (feedCache{}) {
+			err = db.Delete(source)
+		} else {
+			var b []byte
+			if b, err = json.Marshal(v); err == nil {
+				err = db.Put(source, b, 0)
+			}
+		}
+
+		if err != nil {
+			_ = db.Close()
+			return err
+		}
+	}
+
+	return db.Close()
+}
+
 func (p *Plugin) SaveState(data map[string]time.Time) error {
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -399,7 +767,8 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	// -----------------------------------------------------------------------------------------------------------------
 
 	plugin := Plugin{
-		Flow: pluginConfig.Flow,
+		Flow:     pluginConfig.Flow,
+		CacheDir: filepath.Join(pluginConfig.Flow.FlowDataDir, pluginConfig.PluginType, PLUGIN_NAME, DEFAULT_CACHE_DIR),
 		LogFields: log.Fields{
 			"hash":   pluginConfig.Flow.FlowHash,
 			"run":    pluginConfig.Flow.GetRunID(),
@@ -408,9 +777,10 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 			"plugin": PLUGIN_NAME,
 			"type":   pluginConfig.PluginType,
 		},
-		PluginName:       PLUGIN_NAME,
-		PluginType:       pluginConfig.PluginType,
-		OptionExpireLast: 0,
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		opmlFeeds:  make(map[string][]string, 0),
+		opmlTime:   make(map[string]time.Time, 0),
 	}
 
 	// -----------------------------------------------------------------------------------------------------------------
@@ -438,10 +808,13 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		"time_zone_c":           -1,
 		"timeout":               -1,
 
-		"input":           1,
+		"discover":        -1,
+		"input":           -1,
 		"input_encoding":  -1,
 		"match_signature": -1,
 		"match_ttl":       -1,
+		"opml":            -1,
+		"opml_refresh":    -1,
 		"proxy":           -1,
 		"user_agent":      -1,
 	}
@@ -453,53 +826,20 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 
 	// -----------------------------------------------------------------------------------------------------------------
 
-	// expire_action.
-	setExpireAction := func(p interface{}) {
-		if v, b := core.IsSliceOfString(p); b {
-			availableParams["expire_action"] = 0
-			plugin.OptionExpireAction = v
-		}
-	}
-	setExpireAction(pluginConfig.AppConfig.GetStringSlice(core.VIPER_DEFAULT_EXPIRE_ACTION))
-	setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
-	setExpireAction((*pluginConfig.PluginParams)["expire_action"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action", plugin.OptionExpireAction)
-
-	// expire_action_delay.
-	setExpireActionDelay := func(p interface{}) {
-		if v, b := core.IsInterval(p); b {
-			availableParams["expire_action_delay"] = 0
-			plugin.OptionExpireActionDelay = v
-		}
-	}
-	setExpireActionDelay(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
-	setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
-	setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action_delay", plugin.OptionExpireActionDelay)
-
-	// expire_action_timeout.
-	setExpireActionTimeout := func(p interface{}) {
-		if v, b := core.IsInt(p); b {
-			availableParams["expire_action_timeout"] = 0
-			plugin.OptionExpireActionTimeout = v
-		}
-	}
-	setExpireActionTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
-	setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
-	setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action_timeout", plugin.OptionExpireActionTimeout)
+	// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+	plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
 
-	// expire_interval.
-	setExpireInterval := func(p interface{}) {
-		if v, b := core.IsInterval(p); b {
-			availableParams["expire_interval"] = 0
-			plugin.OptionExpireInterval = v
+	// discover.
+	setDiscover := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["discover"] = 0
+			plugin.OptionDiscover = v
 		}
 	}
-	setExpireInterval(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_INTERVAL))
-	setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
-	setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
-	core.ShowPluginParam(plugin.LogFields, "expire_interval", plugin.OptionExpireInterval)
+	setDiscover(DEFAULT_DISCOVER)
+	setDiscover(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.discover", template)))
+	setDiscover((*pluginConfig.PluginParams)["discover"])
+	core.ShowPluginParam(plugin.LogFields, "discover", plugin.OptionDiscover)
 
 	// force.
 	setForce := func(p interface{}) {
@@ -572,6 +912,29 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	setMatchTTL((*pluginConfig.PluginParams)["match_ttl"])
 	core.ShowPluginParam(plugin.LogFields, "match_ttl", plugin.OptionMatchTTL)
 
+	// opml.
+	setOPML := func(p interface{}) {
+		if v, b := core.IsSliceOfString(p); b {
+			availableParams["opml"] = 0
+			plugin.OptionOPML = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+		}
+	}
+	setOPML(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.opml", template)))
+	setOPML((*pluginConfig.PluginParams)["opml"])
+	core.ShowPluginParam(plugin.LogFields, "opml", plugin.OptionOPML)
+
+	// opml_refresh.
+	setOPMLRefresh := func(p interface{}) {
+		if v, b := core.IsInterval(p); b {
+			availableParams["opml_refresh"] = 0
+			plugin.OptionOPMLRefresh = time.Duration(v) * time.Millisecond
+		}
+	}
+	setOPMLRefresh(DEFAULT_OPML_REFRESH)
+	setOPMLRefresh(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.opml_refresh", template)))
+	setOPMLRefresh((*pluginConfig.PluginParams)["opml_refresh"])
+	core.ShowPluginParam(plugin.LogFields, "opml_refresh", plugin.OptionOPMLRefresh)
+
 	// proxy.
 	setProxy := func(p interface{}) {
 		if v, b := core.IsString(p); b {
@@ -725,6 +1088,10 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	// -----------------------------------------------------------------------------------------------------------------
 	// Additional checks.
 
+	if len(plugin.OptionInput) == 0 && len(plugin.OptionOPML) == 0 {
+		return &Plugin{}, fmt.Errorf(core.ERROR_PLUGIN_REQUIRED_PARAM.Error(), []string{"input", "opml"})
+	}
+
 	if plugin.OptionProxy != "" {
 		if proxyUrl, err := url.Parse(plugin.OptionProxy); err == nil {
 			plugin.OptionProxyURL = proxyUrl
@@ -106,6 +106,8 @@ type Plugin struct {
 
 	Flow *core.Flow
 
+	Expire *core.ExpireCheck
+
 	LogFields log.Fields
 
 	PluginName string
@@ -115,11 +117,6 @@ type Plugin struct {
 	OptionAccessToken         string
 	OptionConsumerKey         string
 	OptionConsumerSecret      string
-	OptionExpireAction        []string
-	OptionExpireActionDelay   int64
-	OptionExpireActionTimeout int
-	OptionExpireInterval      int64
-	OptionExpireLast          int64
 	OptionForce               bool
 	OptionForceCount          int
 	OptionInput               []string
@@ -321,39 +318,7 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	}
 
 	// Check every source for expiration.
-	sourcesExpired := false
-	
-    // Check if any source is expired.
-    for _, source := range p.OptionInput {
-        sourceTime := flowStates[source]
-		
-        if (currentTime.Unix() - sourceTime.Unix()) > p.OptionExpireInterval / 1000 {
-			sourcesExpired = true
-            
-            core.LogInputPlugin(p.LogFields, source, 
-                fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
-
-			// Execute command if expire delay exceeded.
-			// ExpireLast keeps last execution timestamp.
-			if (currentTime.Unix() - p.OptionExpireLast) > p.OptionExpireActionDelay / 1000 {
-				p.OptionExpireLast = currentTime.Unix()
-
-				// Execute command with args.
-				// We don't worry about command return code.
-				if len(p.OptionExpireAction) > 0 {
-					cmd := p.OptionExpireAction[0]
-					args := []string{p.Flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
-					args = append(args, p.OptionExpireAction[1:]...)
-
-					output, err := core.ExecWithTimeout(cmd, args, p.OptionExpireActionTimeout)
-
-					core.LogInputPlugin(p.LogFields, source, fmt.Sprintf(
-						"source expired action: command: %s, arguments: %v, output: %s, error: %v",
-						cmd, args, output, err))
-				}
-			}
-		}
-    }
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
 
 	// Inform about sources failures.
 	if len(failedSources) > 0 {
@@ -390,7 +355,6 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		},
 		PluginName:       PLUGIN_NAME,
 		PluginType:       pluginConfig.PluginType,
-		OptionExpireLast: 0,
 	}
 
 	// -----------------------------------------------------------------------------------------------------------------
@@ -401,8 +365,8 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 
 	availableParams := map[string]int{
 		"expire_action":         -1,
+		"expire_action_delay":   -1,
 		"expire_action_timeout": -1,
-		"expire_delay":          -1,
 		"expire_interval":       -1,
 		"force":                 -1,
 		"force_count":           -1,
@@ -483,53 +447,8 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 
 	// -----------------------------------------------------------------------------------------------------------------
 
-	// expire_action.
-	setExpireAction := func(p interface{}) {
-		if v, b := core.IsSliceOfString(p); b {
-			availableParams["expire_action"] = 0
-			plugin.OptionExpireAction = v
-		}
-	}
-	setExpireAction(pluginConfig.AppConfig.GetStringSlice(core.VIPER_DEFAULT_EXPIRE_ACTION))
-	setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
-	setExpireAction((*pluginConfig.PluginParams)["expire_action"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action", plugin.OptionExpireAction)
-
-	// expire_action_delay.
-	setExpireActionDelay := func(p interface{}) {
-		if v, b := core.IsInterval(p); b {
-			availableParams["expire_action_delay"] = 0
-			plugin.OptionExpireActionDelay = v
-		}
-	}
-	setExpireActionDelay(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
-	setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
-	setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action_delay", plugin.OptionExpireActionDelay)
-
-	// expire_action_timeout.
-	setExpireActionTimeout := func(p interface{}) {
-		if v, b := core.IsInt(p); b {
-			availableParams["expire_action_timeout"] = 0
-			plugin.OptionExpireActionTimeout = v
-		}
-	}
-	setExpireActionTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
-	setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
-	setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
-	core.ShowPluginParam(plugin.LogFields, "expire_action_timeout", plugin.OptionExpireActionTimeout)
-
-	// expire_interval.
-	setExpireInterval := func(p interface{}) {
-		if v, b := core.IsInterval(p); b {
-			availableParams["expire_interval"] = 0
-			plugin.OptionExpireInterval = v
-		}
-	}
-	setExpireInterval(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_INTERVAL))
-	setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
-	setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
-	core.ShowPluginParam(plugin.LogFields, "expire_interval", plugin.OptionExpireInterval)
+	// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+	plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
 
 	// force.
 	setForce := func(p interface{}) {
@@ -0,0 +1,792 @@
+package webhookIn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+)
+
+const (
+	PLUGIN_NAME = "webhook"
+
+	DEFAULT_BODY_LIMIT  = 10 * 1024 * 1024
+	DEFAULT_BUFFER_SIZE = 1000
+	DEFAULT_LISTEN      = ":8081"
+	DEFAULT_SECRET_MODE = "hmac"
+	DEFAULT_SPLIT       = "."
+	DEFAULT_TRIGGER     = false
+)
+
+var (
+	ERROR_BUFFER_FULL         = errors.New("buffer is full: %d")
+	ERROR_PATH_IN_USE         = errors.New("path already in use: %s")
+	ERROR_PAYLOAD_INVALID     = errors.New("payload invalid: %s")
+	ERROR_SECRET_EMPTY        = errors.New("secret is set, but resolved to empty value")
+	ERROR_SECRET_INVALID      = errors.New("secret invalid")
+	ERROR_SECRET_MODE_UNKNOWN = errors.New("secret mode unknown: %s")
+)
+
+var (
+	servers     = make(map[string]*http.ServeMux)
+	serversLock sync.Mutex
+	serversPath = make(map[string]bool)
+)
+
+// registerHandler adds handler to shared listener, so several flows may use the same address with different paths.
+func registerHandler(listen string, path string, handler http.Handler) error {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	if serversPath[listen+path] {
+		return fmt.Errorf(ERROR_PATH_IN_USE.Error(), listen+path)
+	}
+
+	mux, ok := servers[listen]
+	if !ok {
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+
+		mux = http.NewServeMux()
+		servers[listen] = mux
+
+		go func() {
+			_ = http.Serve(listener, mux)
+		}()
+	}
+
+	mux.Handle(path, handler)
+	serversPath[listen+path] = true
+
+	return nil
+}
+
+func convertValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+func parsePayload(contentType string, body []byte) (interface{}, error) {
+	var payload interface{}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return payload, err
+		}
+
+		temp := make(map[string]interface{}, len(values))
+
+		for k, v := range values {
+			if len(v) == 1 {
+				temp[k] = v[0]
+			} else {
+				a := make([]interface{}, 0, len(v))
+				for _, s := range v {
+					a = append(a, s)
+				}
+				temp[k] = a
+			}
+		}
+
+		payload = temp
+
+	default:
+		if len(body) == 0 {
+			return payload, nil
+		}
+
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return payload, err
+		}
+	}
+
+	return payload, nil
+}
+
+func runQuery(query *gojq.Query, payload interface{}) ([]interface{}, error) {
+	temp := make([]interface{}, 0)
+
+	iter := query.Run(payload)
+
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return temp, err
+		}
+
+		temp = append(temp, v)
+	}
+
+	return temp, nil
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	Buffer  []*core.Datum
+	Pending []*core.Datum
+
+	OptionBufferSize   int
+	OptionFields       map[string]string
+	OptionFieldsQuery  map[string]*gojq.Query
+	OptionInput        []string
+	OptionListen       string
+	OptionPath         string
+	OptionSecret       string
+	OptionSecretHeader string
+	OptionSecretMode   string
+	OptionSplit        string
+	OptionSplitQuery   *gojq.Query
+	OptionTimeFormat   string
+	OptionTimeFormatA  string
+	OptionTimeFormatB  string
+	OptionTimeFormatC  string
+	OptionTimeZone     *time.Location
+	OptionTimeZoneA    *time.Location
+	OptionTimeZoneB    *time.Location
+	OptionTimeZoneC    *time.Location
+	OptionTrigger      bool
+}
+
+// Commit forgets data of successful flow run.
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.Pending = make([]*core.Datum, 0)
+
+	return nil
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+
+	if err := core.PluginLoadState(p.Flow.FlowStateDir, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	// Take buffered data, data are kept until flow run is finished (see Commit/Rollback).
+	p.m.Lock()
+	temp := p.Buffer
+	p.Buffer = make([]*core.Datum, 0)
+	p.Pending = append(p.Pending, temp...)
+	p.m.Unlock()
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+
+	source := p.OptionPath
+
+	for _, item := range temp {
+		if item.TIME.After(flowStates[source]) {
+			flowStates[source] = item.TIME
+		}
+	}
+
+	core.LogInputPlugin(p.LogFields, source,
+		fmt.Sprintf("last update: %s, received data: %d", flowStates[source], len(temp)))
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check source for expiration.
+	if p.Expire.Check(p.Flow, p.LogFields, []string{source}, flowStates) {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback returns data of failed flow run back into buffer (requests were already accepted).
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.Buffer = append(p.Pending, p.Buffer...)
+	p.Pending = make([]*core.Datum, 0)
+
+	return nil
+}
+
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, DEFAULT_BODY_LIMIT))
+	if err != nil {
+		core.LogInputPlugin(p.LogFields, p.OptionPath, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Verify request.
+	if !p.verify(r, body) {
+		core.LogInputPlugin(p.LogFields, p.OptionPath, fmt.Errorf("%s: %s", ERROR_SECRET_INVALID, r.RemoteAddr))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	data, err := p.parseRequest(r, body)
+	if err != nil {
+		core.LogInputPlugin(p.LogFields, p.OptionPath, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Keep data until next receive.
+	p.m.Lock()
+	if len(p.Buffer)+len(data) > p.OptionBufferSize {
+		p.m.Unlock()
+		core.LogInputPlugin(p.LogFields, p.OptionPath, fmt.Errorf(ERROR_BUFFER_FULL.Error(), p.OptionBufferSize))
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	p.Buffer = append(p.Buffer, data...)
+	p.m.Unlock()
+
+	// Run flow immediately.
+	if p.OptionTrigger {
+		p.Flow.Trigger()
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (p *Plugin) parseRequest(r *http.Request, body []byte) ([]*core.Datum, error) {
+	temp := make([]*core.Datum, 0)
+	currentTime := time.Now().UTC()
+
+	payload, err := parsePayload(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		return temp, err
+	}
+
+	items, err := runQuery(p.OptionSplitQuery, payload)
+	if err != nil {
+		return temp, err
+	}
+
+	// Credentials (secret header, authorization, cookies) mustn't leak into data.
+	skip := []string{http.CanonicalHeaderKey(p.OptionSecretHeader), "Authorization", "Cookie"}
+
+	headers := make([]string, 0)
+	for k, v := range r.Header {
+		if core.IsValueInSlice(k, &skip) {
+			continue
+		}
+		headers = append(headers, fmt.Sprintf("%s: %s", k, strings.Join(v, ", ")))
+	}
+	sort.Strings(headers)
+
+	for _, payloadItem := range items {
+		var u, _ = uuid.NewRandom()
+
+		item := &core.Datum{
+			FLOW:        p.Flow.FlowName,
+			PLUGIN:      p.PluginName,
+			SOURCE:      p.OptionPath,
+			TIME:        currentTime,
+			TIMEFORMAT:  currentTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+			TIMEFORMATA: currentTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+			TIMEFORMATB: currentTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+			TIMEFORMATC: currentTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+			UUID:        u,
+
+			WEBHOOK: core.Webhook{
+				BODY:    convertValue(payloadItem),
+				HEADERS: headers,
+				METHOD:  r.Method,
+				PATH:    r.URL.Path,
+				QUERY:   r.URL.RawQuery,
+				REMOTE:  r.RemoteAddr,
+			},
+
+			WARNINGS: make([]string, 0),
+		}
+
+		// Map payload into datum fields.
+		for field, query := range p.OptionFieldsQuery {
+			values, err := runQuery(query, payloadItem)
+			if err != nil {
+				return temp, err
+			}
+
+			rv, _ := core.ReflectDatumField(item, field)
+
+			switch rv.Kind() {
+			case reflect.String:
+				if len(values) > 0 {
+					rv.SetString(convertValue(values[0]))
+				}
+			case reflect.Slice:
+				for _, v := range values {
+					rv.Set(reflect.Append(rv, reflect.ValueOf(convertValue(v))))
+				}
+			}
+		}
+
+		temp = append(temp, item)
+	}
+
+	return temp, nil
+}
+
+func (p *Plugin) verify(r *http.Request, body []byte) bool {
+	if p.OptionSecret == "" {
+		return true
+	}
+
+	value := r.Header.Get(p.OptionSecretHeader)
+
+	switch p.OptionSecretMode {
+	case "hmac":
+		signature, err := hex.DecodeString(value[strings.Index(value, "=")+1:])
+		if err != nil {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, []byte(p.OptionSecret))
+		mac.Write(body)
+
+		return hmac.Equal(signature, mac.Sum(nil))
+
+	case "token":
+		value = strings.TrimPrefix(value, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(value), []byte(p.OptionSecret)) == 1
+	}
+
+	return false
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		Buffer:     make([]*core.Datum, 0),
+		Pending:    make([]*core.Datum, 0),
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// "0" - will be set if parameter is set somehow (defaults, template, config etc.).
+	availableParams := map[string]int{
+		"cred":     -1,
+		"template": -1,
+
+		"buffer_size":           -1,
+		"expire_action":         -1,
+		"expire_action_delay":   -1,
+		"expire_action_timeout": -1,
+		"expire_interval":       -1,
+		"fields":                -1,
+		"listen":                -1,
+		"path":                  -1,
+		"secret":                -1,
+		"secret_header":         -1,
+		"secret_mode":           -1,
+		"split":                 -1,
+		"time_format":           -1,
+		"time_format_a":         -1,
+		"time_format_b":         -1,
+		"time_format_c":         -1,
+		"time_zone":             -1,
+		"time_zone_a":           -1,
+		"time_zone_b":           -1,
+		"time_zone_c":           -1,
+		"trigger":               -1,
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// secret.
+	setSecret := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["secret"] = 0
+			plugin.OptionSecret = core.GetCredValue(v, vault)
+		}
+	}
+	setSecret(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.secret", cred)))
+	setSecret((*pluginConfig.PluginParams)["secret"])
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// buffer_size.
+	setBufferSize := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["buffer_size"] = 0
+			plugin.OptionBufferSize = v
+		}
+	}
+	setBufferSize(DEFAULT_BUFFER_SIZE)
+	setBufferSize(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.buffer_size", template)))
+	setBufferSize((*pluginConfig.PluginParams)["buffer_size"])
+	core.ShowPluginParam(plugin.LogFields, "buffer_size", plugin.OptionBufferSize)
+
+	// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+	plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+	// fields.
+	templateFields, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.fields", template)))
+	configFields, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["fields"])
+
+	mergedFields := make(map[string]string, 0)
+	mergedFieldsQuery := make(map[string]*gojq.Query, 0)
+
+	for k, v := range templateFields {
+		mergedFields[k] = fmt.Sprintf("%s", v)
+	}
+
+	for k, v := range configFields {
+		mergedFields[k] = fmt.Sprintf("%s", v)
+	}
+
+	for k, v := range mergedFields {
+		if _, err := core.ReflectDatumField(&core.Datum{}, k); err != nil {
+			return &Plugin{}, err
+		}
+
+		query, err := gojq.Parse(v)
+		if err != nil {
+			return &Plugin{}, err
+		}
+
+		mergedFieldsQuery[k] = query
+	}
+
+	if len(mergedFields) > 0 {
+		availableParams["fields"] = 0
+	}
+
+	plugin.OptionFields = mergedFields
+	plugin.OptionFieldsQuery = mergedFieldsQuery
+	core.ShowPluginParam(plugin.LogFields, "fields", plugin.OptionFields)
+
+	// listen.
+	setListen := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["listen"] = 0
+			plugin.OptionListen = v
+		}
+	}
+	setListen(DEFAULT_LISTEN)
+	setListen(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.listen", template)))
+	setListen((*pluginConfig.PluginParams)["listen"])
+	core.ShowPluginParam(plugin.LogFields, "listen", plugin.OptionListen)
+
+	// path.
+	setPath := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["path"] = 0
+			plugin.OptionPath = "/" + strings.TrimPrefix(v, "/")
+		}
+	}
+	setPath(pluginConfig.Flow.FlowName)
+	setPath(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.path", template)))
+	setPath((*pluginConfig.PluginParams)["path"])
+	core.ShowPluginParam(plugin.LogFields, "path", plugin.OptionPath)
+
+	// secret_header.
+	setSecretHeader := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["secret_header"] = 0
+			plugin.OptionSecretHeader = v
+		}
+	}
+	setSecretHeader(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.secret_header", template)))
+	setSecretHeader((*pluginConfig.PluginParams)["secret_header"])
+
+	// secret_mode.
+	setSecretMode := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["secret_mode"] = 0
+			plugin.OptionSecretMode = v
+		}
+	}
+	setSecretMode(DEFAULT_SECRET_MODE)
+	setSecretMode(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.secret_mode", template)))
+	setSecretMode((*pluginConfig.PluginParams)["secret_mode"])
+	core.ShowPluginParam(plugin.LogFields, "secret_mode", plugin.OptionSecretMode)
+
+	// split.
+	setSplit := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["split"] = 0
+			plugin.OptionSplit = v
+		}
+	}
+	setSplit(DEFAULT_SPLIT)
+	setSplit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.split", template)))
+	setSplit((*pluginConfig.PluginParams)["split"])
+	core.ShowPluginParam(plugin.LogFields, "split", plugin.OptionSplit)
+
+	if plugin.OptionSplitQuery, err = gojq.Parse(plugin.OptionSplit); err != nil {
+		return &Plugin{}, err
+	}
+
+	// time_format.
+	setTimeFormat := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format"] = 0
+			plugin.OptionTimeFormat = v
+		}
+	}
+	setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+	setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+	core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+	// time_format_a.
+	setTimeFormatA := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_a"] = 0
+			plugin.OptionTimeFormatA = v
+		}
+	}
+	setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+	setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+	// time_format_b.
+	setTimeFormatB := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_b"] = 0
+			plugin.OptionTimeFormatB = v
+		}
+	}
+	setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+	setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+	// time_format_c.
+	setTimeFormatC := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["time_format_c"] = 0
+			plugin.OptionTimeFormatC = v
+		}
+	}
+	setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+	setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+	setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+	core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+	// time_zone.
+	setTimeZone := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone"] = 0
+			plugin.OptionTimeZone = v
+		}
+	}
+	setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+	setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+	// time_zone_a.
+	setTimeZoneA := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_a"] = 0
+			plugin.OptionTimeZoneA = v
+		}
+	}
+	setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+	setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+	// time_zone_b.
+	setTimeZoneB := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_b"] = 0
+			plugin.OptionTimeZoneB = v
+		}
+	}
+	setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+	setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+	// time_zone_c.
+	setTimeZoneC := func(p interface{}) {
+		if v, b := core.IsTimeZone(p); b {
+			availableParams["time_zone_c"] = 0
+			plugin.OptionTimeZoneC = v
+		}
+	}
+	setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+	setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+	setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+	core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+	// trigger.
+	setTrigger := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["trigger"] = 0
+			plugin.OptionTrigger = v
+		}
+	}
+	setTrigger(DEFAULT_TRIGGER)
+	setTrigger(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.trigger", template)))
+	setTrigger((*pluginConfig.PluginParams)["trigger"])
+	core.ShowPluginParam(plugin.LogFields, "trigger", plugin.OptionTrigger)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	// Don't accept unauthenticated requests if secret wasn't resolved (unset env, vault errors etc.).
+	if availableParams["secret"] == 0 && plugin.OptionSecret == "" {
+		return &Plugin{}, ERROR_SECRET_EMPTY
+	}
+
+	switch plugin.OptionSecretMode {
+	case "hmac":
+		if plugin.OptionSecretHeader == "" {
+			plugin.OptionSecretHeader = "X-Hub-Signature-256"
+		}
+	case "token":
+		if plugin.OptionSecretHeader == "" {
+			plugin.OptionSecretHeader = "Authorization"
+		}
+	default:
+		return &Plugin{}, fmt.Errorf(ERROR_SECRET_MODE_UNKNOWN.Error(), plugin.OptionSecretMode)
+	}
+	core.ShowPluginParam(plugin.LogFields, "secret_header", plugin.OptionSecretHeader)
+
+	plugin.OptionInput = []string{plugin.OptionPath}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Start listening.
+
+	if err := registerHandler(plugin.OptionListen, plugin.OptionPath, &plugin); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
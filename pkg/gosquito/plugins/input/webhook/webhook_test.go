@@ -0,0 +1,50 @@
+package webhookIn
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchyny/gojq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+)
+
+func TestParseRequestHeaders(t *testing.T) {
+	query, _ := gojq.Parse(".")
+
+	p := &Plugin{
+		Flow:               &core.Flow{FlowName: "webhook"},
+		OptionPath:         "/hook",
+		OptionSecretHeader: "X-Gitea-Token",
+		OptionSplitQuery:   query,
+		OptionTimeZone:     time.UTC,
+		OptionTimeZoneA:    time.UTC,
+		OptionTimeZoneB:    time.UTC,
+		OptionTimeZoneC:    time.UTC,
+	}
+
+	body := `{"a": 1}`
+
+	r := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("X-Event", "push")
+	r.Header.Set("x-gitea-token", "secret")
+
+	data, err := p.parseRequest(r, []byte(body))
+	if err != nil || len(data) != 1 {
+		t.Fatalf(`parseRequest() = %v, %v`, data, err)
+	}
+
+	headers := strings.Join(data[0].WEBHOOK.HEADERS, "\n")
+
+	if strings.Contains(headers, "secret") {
+		t.Errorf(`parseRequest() headers contain credentials: %v`, data[0].WEBHOOK.HEADERS)
+	}
+
+	if !strings.Contains(headers, "X-Event: push") {
+		t.Errorf(`parseRequest() headers = %v`, data[0].WEBHOOK.HEADERS)
+	}
+}
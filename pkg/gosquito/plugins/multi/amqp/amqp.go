@@ -0,0 +1,942 @@
+package amqpMulti
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	tmpl "text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	PLUGIN_NAME = "amqp"
+
+	DEFAULT_BUFFER_SIZE = 1000
+	DEFAULT_PERSISTENT  = true
+	DEFAULT_REQUEUE     = true
+	DEFAULT_SERVER      = "amqp://127.0.0.1:5672/"
+	DEFAULT_SSL_VERIFY  = true
+	DEFAULT_TIMEOUT     = 10
+)
+
+var (
+	ERROR_ACK_ERROR        = errors.New("cannot ack message: %v")
+	ERROR_CONNECT_ERROR    = errors.New("cannot connect: %v")
+	ERROR_GET_ERROR        = errors.New("cannot get message: %v")
+	ERROR_PAYLOAD_INVALID  = errors.New("payload invalid: %v")
+	ERROR_PUBLISH_ERROR    = errors.New("cannot publish: %v")
+	ERROR_PUBLISH_NACK     = errors.New("message not confirmed by server")
+	ERROR_SERVER_UNKNOWN   = errors.New("server invalid: %v")
+	ERROR_TEMPLATE_INVALID = errors.New("template invalid: %v")
+)
+
+// connect (re)creates connection and channel if they were closed.
+func connect(p *Plugin) error {
+	if p.Channel != nil && !p.Channel.IsClosed() {
+		return nil
+	}
+
+	if p.Conn == nil || p.Conn.IsClosed() {
+		config := amqp.Config{
+			Dial:            amqp.DefaultDial(time.Duration(p.OptionTimeout) * time.Second),
+			Heartbeat:       10 * time.Second,
+			Properties:      amqp.Table{"connection_name": fmt.Sprintf("%s-%s", p.Flow.FlowName, p.PluginType)},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: !p.OptionSSLVerify},
+		}
+
+		// Credentials override server URL.
+		if p.OptionUsername != "" {
+			config.SASL = []amqp.Authentication{&amqp.PlainAuth{Username: p.OptionUsername, Password: p.OptionPassword}}
+		}
+
+		conn, err := amqp.DialConfig(p.OptionServer, config)
+		if err != nil {
+			return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+		}
+
+		p.Conn = conn
+	}
+
+	channel, err := p.Conn.Channel()
+	if err != nil {
+		return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+	}
+
+	// Output waits for server confirmation of every message.
+	if p.PluginType == "output" {
+		if err := channel.Confirm(false); err != nil {
+			return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+		}
+	}
+
+	p.Channel = channel
+
+	return nil
+}
+
+func convertValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+func runQuery(query *gojq.Query, payload interface{}) ([]interface{}, error) {
+	temp := make([]interface{}, 0)
+
+	iter := query.Run(payload)
+
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return temp, err
+		}
+
+		temp = append(temp, v)
+	}
+
+	return temp, nil
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	Channel *amqp.Channel
+	Conn    *amqp.Connection
+	Pending []amqp.Delivery
+
+	OptionBufferSize         int
+	OptionContentType        string
+	OptionFields             map[string]string
+	OptionFieldsQuery        map[string]*gojq.Query
+	OptionHeaders            map[string]string
+	OptionHeadersTemplate    map[string]*tmpl.Template
+	OptionInput              []string
+	OptionOutput             []string
+	OptionOutputTemplate     []*tmpl.Template
+	OptionPassword           string
+	OptionPayload            string
+	OptionPayloadTemplate    *tmpl.Template
+	OptionPersistent         bool
+	OptionRequeue            bool
+	OptionRoutingKey         string
+	OptionRoutingKeyTemplate *tmpl.Template
+	OptionSSLVerify          bool
+	OptionServer             string
+	OptionTimeFormat         string
+	OptionTimeFormatA        string
+	OptionTimeFormatB        string
+	OptionTimeFormatC        string
+	OptionTimeZone           *time.Location
+	OptionTimeZoneA          *time.Location
+	OptionTimeZoneB          *time.Location
+	OptionTimeZoneC          *time.Location
+	OptionTimeout            int
+	OptionUsername           string
+}
+
+// Commit acknowledges messages after successful flow run.
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	pending := p.Pending
+	p.Pending = make([]amqp.Delivery, 0)
+	p.m.Unlock()
+
+	for _, d := range pending {
+		if err := d.Ack(false); err != nil {
+			// Server returns unacknowledged messages to queues if channel was closed.
+			return fmt.Errorf(ERROR_ACK_ERROR.Error(), err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+
+	if err := core.PluginLoadState(p.Flow.FlowStateDir, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	temp := make([]*core.Datum, 0)
+
+	if err := connect(p); err != nil {
+		return temp, err
+	}
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+
+	// Get messages from every queue, messages are acknowledged after flow run.
+	for _, source := range p.OptionInput {
+		var sourceNewStat = make(map[string]int32)
+
+		for i := 0; i < p.OptionBufferSize; i++ {
+			d, ok, err := p.Channel.Get(source, false)
+			if err != nil {
+				return temp, fmt.Errorf(ERROR_GET_ERROR.Error(), err)
+			}
+
+			if !ok {
+				break
+			}
+
+			item, err := p.pendDelivery(source, d)
+			if err != nil {
+				core.LogInputPlugin(p.LogFields, source, err)
+				continue
+			}
+
+			if item.TIME.After(flowStates[source]) {
+				flowStates[source] = item.TIME
+			}
+
+			sourceNewStat[source] += 1
+
+			temp = append(temp, item)
+		}
+
+		core.LogInputPlugin(p.LogFields, source,
+			fmt.Sprintf("last update: %s, received data: %d", flowStates[source], sourceNewStat[source]))
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	if p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates) {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback returns messages to queues (or drops them) after failed flow run.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	pending := p.Pending
+	p.Pending = make([]amqp.Delivery, 0)
+	p.m.Unlock()
+
+	for _, d := range pending {
+		if err := d.Nack(false, p.OptionRequeue); err != nil {
+			return fmt.Errorf(ERROR_ACK_ERROR.Error(), err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	sendStatus := true
+
+	if err := connect(p); err != nil {
+		return err
+	}
+
+	for _, item := range data {
+		routingKey, message, err := p.formatMessage(item)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range p.OptionOutputTemplate {
+			exchange, err := core.ExtractTemplateIntoString(item, t)
+			if err != nil {
+				return err
+			}
+
+			if err := p.publish(exchange, routingKey, message); err != nil {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, exchange, fmt.Errorf(ERROR_PUBLISH_ERROR.Error(), err))
+			}
+		}
+	}
+
+	if !sendStatus {
+		return core.ERROR_SEND_FAIL
+	}
+
+	return nil
+}
+
+// formatMessage renders routing key and message (payload, headers) of datum.
+func (p *Plugin) formatMessage(item *core.Datum) (string, amqp.Publishing, error) {
+	// Whole datum is sent if payload template isn't set.
+	var payload string
+
+	if p.OptionPayloadTemplate != nil {
+		s, err := core.ExtractTemplateIntoString(item, p.OptionPayloadTemplate)
+		if err != nil {
+			return "", amqp.Publishing{}, err
+		}
+		payload = s
+
+	} else {
+		m, err := core.PruneDatum(item)
+		if err != nil {
+			return "", amqp.Publishing{}, err
+		}
+
+		b, err := json.Marshal(m)
+		if err != nil {
+			return "", amqp.Publishing{}, err
+		}
+		payload = string(b)
+	}
+
+	headers, err := core.ExtractTemplateMapIntoStringMap(item, p.OptionHeadersTemplate)
+	if err != nil {
+		return "", amqp.Publishing{}, err
+	}
+
+	messageHeaders := amqp.Table{}
+	for k, v := range headers {
+		messageHeaders[k] = v
+	}
+
+	routingKey, err := core.ExtractTemplateIntoString(item, p.OptionRoutingKeyTemplate)
+	if err != nil {
+		return "", amqp.Publishing{}, err
+	}
+
+	deliveryMode := amqp.Transient
+	if p.OptionPersistent {
+		deliveryMode = amqp.Persistent
+	}
+
+	message := amqp.Publishing{
+		Body:         []byte(payload),
+		ContentType:  p.OptionContentType,
+		DeliveryMode: deliveryMode,
+		Headers:      messageHeaders,
+		MessageId:    item.UUID.String(),
+		Timestamp:    time.Now().UTC(),
+	}
+
+	return routingKey, message, nil
+}
+
+func (p *Plugin) parseDelivery(source string, d *amqp.Delivery) (*core.Datum, error) {
+	currentTime := time.Now().UTC()
+	u, _ := uuid.NewRandom()
+
+	if !d.Timestamp.IsZero() {
+		currentTime = d.Timestamp.UTC()
+	}
+
+	// Message headers.
+	messageHeaders := make([]string, 0)
+	for k, v := range d.Headers {
+		messageHeaders = append(messageHeaders, fmt.Sprintf("%s: %v", k, v))
+	}
+	sort.Strings(messageHeaders)
+
+	item := &core.Datum{
+		FLOW:        p.Flow.FlowName,
+		PLUGIN:      p.PluginName,
+		SOURCE:      source,
+		TIME:        currentTime,
+		TIMEFORMAT:  currentTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+		TIMEFORMATA: currentTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+		TIMEFORMATB: currentTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+		TIMEFORMATC: currentTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+		UUID:        u,
+
+		AMQP: core.Amqp{
+			CONTENTTYPE: d.ContentType,
+			EXCHANGE:    d.Exchange,
+			HEADERS:     messageHeaders,
+			MESSAGEID:   d.MessageId,
+			PAYLOAD:     string(d.Body),
+			REDELIVERED: fmt.Sprintf("%v", d.Redelivered),
+			ROUTINGKEY:  d.RoutingKey,
+		},
+
+		WARNINGS: make([]string, 0),
+	}
+
+	if len(p.OptionFieldsQuery) == 0 {
+		return item, nil
+	}
+
+	// Map JSON payload into datum fields.
+	var payload interface{}
+	if err := json.Unmarshal(d.Body, &payload); err != nil {
+		return item, err
+	}
+
+	for field, query := range p.OptionFieldsQuery {
+		values, err := runQuery(query, payload)
+		if err != nil {
+			return item, err
+		}
+
+		rv, _ := core.ReflectDatumField(item, field)
+
+		switch rv.Kind() {
+		case reflect.String:
+			if len(values) > 0 {
+				rv.SetString(convertValue(values[0]))
+			}
+		case reflect.Slice:
+			for _, v := range values {
+				rv.Set(reflect.Append(rv, reflect.ValueOf(convertValue(v))))
+			}
+		}
+	}
+
+	return item, nil
+}
+
+// pendDelivery keeps valid message for commit/rollback, invalid messages are dropped at once
+// (requeue would return them forever).
+func (p *Plugin) pendDelivery(source string, d amqp.Delivery) (*core.Datum, error) {
+	item, err := p.parseDelivery(source, &d)
+	if err != nil {
+		if err := d.Nack(false, false); err != nil {
+			return item, fmt.Errorf(ERROR_ACK_ERROR.Error(), err)
+		}
+
+		return item, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err)
+	}
+
+	p.m.Lock()
+	p.Pending = append(p.Pending, d)
+	p.m.Unlock()
+
+	return item, nil
+}
+
+func (p *Plugin) publish(exchange string, routingKey string, message amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.OptionTimeout)*time.Second)
+	defer cancel()
+
+	confirmation, err := p.Channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, message)
+	if err != nil {
+		return err
+	}
+
+	ack, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !ack {
+		return ERROR_PUBLISH_NACK
+	}
+
+	return nil
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		Pending:    make([]amqp.Delivery, 0),
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// "0" - will be set if parameter is set somehow (defaults, template, config etc.).
+	availableParams := map[string]int{
+		"cred":     -1,
+		"template": -1,
+		"timeout":  -1,
+
+		"password":   -1,
+		"server":     -1,
+		"ssl_verify": -1,
+		"username":   -1,
+	}
+
+	switch pluginConfig.PluginType {
+	case "input":
+		availableParams["buffer_size"] = -1
+		availableParams["expire_action"] = -1
+		availableParams["expire_action_delay"] = -1
+		availableParams["expire_action_timeout"] = -1
+		availableParams["expire_interval"] = -1
+		availableParams["fields"] = -1
+		availableParams["input"] = 1
+		availableParams["requeue"] = -1
+		availableParams["time_format"] = -1
+		availableParams["time_format_a"] = -1
+		availableParams["time_format_b"] = -1
+		availableParams["time_format_c"] = -1
+		availableParams["time_zone"] = -1
+		availableParams["time_zone_a"] = -1
+		availableParams["time_zone_b"] = -1
+		availableParams["time_zone_c"] = -1
+
+	case "output":
+		availableParams["content_type"] = -1
+		availableParams["headers"] = -1
+		availableParams["output"] = 1
+		availableParams["payload"] = -1
+		availableParams["persistent"] = -1
+		availableParams["routing_key"] = -1
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// password.
+	setPassword := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["password"] = 0
+			plugin.OptionPassword = core.GetCredValue(v, vault)
+		}
+	}
+	setPassword(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.password", cred)))
+	setPassword((*pluginConfig.PluginParams)["password"])
+
+	// username.
+	setUsername := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["username"] = 0
+			plugin.OptionUsername = core.GetCredValue(v, vault)
+		}
+	}
+	setUsername(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.username", cred)))
+	setUsername((*pluginConfig.PluginParams)["username"])
+	core.ShowPluginParam(plugin.LogFields, "username", plugin.OptionUsername)
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	switch pluginConfig.PluginType {
+	case "input":
+		// buffer_size.
+		setBufferSize := func(p interface{}) {
+			if v, b := core.IsInt(p); b {
+				availableParams["buffer_size"] = 0
+				plugin.OptionBufferSize = v
+			}
+		}
+		setBufferSize(DEFAULT_BUFFER_SIZE)
+		setBufferSize(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.buffer_size", template)))
+		setBufferSize((*pluginConfig.PluginParams)["buffer_size"])
+		core.ShowPluginParam(plugin.LogFields, "buffer_size", plugin.OptionBufferSize)
+
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+		// fields.
+		templateFields, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.fields", template)))
+		configFields, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["fields"])
+
+		mergedFields := make(map[string]string, 0)
+		mergedFieldsQuery := make(map[string]*gojq.Query, 0)
+
+		for k, v := range templateFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range configFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range mergedFields {
+			if _, err := core.ReflectDatumField(&core.Datum{}, k); err != nil {
+				return &Plugin{}, err
+			}
+
+			query, err := gojq.Parse(v)
+			if err != nil {
+				return &Plugin{}, err
+			}
+
+			mergedFieldsQuery[k] = query
+		}
+
+		if len(mergedFields) > 0 {
+			availableParams["fields"] = 0
+		}
+
+		plugin.OptionFields = mergedFields
+		plugin.OptionFieldsQuery = mergedFieldsQuery
+		core.ShowPluginParam(plugin.LogFields, "fields", plugin.OptionFields)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+		// requeue.
+		setRequeue := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["requeue"] = 0
+				plugin.OptionRequeue = v
+			}
+		}
+		setRequeue(DEFAULT_REQUEUE)
+		setRequeue(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.requeue", template)))
+		setRequeue((*pluginConfig.PluginParams)["requeue"])
+		core.ShowPluginParam(plugin.LogFields, "requeue", plugin.OptionRequeue)
+
+		// time_format.
+		setTimeFormat := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format"] = 0
+				plugin.OptionTimeFormat = v
+			}
+		}
+		setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+		setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+		core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+		// time_format_a.
+		setTimeFormatA := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_a"] = 0
+				plugin.OptionTimeFormatA = v
+			}
+		}
+		setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+		setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+		// time_format_b.
+		setTimeFormatB := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_b"] = 0
+				plugin.OptionTimeFormatB = v
+			}
+		}
+		setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+		setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+		// time_format_c.
+		setTimeFormatC := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_c"] = 0
+				plugin.OptionTimeFormatC = v
+			}
+		}
+		setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+		setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+		// time_zone.
+		setTimeZone := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone"] = 0
+				plugin.OptionTimeZone = v
+			}
+		}
+		setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+		setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+		// time_zone_a.
+		setTimeZoneA := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_a"] = 0
+				plugin.OptionTimeZoneA = v
+			}
+		}
+		setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+		setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+		// time_zone_b.
+		setTimeZoneB := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_b"] = 0
+				plugin.OptionTimeZoneB = v
+			}
+		}
+		setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+		setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+		// time_zone_c.
+		setTimeZoneC := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_c"] = 0
+				plugin.OptionTimeZoneC = v
+			}
+		}
+		setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+		setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+	case "output":
+		// content_type.
+		setContentType := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["content_type"] = 0
+				plugin.OptionContentType = v
+			}
+		}
+		setContentType(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.content_type", template)))
+		setContentType((*pluginConfig.PluginParams)["content_type"])
+		core.ShowPluginParam(plugin.LogFields, "content_type", plugin.OptionContentType)
+
+		// headers.
+		templateHeaders, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.headers", template)))
+		configHeaders, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["headers"])
+		mergedHeaders := make(map[string]string, 0)
+		mergedHeadersTemplate := make(map[string]*tmpl.Template, 0)
+
+		for k, v := range templateHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range configHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range mergedHeaders {
+			if t, err := tmpl.New(k).Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				mergedHeadersTemplate[k] = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		plugin.OptionHeaders = mergedHeaders
+		plugin.OptionHeadersTemplate = mergedHeadersTemplate
+		core.ShowPluginParam(plugin.LogFields, "headers", plugin.OptionHeaders)
+
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
+
+		for _, v := range plugin.OptionOutput {
+			if t, err := tmpl.New("output").Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				plugin.OptionOutputTemplate = append(plugin.OptionOutputTemplate, t)
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		// payload.
+		setPayload := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["payload"] = 0
+				plugin.OptionPayload = v
+			}
+		}
+		setPayload(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.payload", template)))
+		setPayload((*pluginConfig.PluginParams)["payload"])
+		core.ShowPluginParam(plugin.LogFields, "payload", plugin.OptionPayload)
+
+		if plugin.OptionPayload != "" {
+			if t, err := tmpl.New("payload").Funcs(core.TemplateFuncMap).Parse(plugin.OptionPayload); err == nil {
+				plugin.OptionPayloadTemplate = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		// persistent.
+		setPersistent := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["persistent"] = 0
+				plugin.OptionPersistent = v
+			}
+		}
+		setPersistent(DEFAULT_PERSISTENT)
+		setPersistent(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.persistent", template)))
+		setPersistent((*pluginConfig.PluginParams)["persistent"])
+		core.ShowPluginParam(plugin.LogFields, "persistent", plugin.OptionPersistent)
+
+		// routing_key.
+		setRoutingKey := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["routing_key"] = 0
+				plugin.OptionRoutingKey = v
+			}
+		}
+		setRoutingKey(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.routing_key", template)))
+		setRoutingKey((*pluginConfig.PluginParams)["routing_key"])
+		core.ShowPluginParam(plugin.LogFields, "routing_key", plugin.OptionRoutingKey)
+
+		if t, err := tmpl.New("routing_key").Funcs(core.TemplateFuncMap).Parse(plugin.OptionRoutingKey); err == nil {
+			plugin.OptionRoutingKeyTemplate = t
+		} else {
+			return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+		}
+	}
+
+	// server.
+	setServer := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["server"] = 0
+			plugin.OptionServer = core.GetCredValue(v, vault)
+		}
+	}
+	setServer(DEFAULT_SERVER)
+	setServer(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.server", cred)))
+	setServer((*pluginConfig.PluginParams)["server"])
+
+	// ssl_verify.
+	setSSLVerify := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["ssl_verify"] = 0
+			plugin.OptionSSLVerify = v
+		}
+	}
+	setSSLVerify(DEFAULT_SSL_VERIFY)
+	setSSLVerify(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ssl_verify", template)))
+	setSSLVerify((*pluginConfig.PluginParams)["ssl_verify"])
+	core.ShowPluginParam(plugin.LogFields, "ssl_verify", plugin.OptionSSLVerify)
+
+	// timeout.
+	setTimeout := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["timeout"] = 0
+			plugin.OptionTimeout = v
+		}
+	}
+	setTimeout(DEFAULT_TIMEOUT)
+	setTimeout(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.timeout", template)))
+	setTimeout((*pluginConfig.PluginParams)["timeout"])
+	core.ShowPluginParam(plugin.LogFields, "timeout", plugin.OptionTimeout)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	// Server URL might contain credentials, so it isn't shown.
+	if _, err := amqp.ParseURI(plugin.OptionServer); err != nil {
+		return &Plugin{}, fmt.Errorf(ERROR_SERVER_UNKNOWN.Error(), err)
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
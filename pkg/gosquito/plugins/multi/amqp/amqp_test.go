@@ -0,0 +1,213 @@
+package amqpMulti
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/viper"
+)
+
+type acknowledger struct {
+	m sync.Mutex
+
+	acks     []uint64
+	nacks    []uint64
+	requeues []bool
+	err      error
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.acks = append(a.acks, tag)
+
+	return a.err
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.nacks = append(a.nacks, tag)
+	a.requeues = append(a.requeues, requeue)
+
+	return a.err
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func newPlugin(t *testing.T, pluginType string, params map[string]interface{}) *Plugin {
+	appConfig := viper.New()
+	appConfig.Set(core.VIPER_DEFAULT_EXPIRE_INTERVAL, "1d")
+	appConfig.Set(core.VIPER_DEFAULT_TIME_ZONE, "UTC")
+	core.SetStateBackend(appConfig)
+
+	p, err := Init(&core.PluginConfig{
+		AppConfig:    appConfig,
+		Flow:         &core.Flow{FlowName: "amqp", FlowStateDir: t.TempDir()},
+		PluginParams: &params,
+		PluginType:   pluginType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestParseDelivery(t *testing.T) {
+	p := newPlugin(t, "input", map[string]interface{}{
+		"input": []interface{}{"news"},
+		"fields": map[string]interface{}{
+			"data.text0":  ".title",
+			"data.text1":  ".user.id",
+			"data.array0": ".tags[]",
+		},
+	})
+
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	item, err := p.parseDelivery("news", &amqp.Delivery{
+		Body:       []byte(`{"title": "hello", "user": {"id": 42}, "tags": ["a", "b"]}`),
+		Headers:    amqp.Table{"b": "2", "a": 1},
+		RoutingKey: "news.a",
+		Timestamp:  timestamp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{item.SOURCE, item.AMQP.ROUTINGKEY, item.DATA.TEXT0, item.DATA.TEXT1}
+	if want := []string{"news", "news.a", "hello", "42"}; !reflect.DeepEqual(got, want) {
+		t.Errorf(`parseDelivery() = %q, want %q`, got, want)
+	}
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(item.DATA.ARRAY0, want) {
+		t.Errorf(`parseDelivery() array = %q, want %q`, item.DATA.ARRAY0, want)
+	}
+
+	if want := []string{"a: 1", "b: 2"}; !reflect.DeepEqual(item.AMQP.HEADERS, want) {
+		t.Errorf(`parseDelivery() headers = %q, want %q`, item.AMQP.HEADERS, want)
+	}
+
+	if !item.TIME.Equal(timestamp) {
+		t.Errorf(`parseDelivery() time = %v, want %v`, item.TIME, timestamp)
+	}
+
+	// Fields can't be mapped from invalid payload.
+	if _, err := p.parseDelivery("news", &amqp.Delivery{Body: []byte("hello")}); err == nil {
+		t.Errorf(`parseDelivery() with invalid payload = %v`, err)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	item := &core.Datum{RSS: core.Rss{LINK: "https://example.com", TITLE: "hello"}}
+
+	p := newPlugin(t, "output", map[string]interface{}{
+		"output":      []interface{}{"news"},
+		"payload":     "{{ .RSS.TITLE }}!",
+		"routing_key": "news.{{ .RSS.TITLE }}",
+		"headers":     map[string]interface{}{"link": "{{ .RSS.LINK }}"},
+	})
+
+	routingKey, message, err := p.formatMessage(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routingKey != "news.hello" || string(message.Body) != "hello!" ||
+		!reflect.DeepEqual(message.Headers, amqp.Table{"link": "https://example.com"}) ||
+		message.DeliveryMode != amqp.Persistent {
+		t.Errorf(`formatMessage() = %q, %v`, routingKey, message)
+	}
+
+	// Whole datum is sent if payload template isn't set.
+	p = newPlugin(t, "output", map[string]interface{}{
+		"output":     []interface{}{"news"},
+		"persistent": false,
+	})
+
+	_, message, err = p.formatMessage(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"TITLE":"hello"`; message.DeliveryMode != amqp.Transient ||
+		!strings.Contains(string(message.Body), want) {
+		t.Errorf(`formatMessage() without payload = %s, %d`, message.Body, message.DeliveryMode)
+	}
+}
+
+func TestCommitRollback(t *testing.T) {
+	for _, test := range []struct {
+		requeue bool
+	}{{true}, {false}} {
+		p := newPlugin(t, "input", map[string]interface{}{
+			"input":   []interface{}{"news"},
+			"requeue": test.requeue,
+		})
+
+		ack := &acknowledger{}
+		p.Pending = []amqp.Delivery{{Acknowledger: ack, DeliveryTag: 1}, {Acknowledger: ack, DeliveryTag: 2}}
+
+		if err := p.Rollback(); err != nil || len(p.Pending) != 0 {
+			t.Fatalf(`Rollback() = %v, %v`, err, p.Pending)
+		}
+
+		if want := []uint64{1, 2}; !reflect.DeepEqual(ack.nacks, want) || len(ack.acks) != 0 {
+			t.Errorf(`Rollback() nacks = %v, acks = %v`, ack.nacks, ack.acks)
+		}
+
+		if want := []bool{test.requeue, test.requeue}; !reflect.DeepEqual(ack.requeues, want) {
+			t.Errorf(`Rollback() requeues = %v, want %v`, ack.requeues, want)
+		}
+
+		p.Pending = []amqp.Delivery{{Acknowledger: ack, DeliveryTag: 3}}
+
+		if err := p.Commit(); err != nil || len(p.Pending) != 0 {
+			t.Fatalf(`Commit() = %v, %v`, err, p.Pending)
+		}
+
+		if want := []uint64{3}; !reflect.DeepEqual(ack.acks, want) {
+			t.Errorf(`Commit() acks = %v, want %v`, ack.acks, want)
+		}
+	}
+
+	// Invalid messages are dropped at once, valid messages wait for commit/rollback.
+	p := newPlugin(t, "input", map[string]interface{}{
+		"input":  []interface{}{"news"},
+		"fields": map[string]interface{}{"data.text0": ".title"},
+	})
+	ack := &acknowledger{}
+
+	if _, err := p.pendDelivery("news", amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte("hello")}); err == nil {
+		t.Errorf(`pendDelivery() with invalid payload = %v`, err)
+	}
+
+	if _, err := p.pendDelivery("news", amqp.Delivery{Acknowledger: ack, DeliveryTag: 2, Body: []byte(`{}`)}); err != nil {
+		t.Errorf(`pendDelivery() = %v`, err)
+	}
+
+	if len(p.Pending) != 1 || p.Pending[0].DeliveryTag != 2 ||
+		!reflect.DeepEqual(ack.nacks, []uint64{1}) || !reflect.DeepEqual(ack.requeues, []bool{false}) {
+		t.Errorf(`pendDelivery() pending = %d, nacks = %v, requeues = %v`, len(p.Pending), ack.nacks, ack.requeues)
+	}
+
+	// Pending messages are discarded even if acknowledgement failed.
+	p = newPlugin(t, "input", map[string]interface{}{"input": []interface{}{"news"}})
+	ack = &acknowledger{err: errors.New("channel closed")}
+	p.Pending = []amqp.Delivery{{Acknowledger: ack, DeliveryTag: 1}}
+
+	if err := p.Commit(); err == nil || len(p.Pending) != 0 {
+		t.Errorf(`Commit() = %v, %v`, err, p.Pending)
+	}
+}
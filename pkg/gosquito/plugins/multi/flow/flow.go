@@ -0,0 +1,328 @@
+package flowMulti
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+)
+
+const (
+	PLUGIN_NAME = "flow"
+
+	DEFAULT_TRIGGER = false
+)
+
+var (
+	ERROR_FLOW_INVALID   = errors.New("flow name invalid: %s")
+	ERROR_FLOW_NOT_FOUND = errors.New("flow not found: %s")
+)
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	Queue        *core.Queue
+	QueuePending []*core.QueueBatch
+	Queues       map[string]*core.Queue
+
+	OptionInput   []string
+	OptionOutput  []string
+	OptionTrigger bool
+}
+
+// Commit removes received batches from queue.
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for i, batch := range p.QueuePending {
+		if err := p.Queue.Remove(batch); err != nil {
+			p.QueuePending = p.QueuePending[i:]
+			return err
+		}
+	}
+	p.QueuePending = make([]*core.QueueBatch, 0)
+
+	return nil
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+
+	if err := core.PluginLoadState(p.Flow.FlowStateDir, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	temp := make([]*core.Datum, 0)
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+
+	// Read queue.
+	batches, err := p.Queue.Read(p.OptionInput)
+	if err != nil {
+		return temp, err
+	}
+
+	sourceBatchStat := make(map[string]int32)
+	sourceNewStat := make(map[string]int32)
+
+	for _, batch := range batches {
+		for _, item := range batch.Data {
+			item.FLOW = p.Flow.FlowName
+			temp = append(temp, item)
+		}
+
+		if batch.Time.After(flowStates[batch.Source]) {
+			flowStates[batch.Source] = batch.Time
+		}
+
+		sourceBatchStat[batch.Source] += 1
+		sourceNewStat[batch.Source] += int32(len(batch.Data))
+	}
+
+	// Batches are removed only after successful flow run (see Commit).
+	p.m.Lock()
+	p.QueuePending = append(p.QueuePending, batches...)
+	p.m.Unlock()
+
+	for _, source := range p.OptionInput {
+		core.LogInputPlugin(p.LogFields, source, fmt.Sprintf("last update: %s, received batches: %d, new data: %d",
+			flowStates[source], sourceBatchStat[source], sourceNewStat[source]))
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
+
+	// Inform about expiration.
+	if sourcesExpired {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback keeps received batches in queue, they will be received again.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, batch := range p.QueuePending {
+		p.Queue.Release(batch)
+	}
+	p.QueuePending = make([]*core.QueueBatch, 0)
+
+	return nil
+}
+
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	sendStatus := true
+
+	for _, output := range p.OptionOutput {
+		if err := p.Queues[output].Write(p.Flow.FlowName, data); err != nil {
+			sendStatus = false
+			core.LogOutputPlugin(p.LogFields, output, err)
+			continue
+		}
+
+		core.LogOutputPlugin(p.LogFields, output, fmt.Sprintf("queued: %d", len(data)))
+
+		// Run downstream flow immediately.
+		if p.OptionTrigger {
+			if flow := core.GetFlowByName(output); flow != nil {
+				flow.Trigger()
+			} else {
+				core.LogOutputPlugin(p.LogFields, output, fmt.Errorf(ERROR_FLOW_NOT_FOUND.Error(), output))
+			}
+		}
+	}
+
+	if !sendStatus {
+		return core.ERROR_SEND_FAIL
+	}
+
+	return nil
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// "0" - will be set if parameter is set somehow (defaults, template, config etc.).
+	availableParams := map[string]int{
+		"template": -1,
+	}
+
+	switch pluginConfig.PluginType {
+	case "input":
+		availableParams["expire_action"] = -1
+		availableParams["expire_action_delay"] = -1
+		availableParams["expire_action_timeout"] = -1
+		availableParams["expire_interval"] = -1
+		availableParams["input"] = 1
+	case "output":
+		availableParams["output"] = 1
+		availableParams["trigger"] = -1
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	switch pluginConfig.PluginType {
+
+	case "input":
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+		plugin.Queue = core.GetFlowQueue(pluginConfig.AppConfig, plugin.Flow.FlowName)
+
+	case "output":
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
+
+		// trigger.
+		setTrigger := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["trigger"] = 0
+				plugin.OptionTrigger = v
+			}
+		}
+		setTrigger(DEFAULT_TRIGGER)
+		setTrigger(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.trigger", template)))
+		setTrigger((*pluginConfig.PluginParams)["trigger"])
+		core.ShowPluginParam(plugin.LogFields, "trigger", plugin.OptionTrigger)
+
+		plugin.Queues = make(map[string]*core.Queue, len(plugin.OptionOutput))
+		for _, output := range plugin.OptionOutput {
+			plugin.Queues[output] = core.GetFlowQueue(pluginConfig.AppConfig, output)
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	if plugin.PluginType == "output" {
+		for _, output := range plugin.OptionOutput {
+			if !core.IsFlowNameValid(output) || output == plugin.Flow.FlowName {
+				return &Plugin{}, fmt.Errorf(ERROR_FLOW_INVALID.Error(), output)
+			}
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
@@ -1,14 +1,24 @@
 package ioMulti
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	tmpl "text/template"
 	"time"
 
+	"github.com/google/renameio"
 	"github.com/google/uuid"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
 	log "github.com/livelace/logrus"
@@ -17,34 +27,332 @@ import (
 const (
 	PLUGIN_NAME = "io"
 
-	DEFAULT_DATA_APPEND     = false
-	DEFAULT_FILE_IN         = false
-	DEFAULT_FILE_IN_MODE    = "text"
-	DEFAULT_FILE_IN_PRE     = ""
-	DEFAULT_FILE_IN_POST    = ""
-	DEFAULT_FILE_IN_SPLIT   = "\n"
-	DEFAULT_FILE_OUT        = false
-	DEFAULT_FILE_OUT_APPEND = false
-	DEFAULT_FILE_OUT_MODE   = "text"
-	DEFAULT_FILE_OUT_PRE    = ""
-	DEFAULT_FILE_OUT_POST   = ""
-	DEFAULT_FILE_OUT_SPLIT  = "\n"
-	DEFAULT_MATCH_TTL       = "1d"
-	DEFAULT_TEXT_MODE       = "text"
-	DEFAULT_TEXT_PRE        = ""
-	DEFAULT_TEXT_POST       = ""
-	DEFAULT_TEXT_SPLIT      = "\n"
+	DEFAULT_DATA_APPEND           = false
+	DEFAULT_FILES_DB              = "files.db"
+	DEFAULT_FILE_IN               = false
+	DEFAULT_FILE_IN_MODE          = "text"
+	DEFAULT_FILE_IN_PRE           = ""
+	DEFAULT_FILE_IN_POST          = ""
+	DEFAULT_FILE_IN_RECURSIVE     = false
+	DEFAULT_FILE_IN_SPLIT         = "\n"
+	DEFAULT_FILE_IN_TAIL          = false
+	DEFAULT_FILE_IN_TAIL_LIMIT    = 10 * 1024 * 1024
+	DEFAULT_FILE_IN_TAIL_START    = "end"
+	DEFAULT_FILE_OUT              = false
+	DEFAULT_FILE_OUT_APPEND       = false
+	DEFAULT_FILE_OUT_FORMAT       = "template"
+	DEFAULT_FILE_OUT_GZIP         = false
+	DEFAULT_FILE_OUT_MODE         = "text"
+	DEFAULT_FILE_OUT_PRE          = ""
+	DEFAULT_FILE_OUT_POST         = ""
+	DEFAULT_FILE_OUT_ROTATE_COUNT = 0
+	DEFAULT_FILE_OUT_SPLIT        = "\n"
+	DEFAULT_MATCH_TTL             = "1d"
+	DEFAULT_TEXT_MODE             = "text"
+	DEFAULT_TEXT_PRE              = ""
+	DEFAULT_TEXT_POST             = ""
+	DEFAULT_TEXT_SPLIT            = "\n"
 )
 
 var (
-	ERROR_COPY_TO_STRING = errors.New("cannot copy anything to string: %v")
-	ERROR_MODE_UNKNOWN   = errors.New("mode unknown: %v")
+	ERROR_COPY_TO_STRING   = errors.New("cannot copy anything to string: %v")
+	ERROR_FORMAT_UNKNOWN   = errors.New("format unknown: %v")
+	ERROR_MODE_UNKNOWN     = errors.New("mode unknown: %v")
+	ERROR_ROTATE_FILE      = errors.New("rotate file error: %v, %v")
+	ERROR_TAIL_START       = errors.New("tail start unknown: %v")
+	ERROR_TEMPLATE_INVALID = errors.New("template invalid: %v")
 
 	INFO_READ_FROM_FILE      = "read from file: %v, %v"
+	INFO_ROTATE_FILE         = "rotate file: %v -> %v"
 	INFO_WRITE_TO_FILE       = "write to file: %v, %v"
 	INFO_WRITE_LINES_TO_FILE = "write lines to file: lines -> %v, %v"
 )
 
+// fileState keeps file version (glob/directory sources) and read position (tail mode).
+type fileState struct {
+	Inode   uint64
+	ModTime time.Time
+	Offset  int64
+	Size    int64
+}
+
+func getInode(info os.FileInfo) uint64 {
+	if v, ok := info.Sys().(*syscall.Stat_t); ok {
+		return v.Ino
+	}
+
+	return 0
+}
+
+// isFileSet returns true if source is a set of files (glob pattern or directory).
+func isFileSet(source string) bool {
+	if strings.ContainsAny(source, "*?[") {
+		return true
+	}
+
+	info, err := os.Stat(source)
+
+	return err == nil && info.IsDir()
+}
+
+// findFiles returns regular files of source: glob pattern, directory (recursive if set) or single file.
+func findFiles(p *Plugin, source string) ([]string, error) {
+	temp := make([]string, 0)
+
+	paths := []string{source}
+
+	if strings.ContainsAny(source, "*?[") {
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return temp, err
+		}
+		paths = matches
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return temp, err
+		}
+
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				temp = append(temp, path)
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if file != path && !p.OptionFileInRecursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.Type().IsRegular() {
+				temp = append(temp, file)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return temp, err
+		}
+	}
+
+	sort.Strings(temp)
+
+	return temp, nil
+}
+
+// findInode returns file (and its info) with inode inside directory, used for reading rotated files.
+func findInode(dir string, inode uint64) (string, os.FileInfo) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		if info, err := entry.Info(); err == nil && getInode(info) == inode {
+			return filepath.Join(dir, entry.Name()), info
+		}
+	}
+
+	return "", nil
+}
+
+// readLines reads complete lines between start and size, but not more than DEFAULT_FILE_IN_TAIL_LIMIT bytes at once.
+// Incomplete last line is read only if file is final (rotated) or line is longer than limit.
+func readLines(file string, start int64, size int64, final bool) (string, int64, error) {
+	if size <= start {
+		return "", start, nil
+	}
+
+	end := size
+	if end-start > DEFAULT_FILE_IN_TAIL_LIMIT {
+		end = start + DEFAULT_FILE_IN_TAIL_LIMIT
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", start, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return "", start, err
+	}
+
+	b, err := io.ReadAll(io.LimitReader(f, end-start))
+	if err != nil {
+		return "", start, err
+	}
+
+	n := bytes.LastIndexByte(b, '\n')
+
+	switch {
+	case final && start+int64(len(b)) >= size:
+		return strings.TrimSuffix(string(b), "\n"), start + int64(len(b)), nil
+	case n < 0 && int64(len(b)) < DEFAULT_FILE_IN_TAIL_LIMIT:
+		return "", start, nil
+	case n < 0:
+		return string(b), start + int64(len(b)), nil
+	}
+
+	return string(b[:n]), start + int64(n) + 1, nil
+}
+
+// readTail reads complete lines appended since last read. If file was rotated (inode changed), the rest of
+// the old file (found by inode in the same directory) is read first, then the new file is read from the beginning.
+// Truncated file (size is less than offset) is read from the beginning.
+func readTail(file string, info os.FileInfo, state fileState) (string, fileState, error) {
+	texts := make([]string, 0)
+	start := state.Offset
+	inode := getInode(info)
+
+	if state.Inode != 0 && inode != state.Inode {
+		if old, oldInfo := findInode(filepath.Dir(file), state.Inode); old != "" && oldInfo.Size() > start {
+			text, offset, err := readLines(old, start, oldInfo.Size(), true)
+			if err != nil {
+				return "", state, err
+			}
+
+			if text != "" {
+				texts = append(texts, text)
+			}
+
+			// Old file is read partially (limit), continue next time.
+			if offset < oldInfo.Size() {
+				state.Offset = offset
+				return strings.Join(texts, "\n"), state, nil
+			}
+		}
+
+		start = 0
+	}
+
+	if info.Size() < start {
+		start = 0
+	}
+
+	text, offset, err := readLines(file, start, info.Size(), false)
+	if err != nil {
+		return "", state, err
+	}
+
+	if text != "" {
+		texts = append(texts, text)
+	}
+
+	return strings.Join(texts, "\n"), fileState{Inode: inode, ModTime: info.ModTime(), Offset: offset, Size: info.Size()}, nil
+}
+
+// receiveFiles returns datums of new/changed files (new lines in tail mode) of source.
+func receiveFiles(p *Plugin, source string, files map[string]fileState) ([]*core.Datum, int, error) {
+	temp := make([]*core.Datum, 0)
+
+	found, err := findFiles(p, source)
+	if err != nil {
+		return temp, 0, err
+	}
+
+	for _, file := range found {
+		var u, _ = uuid.NewRandom()
+		var text string
+
+		info, err := os.Stat(file)
+		if err != nil {
+			core.LogInputPlugin(p.LogFields, source, err)
+			continue
+		}
+
+		state, ok := files[file]
+
+		// Files without saved position (first run, new files) are read from the end by default.
+		if p.OptionFileInTail && !ok && p.OptionFileInTailStart == "end" {
+			state = fileState{Inode: getInode(info), ModTime: info.ModTime(), Offset: info.Size(), Size: info.Size()}
+		}
+
+		if p.OptionFileInTail {
+			text, state, err = readTail(file, info, state)
+			if err != nil {
+				core.LogInputPlugin(p.LogFields, source, err)
+				continue
+			}
+
+			files[file] = state
+
+			if text == "" {
+				continue
+			}
+
+		} else {
+			if ok && state.ModTime.Equal(info.ModTime()) && state.Size == info.Size() {
+				continue
+			}
+
+			if text, err = core.GetStringFromFile(file); err != nil {
+				core.LogInputPlugin(p.LogFields, source, err)
+				continue
+			}
+
+			files[file] = fileState{Inode: getInode(info), ModTime: info.ModTime(), Size: info.Size()}
+		}
+
+		itemLines := make([]string, 0)
+		itemMime := ""
+		itemText := ""
+		itemTime := info.ModTime().UTC()
+
+		if mime, err := core.GetFileMimeType(file); err == nil {
+			itemMime = mime.String()
+		}
+
+		switch p.OptionFileInMode {
+		case "split":
+			for _, l := range strings.Split(text, p.OptionFileInSplit) {
+				itemLines = append(itemLines, wrapFileIn(p, l))
+			}
+		case "text":
+			itemText = wrapFileIn(p, text)
+		}
+
+		temp = append(temp, &core.Datum{
+			FLOW:        p.Flow.FlowName,
+			PLUGIN:      p.PluginName,
+			SOURCE:      source,
+			TIME:        itemTime,
+			TIMEFORMAT:  itemTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+			TIMEFORMATA: itemTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+			TIMEFORMATB: itemTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+			TIMEFORMATC: itemTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+			UUID:        u,
+
+			IO: core.Io{
+				MIME:  itemMime,
+				MTIME: fmt.Sprintf("%v", itemTime.Unix()),
+				PATH:  file,
+				SIZE:  fmt.Sprintf("%v", info.Size()),
+				SPLIT: itemLines,
+				TEXT:  itemText,
+			},
+
+			WARNINGS: make([]string, 0),
+		})
+	}
+
+	return temp, len(found), nil
+}
+
 func processText(p *Plugin, input []string) []string {
 	r := make([]string, 0)
 
@@ -167,54 +475,327 @@ func wrapFileOut(p *Plugin, s string) string {
 	return fmt.Sprintf("%s%s%s", p.OptionFileOutPre, s, p.OptionFileOutPost)
 }
 
+// compressFile replaces rotated file with its gzip version (atomically).
+func compressFile(file string) (string, error) {
+	compressed := fmt.Sprintf("%s.gz", file)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	t, err := renameio.TempFile("", compressed)
+	if err != nil {
+		return file, err
+	}
+	defer t.Cleanup()
+
+	w := gzip.NewWriter(t)
+
+	if _, err := io.Copy(w, f); err != nil {
+		return file, err
+	}
+
+	if err := w.Close(); err != nil {
+		return file, err
+	}
+
+	if err := t.CloseAtomicallyReplace(); err != nil {
+		return file, err
+	}
+
+	return compressed, os.Remove(file)
+}
+
+// formatData renders data as file records according to output format.
+func formatData(p *Plugin, data []*core.Datum, header bool) ([]byte, error) {
+	var b bytes.Buffer
+
+	switch p.OptionFileOutFormat {
+	case "csv":
+		w := csv.NewWriter(&b)
+
+		if header {
+			if err := w.Write(p.OptionFileOutFields); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, item := range data {
+			record := make([]string, 0)
+
+			for _, field := range p.OptionFileOutFields {
+				rv, _ := core.ReflectDatumField(item, field)
+
+				switch rv.Kind() {
+				case reflect.String:
+					record = append(record, rv.String())
+				case reflect.Slice:
+					record = append(record, strings.Join(rv.Interface().([]string), p.OptionFileOutSplit))
+				default:
+					record = append(record, fmt.Sprintf("%v", rv))
+				}
+			}
+
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+
+		w.Flush()
+
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+
+	case "jsonl":
+		for _, item := range data {
+			var record map[string]interface{}
+			var err error
+
+			if len(p.OptionFileOutFields) > 0 {
+				record = make(map[string]interface{}, 0)
+
+				for _, field := range p.OptionFileOutFields {
+					rv, _ := core.ReflectDatumField(item, field)
+
+					switch rv.Kind() {
+					case reflect.String, reflect.Slice:
+						record[field] = rv.Interface()
+					default:
+						record[field] = fmt.Sprintf("%v", rv)
+					}
+				}
+
+			} else if record, err = core.PruneDatum(item); err != nil {
+				return nil, err
+			}
+
+			j, err := json.Marshal(record)
+			if err != nil {
+				return nil, err
+			}
+
+			b.Write(j)
+			b.WriteString("\n")
+		}
+
+	case "template":
+		for _, item := range data {
+			s, err := core.ExtractTemplateIntoString(item, p.OptionFileOutTemplate)
+			if err != nil {
+				return nil, err
+			}
+
+			b.WriteString(wrapFileOut(p, s))
+			b.WriteString(p.OptionFileOutSplit)
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// pruneFiles removes the oldest rotated files exceeding rotate count.
+func pruneFiles(p *Plugin, file string) error {
+	if p.OptionFileOutRotateCount <= 0 {
+		return nil
+	}
+
+	type rotatedFile struct {
+		Name      string
+		Timestamp int64
+	}
+
+	temp := make([]rotatedFile, 0)
+
+	files, err := filepath.Glob(fmt.Sprintf("%s.*", file))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		var timestamp int64
+
+		suffix := strings.TrimSuffix(strings.TrimPrefix(f, file+"."), ".gz")
+
+		if _, err := fmt.Sscanf(suffix, "%d", &timestamp); err != nil || fmt.Sprintf("%d", timestamp) != suffix {
+			continue
+		}
+
+		temp = append(temp, rotatedFile{Name: f, Timestamp: timestamp})
+	}
+
+	sort.Slice(temp, func(i, j int) bool {
+		return temp[i].Timestamp < temp[j].Timestamp
+	})
+
+	for i := 0; i < len(temp)-p.OptionFileOutRotateCount; i++ {
+		if err := os.Remove(temp[i].Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateFile moves current file aside (<file>.<timestamp>[.gz]) if it exceeds rotate size or interval.
+func rotateFile(p *Plugin, file string, size int) (bool, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if info.Size() == 0 {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+
+	bySize := p.OptionFileOutRotateSize > 0 && info.Size()+int64(size) > p.OptionFileOutRotateSize
+	byInterval := p.OptionFileOutRotateInterval > 0 &&
+		!info.ModTime().UTC().Truncate(p.OptionFileOutRotateInterval).Equal(now.Truncate(p.OptionFileOutRotateInterval))
+
+	if !bySize && !byInterval {
+		return false, nil
+	}
+
+	rotated := fmt.Sprintf("%s.%d", file, now.UnixNano())
+
+	if err := os.Rename(file, rotated); err != nil {
+		return false, err
+	}
+
+	if p.OptionFileOutGzip {
+		if rotated, err = compressFile(rotated); err != nil {
+			return true, err
+		}
+	}
+
+	core.LogOutputPlugin(p.LogFields, file, fmt.Sprintf(INFO_ROTATE_FILE, file, rotated))
+
+	return true, pruneFiles(p, file)
+}
+
+// sendFile appends rendered data to output file, file is rotated beforehand if needed.
+func sendFile(p *Plugin, file string, data []*core.Datum) error {
+	if err := core.CreateDirIfNotExist(filepath.Dir(file)); err != nil {
+		return err
+	}
+
+	// Size of data is estimated without csv header.
+	b, err := formatData(p, data, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err := rotateFile(p, file, len(b)); err != nil {
+		return fmt.Errorf(ERROR_ROTATE_FILE.Error(), file, err)
+	}
+
+	// New (or empty) csv file starts with header.
+	if p.OptionFileOutFormat == "csv" {
+		if info, err := os.Stat(file); os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+			if b, err = formatData(p, data, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	core.LogOutputPlugin(p.LogFields, file, fmt.Sprintf(INFO_WRITE_TO_FILE, file, len(b)))
+
+	return nil
+}
+
 type Plugin struct {
 	m sync.Mutex
 
 	Flow *core.Flow
 
+	Expire *core.ExpireCheck
+
+	FilesFile string
+
 	LogFields log.Fields
 
+	pendingFiles map[string]fileState
+
 	PluginID    int
 	PluginAlias string
 	PluginName  string
 	PluginType  string
 
-	OptionDataAppend          bool
-	OptionExpireAction        []string
-	OptionExpireActionDelay   int64
-	OptionExpireActionTimeout int
-	OptionExpireInterval      int64
-	OptionExpireLast          int64
-	OptionFileIn              bool
-	OptionFileInMode          string
-	OptionFileInPre           string
-	OptionFileInPost          string
-	OptionFileInSplit         string
-	OptionFileOut             bool
-	OptionFileOutAppend       bool
-	OptionFileOutMode         string
-	OptionFileOutPre          string
-	OptionFileOutPost         string
-	OptionFileOutSplit        string
-	OptionInclude             bool
-	OptionInput               []string
-	OptionMatchSignature      []string
-	OptionMatchTTL            time.Duration
-	OptionOutput              []string
-	OptionRequire             []int
-	OptionTextMode            string
-	OptionTextPre             string
-	OptionTextPost            string
-	OptionTextSplit           string
-	OptionTimeFormat          string
-	OptionTimeFormatA         string
-	OptionTimeFormatB         string
-	OptionTimeFormatC         string
-	OptionTimeZone            *time.Location
-	OptionTimeZoneA           *time.Location
-	OptionTimeZoneB           *time.Location
-	OptionTimeZoneC           *time.Location
-	OptionTimeout             int
+	OptionDataAppend            bool
+	OptionFileIn                bool
+	OptionFileInMode            string
+	OptionFileInPre             string
+	OptionFileInPost            string
+	OptionFileInRecursive       bool
+	OptionFileInSplit           string
+	OptionFileInTail            bool
+	OptionFileInTailStart       string
+	OptionFileOut               bool
+	OptionFileOutAppend         bool
+	OptionFileOutFields         []string
+	OptionFileOutFormat         string
+	OptionFileOutGzip           bool
+	OptionFileOutMode           string
+	OptionFileOutPre            string
+	OptionFileOutPost           string
+	OptionFileOutRotateCount    int
+	OptionFileOutRotateInterval time.Duration
+	OptionFileOutRotateSize     int64
+	OptionFileOutSplit          string
+	OptionFileOutTemplate       *tmpl.Template
+	OptionInclude               bool
+	OptionInput                 []string
+	OptionMatchSignature        []string
+	OptionMatchTTL              time.Duration
+	OptionOutput                []string
+	OptionRequire               []int
+	OptionTextMode              string
+	OptionTextPre               string
+	OptionTextPost              string
+	OptionTextSplit             string
+	OptionTimeFormat            string
+	OptionTimeFormatA           string
+	OptionTimeFormatB           string
+	OptionTimeFormatC           string
+	OptionTimeZone              *time.Location
+	OptionTimeZoneA             *time.Location
+	OptionTimeZoneB             *time.Location
+	OptionTimeZoneC             *time.Location
+	OptionTimeout               int
+}
+
+// Commit saves files' states (read positions) of the last run.
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	files := p.pendingFiles
+	p.pendingFiles = nil
+	p.m.Unlock()
+
+	if files == nil {
+		return nil
+	}
+
+	return p.SaveFiles(files)
 }
 
 func (p *Plugin) FlowLog(message interface{}) {
@@ -247,10 +828,31 @@ func (p *Plugin) GetName() string {
 	return p.PluginName
 }
 
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
 func (p *Plugin) GetRequire() []int {
 	return p.OptionRequire
 }
 
+func (p *Plugin) LoadFiles() (map[string]fileState, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]fileState, 0)
+
+	if _, err := core.IsFile(p.FilesFile); err != nil {
+		return data, nil
+	}
+
+	if err := core.PluginLoadData(p.FilesFile, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
 func (p *Plugin) LoadState() (map[string]time.Time, error) {
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -697,6 +1299,12 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	}
 	core.LogInputPlugin(p.LogFields, "all", fmt.Sprintf("states loaded: %d", len(flowStates)))
 
+	// Load files' states (glob/directory sources, tail mode).
+	files, err := p.LoadFiles()
+	if err != nil {
+		return temp, err
+	}
+
 	for _, source := range p.OptionInput {
 		var itemNew = false
 		var itemSignature string
@@ -712,15 +1320,56 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 			sourceLastTime = time.Unix(0, 0)
 		}
 
+		// Glob patterns, directories and tail mode: datum per new/changed file.
+		if p.OptionFileIn && (p.OptionFileInTail || isFileSet(source)) {
+			// Skip sources with backoff/quarantine.
+			if !p.Flow.GetHealth().Allow(source) {
+				core.LogInputPlugin(p.LogFields, source,
+					fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+				continue
+			}
+
+			data, found, err := receiveFiles(p, source, files)
+			if err != nil {
+				failedSources = append(failedSources, source)
+				p.Flow.GetHealth().Fail(source, err)
+				core.LogInputPlugin(p.LogFields, source, err)
+				continue
+			}
+			p.Flow.GetHealth().Success(source)
+
+			for _, item := range data {
+				if item.TIME.Unix() > sourceLastTime.Unix() {
+					sourceLastTime = item.TIME
+				}
+			}
+
+			temp = append(temp, data...)
+
+			flowStates[source] = sourceLastTime
+			core.LogInputPlugin(p.LogFields, source,
+				fmt.Sprintf("last update: %s, received data: %d, new data: %d", sourceLastTime, found, len(data)))
+
+			continue
+		}
+
 		itemLines := make([]string, 0)
 		itemMtime := ""
 		itemText := ""
 
 		if p.OptionFileIn {
+			// Skip sources with backoff/quarantine.
+			if !p.Flow.GetHealth().Allow(source) {
+				core.LogInputPlugin(p.LogFields, source,
+					fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+				continue
+			}
+
 			if mtime, err := core.IsFile(source); err == nil {
 				itemMtime = fmt.Sprintf("%v", mtime.Unix())
 			} else {
 				failedSources = append(failedSources, source)
+				p.Flow.GetHealth().Fail(source, err)
 				core.LogProcessPlugin(p.LogFields, err)
 				continue
 			}
@@ -736,10 +1385,13 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 				}
 			} else {
 				failedSources = append(failedSources, source)
+				p.Flow.GetHealth().Fail(source, err)
 				core.LogProcessPlugin(p.LogFields, err)
 				continue
 			}
 
+			p.Flow.GetHealth().Success(source)
+
 		} else {
 			itemText = source
 		}
@@ -814,52 +1466,69 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		return temp, err
 	}
 
+	// Files' states are saved after successful flow run (Commit).
+	p.m.Lock()
+	p.pendingFiles = files
+	p.m.Unlock()
+
 	// Check every source for expiration.
-	sourcesExpired := false
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
 
-	// Check if any source is expired.
-	for _, source := range p.OptionInput {
-		sourceTime := flowStates[source]
+	// Inform about sources failures.
+	if len(failedSources) > 0 {
+		return temp, core.ERROR_FLOW_SOURCE_FAIL
+	}
 
-		if (currentTime.Unix() - sourceTime.Unix()) > p.OptionExpireInterval/1000 {
-			sourcesExpired = true
+	// Inform about expiration.
+	if sourcesExpired {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
 
-			core.LogInputPlugin(p.LogFields, source,
-				fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
+	return temp, nil
+}
 
-			// Execute command if expire delay exceeded.
-			// ExpireLast keeps last execution timestamp.
-			if (currentTime.Unix() - p.OptionExpireLast) > p.OptionExpireActionDelay/1000 {
-				p.OptionExpireLast = currentTime.Unix()
+// Rollback discards files' states of the last run, files are read from saved positions next time.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
 
-				// Execute command with args.
-				// We don't worry about command return code.
-				if len(p.OptionExpireAction) > 0 {
-					cmd := p.OptionExpireAction[0]
-					args := []string{p.Flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
-					args = append(args, p.OptionExpireAction[1:]...)
+	p.pendingFiles = nil
 
-					output, err := core.ExecWithTimeout(cmd, args, p.OptionExpireActionTimeout)
+	return nil
+}
 
-					core.LogInputPlugin(p.LogFields, source, fmt.Sprintf(
-						"source expired action: command: %s, arguments: %v, output: %s, error: %v",
-						cmd, args, output, err))
-				}
-			}
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	for _, file := range p.OptionOutput {
+		if err := sendFile(p, file, data); err != nil {
+			core.LogOutputPlugin(p.LogFields, file, fmt.Errorf(INFO_WRITE_TO_FILE, file, err))
+			return err
 		}
 	}
 
-	// Inform about sources failures.
-	if len(failedSources) > 0 {
-		return temp, core.ERROR_FLOW_SOURCE_FAIL
+	return nil
+}
+
+// SaveFiles persists files' states, states of removed files are dropped.
+func (p *Plugin) SaveFiles(data map[string]fileState) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for file := range data {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			delete(data, file)
+		}
 	}
 
-	// Inform about expiration.
-	if sourcesExpired {
-		return temp, core.ERROR_FLOW_EXPIRE
+	if err := core.CreateDirIfNotExist(filepath.Dir(p.FilesFile)); err != nil {
+		return err
 	}
 
-	return temp, nil
+	return core.PluginSaveData(p.FilesFile, &data)
 }
 
 func (p *Plugin) SaveState(data map[string]time.Time) error {
@@ -873,7 +1542,8 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	// -----------------------------------------------------------------------------------------------------------------
 
 	plugin := Plugin{
-		Flow: pluginConfig.Flow,
+		Flow:      pluginConfig.Flow,
+		FilesFile: filepath.Join(pluginConfig.Flow.FlowDataDir, pluginConfig.PluginType, PLUGIN_NAME, DEFAULT_FILES_DB),
 		LogFields: log.Fields{
 			"hash":   pluginConfig.Flow.FlowHash,
 			"run":    pluginConfig.Flow.GetRunID(),
@@ -917,6 +1587,9 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		availableParams["expire_action_timeout"] = -1
 		availableParams["expire_delay"] = -1
 		availableParams["expire_interval"] = -1
+		availableParams["file_in_recursive"] = -1
+		availableParams["file_in_tail"] = -1
+		availableParams["file_in_tail_start"] = -1
 		availableParams["match_signature"] = -1
 		availableParams["match_ttl"] = -1
 		availableParams["time_format"] = -1
@@ -943,64 +1616,73 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		availableParams["text_pre"] = -1
 		availableParams["text_post"] = -1
 		availableParams["text_split"] = -1
+
+	case "output":
+		for _, param := range []string{"file_in", "file_in_mode", "file_in_pre", "file_in_post", "file_in_split", "input"} {
+			delete(availableParams, param)
+		}
+
+		availableParams["file_out_fields"] = -1
+		availableParams["file_out_format"] = -1
+		availableParams["file_out_gzip"] = -1
+		availableParams["file_out_pre"] = -1
+		availableParams["file_out_post"] = -1
+		availableParams["file_out_rotate_count"] = -1
+		availableParams["file_out_rotate_interval"] = -1
+		availableParams["file_out_rotate_size"] = -1
+		availableParams["file_out_split"] = -1
+		availableParams["file_out_template"] = -1
+		availableParams["output"] = 1
 	}
 
 	// -----------------------------------------------------------------------------------------------------------------
 	// Get plugin specific settings.
 
 	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+	fileOutTemplate := ""
 
 	// -----------------------------------------------------------------------------------------------------------------
 
 	switch pluginConfig.PluginType {
 	case "input":
-		// expire_action.
-		setExpireAction := func(p interface{}) {
-			if v, b := core.IsSliceOfString(p); b {
-				availableParams["expire_action"] = 0
-				plugin.OptionExpireAction = v
-			}
-		}
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(core.VIPER_DEFAULT_EXPIRE_ACTION))
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
-		setExpireAction((*pluginConfig.PluginParams)["expire_action"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action", plugin.OptionExpireAction)
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
 
-		// expire_action_delay.
-		setExpireActionDelay := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_action_delay"] = 0
-				plugin.OptionExpireActionDelay = v
+		// file_in_recursive.
+		setFileInRecursive := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["file_in_recursive"] = 0
+				plugin.OptionFileInRecursive = v
 			}
 		}
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
-		setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_delay", plugin.OptionExpireActionDelay)
+		setFileInRecursive(DEFAULT_FILE_IN_RECURSIVE)
+		setFileInRecursive(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_recursive", template)))
+		setFileInRecursive((*pluginConfig.PluginParams)["file_in_recursive"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_recursive", plugin.OptionFileInRecursive)
 
-		// expire_action_timeout.
-		setExpireActionTimeout := func(p interface{}) {
-			if v, b := core.IsInt(p); b {
-				availableParams["expire_action_timeout"] = 0
-				plugin.OptionExpireActionTimeout = v
+		// file_in_tail.
+		setFileInTail := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["file_in_tail"] = 0
+				plugin.OptionFileInTail = v
 			}
 		}
-		setExpireActionTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
-		setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
-		setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_timeout", plugin.OptionExpireActionTimeout)
+		setFileInTail(DEFAULT_FILE_IN_TAIL)
+		setFileInTail(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_tail", template)))
+		setFileInTail((*pluginConfig.PluginParams)["file_in_tail"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_tail", plugin.OptionFileInTail)
 
-		// expire_interval.
-		setExpireInterval := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_interval"] = 0
-				plugin.OptionExpireInterval = v
+		// file_in_tail_start.
+		setFileInTailStart := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_in_tail_start"] = 0
+				plugin.OptionFileInTailStart = v
 			}
 		}
-		setExpireInterval(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_INTERVAL))
-		setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
-		setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
-		core.ShowPluginParam(plugin.LogFields, "expire_interval", plugin.OptionExpireInterval)
+		setFileInTailStart(DEFAULT_FILE_IN_TAIL_START)
+		setFileInTailStart(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_tail_start", template)))
+		setFileInTailStart((*pluginConfig.PluginParams)["file_in_tail_start"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_tail_start", plugin.OptionFileInTailStart)
 
 		// match_signature.
 		setMatchSignature := func(p interface{}) {
@@ -1289,77 +1971,214 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		setTextSplit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.text_split", template)))
 		setTextSplit((*pluginConfig.PluginParams)["text_split"])
 		core.ShowPluginParam(plugin.LogFields, "text_split", plugin.OptionTextSplit)
-	}
 
-	// file_in.
-	setFileIn := func(p interface{}) {
-		if v, b := core.IsBool(p); b {
-			availableParams["file_in"] = 0
-			plugin.OptionFileIn = v
+	case "output":
+		// file_out_fields.
+		setFileOutFields := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["file_out_fields"] = 0
+				plugin.OptionFileOutFields = v
+			}
 		}
-	}
-	setFileIn(DEFAULT_FILE_IN)
-	setFileIn(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in", template)))
-	setFileIn((*pluginConfig.PluginParams)["file_in"])
-	core.ShowPluginParam(plugin.LogFields, "file_in", plugin.OptionFileIn)
+		setFileOutFields(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.file_out_fields", template)))
+		setFileOutFields((*pluginConfig.PluginParams)["file_out_fields"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_fields", plugin.OptionFileOutFields)
 
-	// file_in_mode.
-	setFileInMode := func(p interface{}) {
-		if v, b := core.IsString(p); b {
-			availableParams["file_in_mode"] = 0
-			plugin.OptionFileInMode = v
+		// file_out_format.
+		setFileOutFormat := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_out_format"] = 0
+				plugin.OptionFileOutFormat = v
+			}
 		}
-	}
-	setFileInMode(DEFAULT_FILE_IN_MODE)
-	setFileInMode(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_mode", template)))
-	setFileInMode((*pluginConfig.PluginParams)["file_in_mode"])
-	core.ShowPluginParam(plugin.LogFields, "file_in_mode", plugin.OptionFileInMode)
+		setFileOutFormat(DEFAULT_FILE_OUT_FORMAT)
+		setFileOutFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_format", template)))
+		setFileOutFormat((*pluginConfig.PluginParams)["file_out_format"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_format", plugin.OptionFileOutFormat)
 
-	// file_in_pre.
-	setFileInPre := func(p interface{}) {
-		if v, b := core.IsString(p); b {
-			availableParams["file_in_pre"] = 0
-			plugin.OptionFileInPre = v
+		// file_out_gzip.
+		setFileOutGzip := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["file_out_gzip"] = 0
+				plugin.OptionFileOutGzip = v
+			}
 		}
-	}
-	setFileInPre(DEFAULT_FILE_IN_PRE)
-	setFileInPre(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_pre", template)))
-	setFileInPre((*pluginConfig.PluginParams)["file_in_pre"])
-	core.ShowPluginParam(plugin.LogFields, "file_in_pre", plugin.OptionFileInPre)
+		setFileOutGzip(DEFAULT_FILE_OUT_GZIP)
+		setFileOutGzip(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_gzip", template)))
+		setFileOutGzip((*pluginConfig.PluginParams)["file_out_gzip"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_gzip", plugin.OptionFileOutGzip)
 
-	// file_in_post.
-	setFileInPost := func(p interface{}) {
-		if v, b := core.IsString(p); b {
-			availableParams["file_in_post"] = 0
-			plugin.OptionFileInPost = v
+		// file_out_pre.
+		setFileOutPre := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_out_pre"] = 0
+				plugin.OptionFileOutPre = v
+			}
 		}
-	}
-	setFileInPost(DEFAULT_FILE_IN_POST)
-	setFileInPost(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_post", template)))
-	setFileInPost((*pluginConfig.PluginParams)["file_in_post"])
-	core.ShowPluginParam(plugin.LogFields, "file_in_post", plugin.OptionFileInPost)
+		setFileOutPre(DEFAULT_FILE_OUT_PRE)
+		setFileOutPre(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_pre", template)))
+		setFileOutPre((*pluginConfig.PluginParams)["file_out_pre"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_pre", plugin.OptionFileOutPre)
+
+		// file_out_post.
+		setFileOutPost := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_out_post"] = 0
+				plugin.OptionFileOutPost = v
+			}
+		}
+		setFileOutPost(DEFAULT_FILE_OUT_POST)
+		setFileOutPost(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_post", template)))
+		setFileOutPost((*pluginConfig.PluginParams)["file_out_post"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_post", plugin.OptionFileOutPost)
+
+		// file_out_rotate_count.
+		setFileOutRotateCount := func(p interface{}) {
+			if v, b := core.IsInt(p); b {
+				availableParams["file_out_rotate_count"] = 0
+				plugin.OptionFileOutRotateCount = v
+			}
+		}
+		setFileOutRotateCount(DEFAULT_FILE_OUT_ROTATE_COUNT)
+		setFileOutRotateCount(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.file_out_rotate_count", template)))
+		setFileOutRotateCount((*pluginConfig.PluginParams)["file_out_rotate_count"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_rotate_count", plugin.OptionFileOutRotateCount)
 
-	// file_in_split.
-	setFileInSplit := func(p interface{}) {
-		if v, b := core.IsString(p); b {
-			availableParams["file_in_split"] = 0
-			plugin.OptionFileInSplit = v
+		// file_out_rotate_interval.
+		setFileOutRotateInterval := func(p interface{}) {
+			if v, b := core.IsInterval(p); b {
+				availableParams["file_out_rotate_interval"] = 0
+				plugin.OptionFileOutRotateInterval = time.Duration(v) * time.Millisecond
+			}
+		}
+		setFileOutRotateInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_rotate_interval", template)))
+		setFileOutRotateInterval((*pluginConfig.PluginParams)["file_out_rotate_interval"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_rotate_interval", plugin.OptionFileOutRotateInterval)
+
+		// file_out_rotate_size.
+		setFileOutRotateSize := func(p interface{}) {
+			if v, b := core.IsSize(p); b {
+				availableParams["file_out_rotate_size"] = 0
+				plugin.OptionFileOutRotateSize = v
+			}
 		}
+		setFileOutRotateSize(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_rotate_size", template)))
+		setFileOutRotateSize((*pluginConfig.PluginParams)["file_out_rotate_size"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_rotate_size", plugin.OptionFileOutRotateSize)
+
+		// file_out_split.
+		setFileOutSplit := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_out_split"] = 0
+				plugin.OptionFileOutSplit = v
+			}
+		}
+		setFileOutSplit(DEFAULT_FILE_OUT_SPLIT)
+		setFileOutSplit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_split", template)))
+		setFileOutSplit((*pluginConfig.PluginParams)["file_out_split"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_split", plugin.OptionFileOutSplit)
+
+		// file_out_template.
+		setFileOutTemplate := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_out_template"] = 0
+				fileOutTemplate = v
+			}
+		}
+		setFileOutTemplate(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_out_template", template)))
+		setFileOutTemplate((*pluginConfig.PluginParams)["file_out_template"])
+		core.ShowPluginParam(plugin.LogFields, "file_out_template", fileOutTemplate)
+
+		if t, err := tmpl.New("file_out_template").Funcs(core.TemplateFuncMap).Parse(fileOutTemplate); err == nil {
+			plugin.OptionFileOutTemplate = t
+		} else {
+			return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+		}
+
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
 	}
-	setFileInSplit(DEFAULT_FILE_IN_SPLIT)
-	setFileInSplit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_split", template)))
-	setFileInSplit((*pluginConfig.PluginParams)["file_in_split"])
-	core.ShowPluginParam(plugin.LogFields, "file_in_split", plugin.OptionFileInSplit)
 
-	// input.
-	setInput := func(p interface{}) {
-		if v, b := core.IsSliceOfString(p); b {
-			availableParams["input"] = 0
-			plugin.OptionInput = v
+	// Input parameters (file_in, file_in_mode, file_in_pre, file_in_post, file_in_split, input).
+	if pluginConfig.PluginType != "output" {
+		// file_in.
+		setFileIn := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["file_in"] = 0
+				plugin.OptionFileIn = v
+			}
 		}
+		setFileIn(DEFAULT_FILE_IN)
+		setFileIn(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in", template)))
+		setFileIn((*pluginConfig.PluginParams)["file_in"])
+		core.ShowPluginParam(plugin.LogFields, "file_in", plugin.OptionFileIn)
+
+		// file_in_mode.
+		setFileInMode := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_in_mode"] = 0
+				plugin.OptionFileInMode = v
+			}
+		}
+		setFileInMode(DEFAULT_FILE_IN_MODE)
+		setFileInMode(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_mode", template)))
+		setFileInMode((*pluginConfig.PluginParams)["file_in_mode"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_mode", plugin.OptionFileInMode)
+
+		// file_in_pre.
+		setFileInPre := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_in_pre"] = 0
+				plugin.OptionFileInPre = v
+			}
+		}
+		setFileInPre(DEFAULT_FILE_IN_PRE)
+		setFileInPre(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_pre", template)))
+		setFileInPre((*pluginConfig.PluginParams)["file_in_pre"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_pre", plugin.OptionFileInPre)
+
+		// file_in_post.
+		setFileInPost := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_in_post"] = 0
+				plugin.OptionFileInPost = v
+			}
+		}
+		setFileInPost(DEFAULT_FILE_IN_POST)
+		setFileInPost(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_post", template)))
+		setFileInPost((*pluginConfig.PluginParams)["file_in_post"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_post", plugin.OptionFileInPost)
+
+		// file_in_split.
+		setFileInSplit := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["file_in_split"] = 0
+				plugin.OptionFileInSplit = v
+			}
+		}
+		setFileInSplit(DEFAULT_FILE_IN_SPLIT)
+		setFileInSplit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.file_in_split", template)))
+		setFileInSplit((*pluginConfig.PluginParams)["file_in_split"])
+		core.ShowPluginParam(plugin.LogFields, "file_in_split", plugin.OptionFileInSplit)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = v
+			}
+		}
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
 	}
-	setInput((*pluginConfig.PluginParams)["input"])
-	core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
 
 	// timeout.
 	setTimeout := func(p interface{}) {
@@ -1383,10 +2202,14 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	// -----------------------------------------------------------------------------------------------------------------
 	// Additional checks.
 
-	if plugin.OptionFileInMode != "split" && plugin.OptionFileInMode != "text" {
+	if pluginConfig.PluginType != "output" && plugin.OptionFileInMode != "split" && plugin.OptionFileInMode != "text" {
 		return &Plugin{}, fmt.Errorf(ERROR_MODE_UNKNOWN.Error(), plugin.OptionFileInMode)
 	}
 
+	if pluginConfig.PluginType == "input" && plugin.OptionFileInTailStart != "beginning" && plugin.OptionFileInTailStart != "end" {
+		return &Plugin{}, fmt.Errorf(ERROR_TAIL_START.Error(), plugin.OptionFileInTailStart)
+	}
+
 	if pluginConfig.PluginType == "process" {
 		if len(plugin.OptionInput) != len(plugin.OptionOutput) {
 			return &Plugin{}, fmt.Errorf(
@@ -1399,6 +2222,28 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		}
 	}
 
+	if pluginConfig.PluginType == "output" {
+		switch plugin.OptionFileOutFormat {
+		case "csv":
+			if len(plugin.OptionFileOutFields) == 0 {
+				return &Plugin{}, fmt.Errorf(core.ERROR_PLUGIN_REQUIRED_PARAM.Error(), []string{"file_out_fields"})
+			}
+		case "jsonl":
+		case "template":
+			if fileOutTemplate == "" {
+				return &Plugin{}, fmt.Errorf(core.ERROR_PLUGIN_REQUIRED_PARAM.Error(), []string{"file_out_template"})
+			}
+		default:
+			return &Plugin{}, fmt.Errorf(ERROR_FORMAT_UNKNOWN.Error(), plugin.OptionFileOutFormat)
+		}
+
+		for _, field := range plugin.OptionFileOutFields {
+			if _, err := core.ReflectDatumField(&core.Datum{}, field); err != nil {
+				return &Plugin{}, err
+			}
+		}
+	}
+
 	// -----------------------------------------------------------------------------------------------------------------
 
 	return &plugin, nil
@@ -3,15 +3,19 @@ package kafkaMulti
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	tmpl "text/template"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
 	"github.com/linkedin/goavro/v2"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
 	log "github.com/livelace/logrus"
@@ -25,9 +29,13 @@ const (
 	DEFAULT_BATCH_SIZE     = 1000
 	DEFAULT_COMPRESSION    = "none"
 	DEFAULT_CONFLUENT_AVRO = true
+	DEFAULT_FORMAT         = "avro"
 	DEFAULT_LOG_LEVEL      = 0
+	DEFAULT_MANUAL_COMMIT  = false
 	DEFAULT_MATCH_TTL      = "1d"
 	DEFAULT_OFFSET         = "earliest"
+	DEFAULT_PERSISTENT     = false
+	DEFAULT_RAW_FIELD      = "data.text0"
 	DEFAULT_SCHEMA_BASE    = `
 {
   "type": "record",
@@ -47,14 +55,52 @@ const (
 )
 
 var (
+	ERROR_COMMIT_ERROR             = errors.New("cannot commit offsets: %v")
+	ERROR_FORMAT_UNKNOWN           = errors.New("format unknown: %s")
+	ERROR_MESSAGE_TEMPLATE_AVRO    = errors.New("message_template is not supported with avro format")
 	ERROR_OFFSET_UNKNOWN           = errors.New("offset unknown: %s")
+	ERROR_PARTITION_INVALID        = errors.New("partition invalid: %s")
 	ERROR_SCHEMA_CREATE            = errors.New("schema create error: %s")
 	ERROR_SCHEMA_ERROR             = errors.New("schema error: %s")
 	ERROR_SCHEMA_NOT_SET           = errors.New("schema not set")
+	ERROR_SEEK_ERROR               = errors.New("cannot seek offsets: %v")
+	ERROR_SEEK_UNKNOWN             = errors.New("seek must be offset, RFC3339 time or interval: %s")
 	ERROR_SEND_ERROR               = errors.New("cannot send data: %v")
 	ERROR_SUBJECT_STRATEGY_UNKNOWN = errors.New("schema subject strategy unknown: %s")
+	ERROR_TEMPLATE_INVALID         = errors.New("template invalid: %s")
 )
 
+// closeConsumer closes consumer, next run creates a new one.
+func closeConsumer(p *Plugin) error {
+	if p.Consumer == nil {
+		return nil
+	}
+
+	err := p.Consumer.Close()
+	p.Consumer = nil
+
+	return err
+}
+
+// convertValue converts JSON value into string.
+func convertValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		if b, err := json.Marshal(value); err == nil {
+			return string(b)
+		}
+		return fmt.Sprintf("%v", value)
+	}
+}
+
 func genSchema(p *Plugin, schema *map[string]interface{}) (string, error) {
 	var buffer bytes.Buffer
 	fields := make([]string, 0)
@@ -115,6 +161,258 @@ func genSchema(p *Plugin, schema *map[string]interface{}) (string, error) {
 	return buffer.String(), nil
 }
 
+// flattenConfig converts nested maps (keys with dots in config files) into librdkafka properties.
+func flattenConfig(prefix string, config map[string]interface{}, result map[string]string) {
+	for k, v := range config {
+		key := k
+		if prefix != "" {
+			key = fmt.Sprintf("%s.%s", prefix, k)
+		}
+
+		if m, ok := core.IsMapWithStringAsKey(v); ok {
+			flattenConfig(key, m, result)
+		} else {
+			result[key] = fmt.Sprintf("%v", v)
+		}
+	}
+}
+
+// formatMessage sets message key, headers and partition from datum.
+func formatMessage(p *Plugin, item *core.Datum, message *kafka.Message) error {
+	// Empty key means no key (messages are spread across partitions).
+	key, err := core.ExtractTemplateIntoString(item, p.OptionMessageKeyTemplate)
+	if err != nil {
+		return err
+	}
+
+	if key != "" {
+		message.Key = []byte(key)
+	}
+
+	headers, err := core.ExtractTemplateMapIntoStringMap(item, p.OptionHeadersTemplate)
+	if err != nil {
+		return err
+	}
+
+	headersKeys := make([]string, 0)
+	for k := range headers {
+		headersKeys = append(headersKeys, k)
+	}
+	sort.Strings(headersKeys)
+
+	for _, k := range headersKeys {
+		message.Headers = append(message.Headers, kafka.Header{Key: k, Value: []byte(headers[k])})
+	}
+
+	// Empty partition means any partition (partitioner decides by key).
+	partition, err := core.ExtractTemplateIntoString(item, p.OptionMessagePartitionTemplate)
+	if err != nil {
+		return err
+	}
+
+	if partition != "" {
+		v, err := strconv.ParseInt(partition, 10, 32)
+		if err != nil || v < 0 {
+			return fmt.Errorf(ERROR_PARTITION_INVALID.Error(), partition)
+		}
+
+		message.TopicPartition.Partition = int32(v)
+	}
+
+	return nil
+}
+
+// formatValue returns "json" or "raw" message value, message template has higher priority over schema and raw field.
+func formatValue(p *Plugin, item *core.Datum) ([]byte, error) {
+	if p.OptionMessageTemplate != nil {
+		s, err := core.ExtractTemplateIntoString(item, p.OptionMessageTemplate)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(s), nil
+	}
+
+	if p.OptionFormat == "raw" {
+		rv, _ := core.ReflectDatumField(item, p.OptionRawField)
+		return []byte(rv.String()), nil
+	}
+
+	// Schema-less messages contain whole datum.
+	if len(p.SchemaNative) > 0 {
+		return json.Marshal(populateSchema(p, item))
+	}
+
+	messageJson, err := core.PruneDatum(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(messageJson)
+}
+
+// getConsumer returns existing (persistent) consumer or creates a new one.
+func getConsumer(p *Plugin) (*kafka.Consumer, error) {
+	if p.Consumer != nil {
+		return p.Consumer, nil
+	}
+
+	consumer, err := kafka.NewConsumer(p.KafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Seek assigned partitions once after start.
+	rebalance := func(c *kafka.Consumer, event kafka.Event) error {
+		switch e := event.(type) {
+		case kafka.AssignedPartitions:
+			if p.OptionSeek != "" && !p.SeekDone {
+				if partitions, err := seekPartitions(p, c, e.Partitions); err == nil {
+					p.SeekDone = true
+					return c.Assign(partitions)
+				} else {
+					core.LogInputPlugin(p.LogFields, "seek", fmt.Errorf(ERROR_SEEK_ERROR.Error(), err))
+				}
+			}
+			return c.Assign(e.Partitions)
+
+		case kafka.RevokedPartitions:
+			return c.Unassign()
+		}
+
+		return nil
+	}
+
+	if err := consumer.SubscribeTopics(p.OptionInput, rebalance); err != nil {
+		_ = consumer.Close()
+		return nil, err
+	}
+
+	p.Consumer = consumer
+
+	return consumer, nil
+}
+
+// mapJson maps results of jq queries applied to JSON message into datum fields.
+func mapJson(p *Plugin, item *core.Datum, messageJson interface{}) error {
+	for query, field := range p.SchemaNative {
+		ro, _ := core.ReflectDatumField(item, field)
+		values := make([]interface{}, 0)
+
+		iter := p.SchemaQueries[query].Run(messageJson)
+
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+
+			if err, ok := v.(error); ok {
+				return err
+			}
+
+			// Expand arrays for array fields.
+			if a, ok := v.([]interface{}); ok && ro.Kind() == reflect.Slice {
+				values = append(values, a...)
+			} else if v != nil {
+				values = append(values, v)
+			}
+		}
+
+		// Handle absence of query results, fill with empty data.
+		if len(values) == 0 {
+			values = append(values, "")
+		}
+
+		switch ro.Kind() {
+		case reflect.String:
+			ro.SetString(convertValue(values[0]))
+		case reflect.Slice:
+			for _, v := range values {
+				ro.Set(reflect.Append(ro, reflect.ValueOf(convertValue(v))))
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseSeek parses seek value into offset or time (zero offset if time is set).
+func parseSeek(s string) (kafka.Offset, time.Time, error) {
+	switch strings.ToLower(s) {
+	case "beginning", "earliest":
+		return kafka.OffsetBeginning, time.Time{}, nil
+	case "end", "latest":
+		return kafka.OffsetEnd, time.Time{}, nil
+	}
+
+	// Absolute offset.
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil && v >= 0 {
+		return kafka.Offset(v), time.Time{}, nil
+	}
+
+	// Absolute time.
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return 0, t, nil
+	}
+
+	// Relative time (interval ago).
+	if v, b := core.IsInterval(s); b {
+		return 0, time.Now().Add(-time.Duration(v) * time.Millisecond), nil
+	}
+
+	return 0, time.Time{}, fmt.Errorf(ERROR_SEEK_UNKNOWN.Error(), s)
+}
+
+// populateSchema populates schema with datum data.
+func populateSchema(p *Plugin, item *core.Datum) map[string]interface{} {
+	schema := make(map[string]interface{}, 0)
+
+	for k, v := range p.SchemaNative {
+		// Try to detect/expand data fields and/or use value as string.
+		if rv, err := core.ReflectDatumField(item, v); err == nil {
+			switch rv.Kind() {
+			case reflect.String:
+				schema[k] = rv.Interface()
+			case reflect.Slice:
+				schema[k] = rv.Interface()
+			default:
+				// Some special fields like: UUID, Time, etc.
+				schema[k] = fmt.Sprintf("%v", rv)
+			}
+		} else {
+			schema[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return schema
+}
+
+// seekPartitions sets partitions offsets to seek value.
+func seekPartitions(p *Plugin, c *kafka.Consumer, partitions []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	offset, t, err := parseSeek(p.OptionSeek)
+	if err != nil {
+		return partitions, err
+	}
+
+	result := make([]kafka.TopicPartition, len(partitions))
+	copy(result, partitions)
+
+	if !t.IsZero() {
+		for i := range result {
+			result[i].Offset = kafka.Offset(t.UnixMilli())
+		}
+
+		return c.OffsetsForTimes(result, p.OptionTimeout*1000)
+	}
+
+	for i := range result {
+		result[i].Offset = offset
+	}
+
+	return result, nil
+}
+
 func sendData(p *Plugin, messages []*kafka.Message) error {
 	producer, err := kafka.NewProducer(p.KafkaConfig)
 	if err != nil {
@@ -151,6 +449,21 @@ func sendData(p *Plugin, messages []*kafka.Message) error {
 	return nil
 }
 
+// trackOffset remembers first received (rollback) and next (commit) offsets of partition.
+func trackOffset(p *Plugin, partition kafka.TopicPartition) {
+	partitionKey := fmt.Sprintf("%s:%d", *partition.Topic, partition.Partition)
+
+	if _, ok := p.OffsetsFirst[partitionKey]; !ok {
+		p.OffsetsFirst[partitionKey] = partition
+	}
+
+	p.OffsetsNext[partitionKey] = kafka.TopicPartition{
+		Topic:     partition.Topic,
+		Partition: partition.Partition,
+		Offset:    partition.Offset + 1,
+	}
+}
+
 func upsertSchema(p *Plugin, subject string) (*srclient.Schema, error) {
 	registrySchema, _ := p.SchemaRegistryClient.GetLatestSchema(subject, false)
 
@@ -166,7 +479,14 @@ type Plugin struct {
 
 	Flow *core.Flow
 
-	KafkaConfig *kafka.ConfigMap
+	Expire *core.ExpireCheck
+
+	Consumer     *kafka.Consumer
+	KafkaConfig  *kafka.ConfigMap
+	OffsetsFirst map[string]kafka.TopicPartition
+	OffsetsNext  map[string]kafka.TopicPartition
+	SeekDone     bool
+	StatesNext   map[string]time.Time
 
 	LogFields log.Fields
 
@@ -176,42 +496,87 @@ type Plugin struct {
 	SchemaCache          map[uint32]*goavro.Codec
 	SchemaCodec          *goavro.Codec
 	SchemaNative         map[string]interface{}
+	SchemaQueries        map[string]*gojq.Query
 	SchemaRegistryClient *srclient.SchemaRegistryClient
 
-	OptionBrokers               string
-	OptionClientId              string
-	OptionCompress              string
-	OptionConfluentAvro         bool
-	OptionExpireAction          []string
-	OptionExpireActionDelay     int64
-	OptionExpireActionTimeout   int
-	OptionExpireInterval        int64
-	OptionExpireLast            int64
-	OptionForce                 bool
-	OptionForceCount            int
-	OptionGroupId               string
-	OptionInput                 []string
-	OptionLogLevel              int
-	OptionMatchSignature        []string
-	OptionMatchTTL              time.Duration
-	OptionMessageKey            string
-	OptionOffset                string
-	OptionSendDelay             time.Duration
-	OptionOutput                []string
-	OptionSchema                string
-	OptionSchemaRecordName      string
-	OptionSchemaRecordNamespace string
-	OptionSchemaRegistry        string
-	OptionSchemaSubjectStrategy string
-	OptionTimeFormat            string
-	OptionTimeFormatA           string
-	OptionTimeFormatB           string
-	OptionTimeFormatC           string
-	OptionTimeZone              *time.Location
-	OptionTimeZoneA             *time.Location
-	OptionTimeZoneB             *time.Location
-	OptionTimeZoneC             *time.Location
-	OptionTimeout               int
+	OptionBrokers                  string
+	OptionClientId                 string
+	OptionCompress                 string
+	OptionConfig                   map[string]string
+	OptionConfluentAvro            bool
+	OptionForce                    bool
+	OptionForceCount               int
+	OptionFormat                   string
+	OptionGroupId                  string
+	OptionHeaders                  map[string]string
+	OptionHeadersTemplate          map[string]*tmpl.Template
+	OptionInput                    []string
+	OptionLogLevel                 int
+	OptionManualCommit             bool
+	OptionMatchSignature           []string
+	OptionMatchTTL                 time.Duration
+	OptionMessageKey               string
+	OptionMessageKeyTemplate       *tmpl.Template
+	OptionMessagePartition         string
+	OptionMessagePartitionTemplate *tmpl.Template
+	OptionMessageTemplate          *tmpl.Template
+	OptionOffset                   string
+	OptionSeek                     string
+	OptionSendDelay                time.Duration
+	OptionOutput                   []string
+	OptionPersistent               bool
+	OptionRawField                 string
+	OptionSchema                   string
+	OptionSchemaRecordName         string
+	OptionSchemaRecordNamespace    string
+	OptionSchemaRegistry           string
+	OptionSchemaSubjectStrategy    string
+	OptionTimeFormat               string
+	OptionTimeFormatA              string
+	OptionTimeFormatB              string
+	OptionTimeFormatC              string
+	OptionTimeZone                 *time.Location
+	OptionTimeZoneA                *time.Location
+	OptionTimeZoneB                *time.Location
+	OptionTimeZoneC                *time.Location
+	OptionTimeout                  int
+}
+
+func (p *Plugin) Commit() error {
+	var err error
+
+	if p.OptionManualCommit && p.Consumer != nil && len(p.OffsetsNext) > 0 {
+		offsets := make([]kafka.TopicPartition, 0)
+		for _, v := range p.OffsetsNext {
+			offsets = append(offsets, v)
+		}
+
+		if _, commitErr := p.Consumer.CommitOffsets(offsets); commitErr != nil {
+			err = fmt.Errorf(ERROR_COMMIT_ERROR.Error(), commitErr)
+		} else {
+			core.LogInputPlugin(p.LogFields, "commit", fmt.Sprintf("partitions committed: %d", len(offsets)))
+		}
+	}
+
+	// State is reset even if offsets weren't committed, messages are received again.
+	p.OffsetsFirst = make(map[string]kafka.TopicPartition)
+	p.OffsetsNext = make(map[string]kafka.TopicPartition)
+
+	// Signatures are saved only for delivered messages, rolled back messages aren't filtered out.
+	if p.StatesNext != nil {
+		if saveErr := p.SaveState(p.StatesNext); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		p.StatesNext = nil
+	}
+
+	if !p.OptionPersistent {
+		if closeErr := closeConsumer(p); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
 }
 
 func (p *Plugin) FlowLog(message interface{}) {
@@ -268,17 +633,15 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	}
 	core.LogInputPlugin(p.LogFields, "all", fmt.Sprintf("states loaded: %d", len(flowStates)))
 
-	// Create consumer.
-	consumer, err := kafka.NewConsumer(p.KafkaConfig)
+	// Create (or reuse persistent) consumer and subscribe to topics.
+	consumer, err := getConsumer(p)
 	if err != nil {
 		return temp, err
 	}
 
-	// Subscribe to topics.
-	err = consumer.SubscribeTopics(p.OptionInput, nil)
-	if err != nil {
-		return temp, err
-	}
+	// Offsets of received messages, committed after flow run.
+	p.OffsetsFirst = make(map[string]kafka.TopicPartition)
+	p.OffsetsNext = make(map[string]kafka.TopicPartition)
 
 	// Source stat.
 	sourceFailStat := make(map[string]int32)
@@ -291,48 +654,83 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		message, err := consumer.ReadMessage(time.Duration(p.OptionTimeout) * time.Second)
 		if message == nil && err.(kafka.Error).Code() == kafka.ErrTimedOut {
 			break
+		} else if message == nil && err.(kafka.Error).Code() == kafka.ErrMaxPollExceeded {
+			// Persistent consumer rejoins group after long pause between flow runs.
+			core.LogInputPlugin(p.LogFields, "consumer", err)
+			continue
 		} else if err != nil {
 			return temp, err
 		}
 
+		// Remember offsets for commit/rollback.
+		trackOffset(p, message.TopicPartition)
+
 		// Update source overwall (valid and invalid messages) stat.
 		sourceTotalStat[*message.TopicPartition.Topic] += 1
 
 		// Try to decode message.
-		var messageData interface{}
-		var schemaId uint32
-		isConfluentAvro := p.OptionConfluentAvro && len(message.Value) > 5 && message.Value[0] == 0
+		var messageJson interface{}
+		var messageMap map[string]interface{}
+		var messageMapValid bool
 
-		if isConfluentAvro {
-			schemaId = binary.BigEndian.Uint32(message.Value[1:5])
+		switch p.OptionFormat {
+		case "avro":
+			var messageData interface{}
+			var schemaId uint32
+			isConfluentAvro := p.OptionConfluentAvro && len(message.Value) > 5 && message.Value[0] == 0
 
-			if _, ok := p.SchemaCache[schemaId]; !ok {
-				if registrySchema, err := p.SchemaRegistryClient.GetSchema(int(schemaId)); err == nil {
-					p.SchemaCache[schemaId] = registrySchema.Codec()
-				} else {
-					core.LogInputPlugin(p.LogFields, "schema", fmt.Errorf("skip message: %v", err))
-					continue
+			if isConfluentAvro {
+				schemaId = binary.BigEndian.Uint32(message.Value[1:5])
+
+				if _, ok := p.SchemaCache[schemaId]; !ok {
+					if registrySchema, err := p.SchemaRegistryClient.GetSchema(int(schemaId)); err == nil {
+						p.SchemaCache[schemaId] = registrySchema.Codec()
+					} else {
+						core.LogInputPlugin(p.LogFields, "schema", fmt.Errorf("skip message: %v", err))
+						continue
+					}
 				}
+
+				messageData, _, err = p.SchemaCache[schemaId].NativeFromBinary(message.Value[5:])
+			} else {
+				messageData, _, err = p.SchemaCodec.NativeFromBinary(message.Value)
 			}
 
-			messageData, _, err = p.SchemaCache[schemaId].NativeFromBinary(message.Value[5:])
-		} else {
-			messageData, _, err = p.SchemaCodec.NativeFromBinary(message.Value)
-		}
+			if err != nil {
+				sourceFailStat[*message.TopicPartition.Topic] += 1
 
-		if err != nil {
-			sourceFailStat[*message.TopicPartition.Topic] += 1
+				if isConfluentAvro {
+					core.LogInputPlugin(p.LogFields, "decode", fmt.Errorf("schema id: %d, skip message: %v", schemaId, err))
+				} else {
+					core.LogInputPlugin(p.LogFields, "decode", fmt.Errorf("skip message: %v", err))
+				}
+				continue
+			}
 
-			if isConfluentAvro {
-				core.LogInputPlugin(p.LogFields, "decode", fmt.Errorf("schema id: %d, skip message: %v", schemaId, err))
-			} else {
+			messageMap, messageMapValid = core.IsMapWithStringAsKey(messageData)
+
+		case "json":
+			if err := json.Unmarshal(message.Value, &messageJson); err != nil {
+				sourceFailStat[*message.TopicPartition.Topic] += 1
 				core.LogInputPlugin(p.LogFields, "decode", fmt.Errorf("skip message: %v", err))
+				continue
 			}
-			continue
+
+			messageMapValid = true
+
+		case "raw":
+			messageMapValid = true
 		}
 
+		// Message headers.
+		messageHeaders := make([]string, 0)
+		for _, header := range message.Headers {
+			messageHeaders = append(messageHeaders, fmt.Sprintf("%s: %s", header.Key, header.Value))
+		}
+		sort.Strings(messageHeaders)
+
 		// Form new dataItem.
-		if messageMap, messageMapValid := core.IsMapWithStringAsKey(messageData); messageMapValid {
+		if messageMapValid {
 			var currentTime = time.Now().UTC()
 			var itemNew = false
 			var itemSignature string
@@ -349,36 +747,58 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 				TIMEFORMATB: currentTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
 				TIMEFORMATC: currentTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
 				UUID:        u,
+
+				KAFKA: core.Kafka{
+					HEADERS:   messageHeaders,
+					KEY:       string(message.Key),
+					OFFSET:    fmt.Sprintf("%d", message.TopicPartition.Offset),
+					PARTITION: fmt.Sprintf("%d", message.TopicPartition.Partition),
+					TIMESTAMP: fmt.Sprintf("%v", message.Timestamp.Unix()),
+				},
 			}
 
 			// Map message data into item fields.
-			for fieldName, fieldValue := range p.SchemaNative {
-				ri := reflect.ValueOf(messageMap[fieldName])
-				ro, _ := core.ReflectDatumField(&item, fieldValue)
-
-				// Handle absence schema's key in message data.
-				// Handle in/out type mismatch.
-				// Fill with empty data.
-				// BTW. Output is always right!
-				if _, ok := messageMap[fieldName]; !ok || ri.Kind() != ro.Kind() {
-					switch ro.Kind() {
+			switch p.OptionFormat {
+			case "avro":
+				for fieldName, fieldValue := range p.SchemaNative {
+					ri := reflect.ValueOf(messageMap[fieldName])
+					ro, _ := core.ReflectDatumField(&item, fieldValue)
+
+					// Handle absence schema's key in message data.
+					// Handle in/out type mismatch.
+					// Fill with empty data.
+					// BTW. Output is always right!
+					if _, ok := messageMap[fieldName]; !ok || ri.Kind() != ro.Kind() {
+						switch ro.Kind() {
+						case reflect.String:
+							ro.SetString("")
+						case reflect.Slice:
+							ro.Set(reflect.Append(ro, reflect.ValueOf("")))
+						}
+						continue
+					}
+
+					// Populate datum with field data.
+					switch ri.Kind() {
 					case reflect.String:
-						ro.SetString("")
+						ro.SetString(ri.String())
 					case reflect.Slice:
-						ro.Set(reflect.Append(ro, reflect.ValueOf("")))
+						for i := 0; i < ri.Len(); i++ {
+							ro.Set(reflect.Append(ro, reflect.ValueOf(ri.Index(i).Interface())))
+						}
 					}
-					continue
 				}
 
-				// Populate datum with field data.
-				switch ri.Kind() {
-				case reflect.String:
-					ro.SetString(ri.String())
-				case reflect.Slice:
-					for i := 0; i < ri.Len(); i++ {
-						ro.Set(reflect.Append(ro, reflect.ValueOf(ri.Index(i).Interface())))
-					}
+			case "json":
+				if err := mapJson(p, &item, messageJson); err != nil {
+					sourceFailStat[item.SOURCE] += 1
+					core.LogInputPlugin(p.LogFields, "decode", fmt.Errorf("skip message: %v", err))
+					continue
 				}
+
+			case "raw":
+				ro, _ := core.ReflectDatumField(&item, p.OptionRawField)
+				ro.SetString(string(message.Value))
 			}
 
 			// Process only new items. Two methods:
@@ -472,8 +892,10 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		}
 	}
 
-	// Close consumer.
-	err = consumer.Close()
+	// Close consumer, otherwise it's closed after commit/rollback or kept between runs.
+	if !p.OptionManualCommit && !p.OptionPersistent {
+		err = closeConsumer(p)
+	}
 
 	// Show source (topics) statistics.
 	for _, source := range p.OptionInput {
@@ -481,47 +903,11 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 			flowStates[source], sourceTotalStat[source], sourceNewStat[source], sourceFailStat[source]))
 	}
 
-	// Save updated flow states.
-	if err := p.SaveState(flowStates); err != nil {
-		return temp, err
-	}
+	// Updated flow states are saved after successful flow run (Commit).
+	p.StatesNext = flowStates
 
 	// Check every source for expiration.
-	sourcesExpired := false
-
-    // Check if any source is expired.
-	currentTime := time.Now().UTC()
-    
-    for _, source := range p.OptionInput {
-        sourceTime := flowStates[source]
-		
-        if (currentTime.Unix() - sourceTime.Unix()) > p.OptionExpireInterval / 1000 {
-			sourcesExpired = true
-            
-            core.LogInputPlugin(p.LogFields, source, 
-                fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
-
-			// Execute command if expire delay exceeded.
-			// ExpireLast keeps last execution timestamp.
-			if (currentTime.Unix() - p.OptionExpireLast) > p.OptionExpireActionDelay / 1000 {
-				p.OptionExpireLast = currentTime.Unix()
-
-				// Execute command with args.
-				// We don't worry about command return code.
-				if len(p.OptionExpireAction) > 0 {
-					cmd := p.OptionExpireAction[0]
-					args := []string{p.Flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
-					args = append(args, p.OptionExpireAction[1:]...)
-
-					output, err := core.ExecWithTimeout(cmd, args, p.OptionExpireActionTimeout)
-
-					core.LogInputPlugin(p.LogFields, source, fmt.Sprintf(
-						"source expired action: command: %s, arguments: %v, output: %s, error: %v",
-						cmd, args, output, err))
-				}
-			}
-		}
-    }
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
 
 	// Inform about expiration.
 	if sourcesExpired {
@@ -531,6 +917,35 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	return temp, err
 }
 
+func (p *Plugin) Rollback() error {
+	var err error
+
+	// Persistent consumer keeps position, return to first received messages.
+	if p.OptionManualCommit && p.OptionPersistent && p.Consumer != nil {
+		for _, v := range p.OffsetsFirst {
+			if seekErr := p.Consumer.Seek(v, p.OptionTimeout*1000); seekErr != nil {
+				err = fmt.Errorf(ERROR_SEEK_ERROR.Error(), seekErr)
+			}
+		}
+
+		if err == nil && len(p.OffsetsFirst) > 0 {
+			core.LogInputPlugin(p.LogFields, "rollback", fmt.Sprintf("partitions rolled back: %d", len(p.OffsetsFirst)))
+		}
+	}
+
+	p.OffsetsFirst = make(map[string]kafka.TopicPartition)
+	p.OffsetsNext = make(map[string]kafka.TopicPartition)
+	p.StatesNext = nil
+
+	if !p.OptionPersistent {
+		if closeErr := closeConsumer(p); closeErr != nil {
+			return closeErr
+		}
+	}
+
+	return err
+}
+
 func (p *Plugin) SaveState(data map[string]time.Time) error {
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -547,38 +962,32 @@ func (p *Plugin) Send(data []*core.Datum) error {
 		messages := make([]*kafka.Message, 0)
 
 		for _, item := range data {
-			// Populate schema with data.
-			schema := make(map[string]interface{}, 0)
+			// Assemble Kafka message.
+			message := kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			}
 
-			for k, v := range p.SchemaNative {
-				// Try to detect/expand data fields and/or use value as string.
-				if rv, err := core.ReflectDatumField(item, v); err == nil {
-					switch rv.Kind() {
-					case reflect.String:
-						schema[k] = rv.Interface()
-					case reflect.Slice:
-						schema[k] = rv.Interface()
-					default:
-						// Some special fields like: UUID, Time, etc.
-						schema[k] = fmt.Sprintf("%v", rv)
-					}
-				} else {
-					schema[k] = fmt.Sprintf("%v", v)
+			if err := formatMessage(p, item, &message); err != nil {
+				return err
+			}
+
+			if p.OptionFormat != "avro" {
+				value, err := formatValue(p, item)
+				if err != nil {
+					return err
 				}
+
+				message.Value = value
+				messages = append(messages, &message)
+				continue
 			}
 
 			// Convert data into Avro binary.
-			avroBinary, err := p.SchemaCodec.BinaryFromNative(nil, schema)
+			avroBinary, err := p.SchemaCodec.BinaryFromNative(nil, populateSchema(p, item))
 			if err != nil {
 				return err
 			}
 
-			// Assemble Kafka message.
-			message := kafka.Message{
-				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-				Key:            []byte(p.OptionMessageKey),
-			}
-
 			// Create confluent avro (magic + version + message) or vanilla avro message.
 			if p.OptionConfluentAvro {
 				var subject string
@@ -663,9 +1072,12 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 
 		"brokers":                 1,
 		"client_id":               -1,
+		"config":                  -1,
 		"confluent_avro":          -1,
+		"format":                  -1,
 		"log_level":               -1,
-		"schema":                  1,
+		"raw_field":               -1,
+		"schema":                  -1,
 		"schema_record_name":      -1,
 		"schema_record_namespace": -1,
 		"schema_registry":         -1,
@@ -680,9 +1092,12 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		availableParams["force"] = -1
 		availableParams["group_id"] = -1
 		availableParams["input"] = 1
+		availableParams["manual_commit"] = -1
 		availableParams["match_signature"] = -1
 		availableParams["match_ttl"] = -1
 		availableParams["offset"] = -1
+		availableParams["persistent"] = -1
+		availableParams["seek"] = -1
 		availableParams["send_delay"] = -1
 		availableParams["time_format"] = -1
 		availableParams["time_format_a"] = -1
@@ -694,8 +1109,11 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		availableParams["time_zone_c"] = -1
 	case "output":
 		availableParams["compress"] = -1
+		availableParams["headers"] = -1
 		availableParams["output"] = 1
 		availableParams["message_key"] = -1
+		availableParams["message_partition"] = -1
+		availableParams["message_template"] = -1
 		availableParams["schema_subject_strategy"] = -1
 	}
 
@@ -704,58 +1122,15 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 
 	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
 
+	var messageTemplate string
+
 	// -----------------------------------------------------------------------------------------------------------------
 
 	switch pluginConfig.PluginType {
 
 	case "input":
-		// expire_action.
-		setExpireAction := func(p interface{}) {
-			if v, b := core.IsSliceOfString(p); b {
-				availableParams["expire_action"] = 0
-				plugin.OptionExpireAction = v
-			}
-		}
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(core.VIPER_DEFAULT_EXPIRE_ACTION))
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
-		setExpireAction((*pluginConfig.PluginParams)["expire_action"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action", plugin.OptionExpireAction)
-
-		// expire_action_delay.
-		setExpireActionDelay := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_action_delay"] = 0
-				plugin.OptionExpireActionDelay = v
-			}
-		}
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
-		setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_delay", plugin.OptionExpireActionDelay)
-
-		// expire_action_timeout.
-		setExpireActionTimeout := func(p interface{}) {
-			if v, b := core.IsInt(p); b {
-				availableParams["expire_action_timeout"] = 0
-				plugin.OptionExpireActionTimeout = v
-			}
-		}
-		setExpireActionTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
-		setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
-		setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_timeout", plugin.OptionExpireActionTimeout)
-
-		// expire_interval.
-		setExpireInterval := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_interval"] = 0
-				plugin.OptionExpireInterval = v
-			}
-		}
-		setExpireInterval(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_INTERVAL))
-		setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
-		setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
-		core.ShowPluginParam(plugin.LogFields, "expire_interval", plugin.OptionExpireInterval)
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
 
 		// force.
 		setForce := func(p interface{}) {
@@ -807,6 +1182,18 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		setInput((*pluginConfig.PluginParams)["input"])
 		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
 
+		// manual_commit.
+		setManualCommit := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["manual_commit"] = 0
+				plugin.OptionManualCommit = v
+			}
+		}
+		setManualCommit(DEFAULT_MANUAL_COMMIT)
+		setManualCommit(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.manual_commit", template)))
+		setManualCommit((*pluginConfig.PluginParams)["manual_commit"])
+		core.ShowPluginParam(plugin.LogFields, "manual_commit", plugin.OptionManualCommit)
+
 		// match_signature.
 		setMatchSignature := func(p interface{}) {
 			if v, b := core.IsSliceOfString(p); b {
@@ -843,6 +1230,32 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		setOffset((*pluginConfig.PluginParams)["offset"])
 		core.ShowPluginParam(plugin.LogFields, "offset", plugin.OptionOffset)
 
+		// persistent.
+		setPersistent := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["persistent"] = 0
+				plugin.OptionPersistent = v
+			}
+		}
+		setPersistent(DEFAULT_PERSISTENT)
+		setPersistent(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.persistent", template)))
+		setPersistent((*pluginConfig.PluginParams)["persistent"])
+		core.ShowPluginParam(plugin.LogFields, "persistent", plugin.OptionPersistent)
+
+		// seek.
+		setSeek := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["seek"] = 0
+				plugin.OptionSeek = v
+			} else if v, b := core.IsInt(p, true); b {
+				availableParams["seek"] = 0
+				plugin.OptionSeek = strconv.Itoa(v)
+			}
+		}
+		setSeek(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.seek", template)))
+		setSeek((*pluginConfig.PluginParams)["seek"])
+		core.ShowPluginParam(plugin.LogFields, "seek", plugin.OptionSeek)
+
 		// send_delay.
 		setSendDelay := func(p interface{}) {
 			if v, b := core.IsInterval(p); b {
@@ -964,6 +1377,88 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		setCompress((*pluginConfig.PluginParams)["compress"])
 		core.ShowPluginParam(plugin.LogFields, "compress", plugin.OptionCompress)
 
+		// headers.
+		templateHeaders, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.headers", template)))
+		configHeaders, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["headers"])
+		mergedHeaders := make(map[string]string, 0)
+		mergedHeadersTemplate := make(map[string]*tmpl.Template, 0)
+
+		for k, v := range templateHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range configHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range mergedHeaders {
+			if t, err := tmpl.New(k).Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				mergedHeadersTemplate[k] = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		plugin.OptionHeaders = mergedHeaders
+		plugin.OptionHeadersTemplate = mergedHeadersTemplate
+		core.ShowPluginParam(plugin.LogFields, "headers", plugin.OptionHeaders)
+
+		// message_key.
+		setMessageKey := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["message_key"] = 0
+				plugin.OptionMessageKey = v
+			}
+		}
+		setMessageKey(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.message_key", template)))
+		setMessageKey((*pluginConfig.PluginParams)["message_key"])
+		core.ShowPluginParam(plugin.LogFields, "message_key", plugin.OptionMessageKey)
+
+		if t, err := tmpl.New("message_key").Funcs(core.TemplateFuncMap).Parse(plugin.OptionMessageKey); err == nil {
+			plugin.OptionMessageKeyTemplate = t
+		} else {
+			return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+		}
+
+		// message_partition.
+		setMessagePartition := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["message_partition"] = 0
+				plugin.OptionMessagePartition = v
+			} else if v, b := core.IsInt(p, true); b {
+				availableParams["message_partition"] = 0
+				plugin.OptionMessagePartition = strconv.Itoa(v)
+			}
+		}
+		setMessagePartition(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.message_partition", template)))
+		setMessagePartition((*pluginConfig.PluginParams)["message_partition"])
+		core.ShowPluginParam(plugin.LogFields, "message_partition", plugin.OptionMessagePartition)
+
+		if t, err := tmpl.New("message_partition").Funcs(core.TemplateFuncMap).Parse(plugin.OptionMessagePartition); err == nil {
+			plugin.OptionMessagePartitionTemplate = t
+		} else {
+			return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+		}
+
+		// message_template.
+		setMessageTemplate := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["message_template"] = 0
+				messageTemplate = v
+			}
+		}
+		setMessageTemplate(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.message_template", template)))
+		setMessageTemplate((*pluginConfig.PluginParams)["message_template"])
+		core.ShowPluginParam(plugin.LogFields, "message_template", messageTemplate)
+
+		if messageTemplate != "" {
+			if t, err := tmpl.New("message_template").Funcs(core.TemplateFuncMap).Parse(messageTemplate); err == nil {
+				plugin.OptionMessageTemplate = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
 		// output.
 		setOutput := func(p interface{}) {
 			if v, b := core.IsSliceOfString(p); b {
@@ -999,6 +1494,29 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	setServer((*pluginConfig.PluginParams)["brokers"])
 	core.ShowPluginParam(plugin.LogFields, "brokers", plugin.OptionBrokers)
 
+	// config.
+	templateConfig, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.config", template)))
+	configConfig, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["config"])
+	mergedConfig := make(map[string]string, 0)
+
+	// config has higher priority over template config.
+	flattenConfig("", templateConfig, mergedConfig)
+	flattenConfig("", configConfig, mergedConfig)
+
+	if len(mergedConfig) > 0 {
+		availableParams["config"] = 0
+	}
+
+	plugin.OptionConfig = mergedConfig
+
+	// Show only properties names, values might contain secrets.
+	configKeys := make([]string, 0)
+	for k := range mergedConfig {
+		configKeys = append(configKeys, k)
+	}
+	sort.Strings(configKeys)
+	core.ShowPluginParam(plugin.LogFields, "config", configKeys)
+
 	// confluent_avro.
 	setConfluentAvro := func(p interface{}) {
 		if v, b := core.IsBool(p); b {
@@ -1026,6 +1544,18 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		plugin.OptionClientId = plugin.Flow.FlowName
 	}
 
+	// format.
+	setFormat := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["format"] = 0
+			plugin.OptionFormat = strings.ToLower(v)
+		}
+	}
+	setFormat(DEFAULT_FORMAT)
+	setFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.format", template)))
+	setFormat((*pluginConfig.PluginParams)["format"])
+	core.ShowPluginParam(plugin.LogFields, "format", plugin.OptionFormat)
+
 	// log_level.
 	setLogLevel := func(p interface{}) {
 		if v, b := core.IsInt(p); b {
@@ -1038,6 +1568,18 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	setLogLevel((*pluginConfig.PluginParams)["log_level"])
 	core.ShowPluginParam(plugin.LogFields, "log_level", plugin.OptionLogLevel)
 
+	// raw_field.
+	setRawField := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["raw_field"] = 0
+			plugin.OptionRawField = v
+		}
+	}
+	setRawField(DEFAULT_RAW_FIELD)
+	setRawField(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.raw_field", template)))
+	setRawField((*pluginConfig.PluginParams)["raw_field"])
+	core.ShowPluginParam(plugin.LogFields, "raw_field", plugin.OptionRawField)
+
 	// schema_record_name.
 	setSchemaRecordName := func(p interface{}) {
 		if v, b := core.IsString(p); b {
@@ -1089,7 +1631,30 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		mergedSchema[k] = v
 	}
 
-	if len(mergedSchema) > 0 {
+	// json schema maps jq queries (input) or keys (output) to datum fields.
+	if len(mergedSchema) > 0 && plugin.OptionFormat != "avro" {
+		availableParams["schema"] = 0
+		plugin.SchemaNative = mergedSchema
+		plugin.SchemaQueries = make(map[string]*gojq.Query, 0)
+
+		if plugin.PluginType == "input" {
+			for k := range mergedSchema {
+				q := k
+				if !strings.HasPrefix(q, ".") {
+					q = "." + q
+				}
+
+				if query, err := gojq.Parse(q); err == nil {
+					plugin.SchemaQueries[k] = query
+				} else {
+					return &Plugin{}, fmt.Errorf(ERROR_SCHEMA_ERROR.Error(), err)
+				}
+			}
+		}
+
+		core.ShowPluginParam(plugin.LogFields, "schema", plugin.SchemaNative)
+
+	} else if len(mergedSchema) > 0 {
 		if v, err := genSchema(&plugin, &mergedSchema); err == nil {
 			if c, err := goavro.NewCodec(v); err == nil {
 				availableParams["schema"] = 0
@@ -1131,10 +1696,45 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	// -----------------------------------------------------------------------------------------------------------------
 	// Additional checks.
 
+	switch plugin.OptionFormat {
+	case "avro":
+		if len(plugin.SchemaNative) == 0 {
+			return &Plugin{}, fmt.Errorf(core.ERROR_PLUGIN_REQUIRED_PARAM.Error(), []string{"schema"})
+		}
+
+		if plugin.OptionMessageTemplate != nil {
+			return &Plugin{}, ERROR_MESSAGE_TEMPLATE_AVRO
+		}
+	case "json":
+		if plugin.PluginType == "input" && len(plugin.SchemaNative) == 0 {
+			return &Plugin{}, fmt.Errorf(core.ERROR_PLUGIN_REQUIRED_PARAM.Error(), []string{"schema"})
+		}
+
+		if plugin.PluginType == "input" {
+			for _, v := range plugin.SchemaNative {
+				if kind, err := core.GetDatumFieldType(v); err != nil || (kind != reflect.String && kind != reflect.Slice) {
+					return &Plugin{}, fmt.Errorf(core.ERROR_DATA_FIELD_UNKNOWN.Error(), v)
+				}
+			}
+		}
+	case "raw":
+		if err := core.IsDatumFieldsString(&[]string{plugin.OptionRawField}); err != nil {
+			return &Plugin{}, err
+		}
+	default:
+		return &Plugin{}, fmt.Errorf(ERROR_FORMAT_UNKNOWN.Error(), plugin.OptionFormat)
+	}
+
 	if plugin.PluginType == "input" {
 		if plugin.OptionOffset != "earliest" && plugin.OptionOffset != "latest" && plugin.OptionOffset != "none" {
 			return &Plugin{}, fmt.Errorf(ERROR_OFFSET_UNKNOWN.Error(), plugin.OptionOffset)
 		}
+
+		if plugin.OptionSeek != "" {
+			if _, _, err := parseSeek(plugin.OptionSeek); err != nil {
+				return &Plugin{}, err
+			}
+		}
 	}
 
 	// -----------------------------------------------------------------------------------------------------------------
@@ -1150,6 +1750,7 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	switch plugin.PluginType {
 	case "input":
 		kafkaConfig["auto.offset.reset"] = plugin.OptionOffset
+		kafkaConfig["enable.auto.commit"] = !plugin.OptionManualCommit
 		kafkaConfig["group.id"] = plugin.OptionGroupId
 		break
 	case "output":
@@ -1158,6 +1759,11 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 		break
 	}
 
+	// Arbitrary librdkafka properties (SASL/SSL etc.) have the highest priority.
+	for k, v := range plugin.OptionConfig {
+		kafkaConfig[k] = v
+	}
+
 	plugin.KafkaConfig = &kafkaConfig
 
 	// -----------------------------------------------------------------------------------------------------------------
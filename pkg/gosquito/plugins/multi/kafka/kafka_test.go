@@ -0,0 +1,215 @@
+package kafkaMulti
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"github.com/spf13/viper"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+func newPlugin(t *testing.T, pluginType string, params map[string]interface{}) *Plugin {
+	appConfig := viper.New()
+	appConfig.Set(core.VIPER_DEFAULT_EXPIRE_INTERVAL, "1d")
+	appConfig.Set(core.VIPER_DEFAULT_TIME_ZONE, "UTC")
+	core.SetStateBackend(appConfig)
+
+	params["brokers"] = "127.0.0.1:9092"
+
+	p, err := Init(&core.PluginConfig{
+		AppConfig:    appConfig,
+		Flow:         &core.Flow{FlowName: "kafka", FlowStateDir: t.TempDir()},
+		PluginParams: &params,
+		PluginType:   pluginType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestMapJson(t *testing.T) {
+	p := newPlugin(t, "input", map[string]interface{}{
+		"input":  []interface{}{"news"},
+		"format": "json",
+		"schema": map[string]interface{}{
+			".title":     "data.text0",
+			"user.id":    "data.text1",
+			".tags":      "data.array0",
+			".meta":      "data.text2",
+			".missing":   "data.text3",
+			".user.flag": "data.text4",
+		},
+	})
+
+	var message interface{}
+	if err := json.Unmarshal([]byte(`{"title": "hello", "user": {"id": 42, "flag": true}, "tags": ["a", "b"], "meta": {"k": "v"}}`), &message); err != nil {
+		t.Fatal(err)
+	}
+
+	item := core.Datum{}
+	if err := mapJson(p, &item, message); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{item.DATA.TEXT0, item.DATA.TEXT1, item.DATA.TEXT2, item.DATA.TEXT3, item.DATA.TEXT4}
+	if want := []string{"hello", "42", `{"k":"v"}`, "", "true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf(`mapJson() = %q, want %q`, got, want)
+	}
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(item.DATA.ARRAY0, want) {
+		t.Errorf(`mapJson() array = %q, want %q`, item.DATA.ARRAY0, want)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	item := &core.Datum{RSS: core.Rss{LINK: "https://example.com", TITLE: "hello"}}
+
+	tests := []struct {
+		params map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"format": "raw", "raw_field": "rss.title"}, "hello"},
+		{map[string]interface{}{"format": "raw", "message_template": "{{ .RSS.TITLE }}!"}, "hello!"},
+		{map[string]interface{}{"format": "json", "schema": map[string]interface{}{"title": "rss.title"}},
+			`{"title":"hello"}`},
+		{map[string]interface{}{"format": "json", "message_template": `{"link": "{{ .RSS.LINK }}"}`},
+			`{"link": "https://example.com"}`},
+	}
+
+	for _, test := range tests {
+		test.params["output"] = []interface{}{"news"}
+		p := newPlugin(t, "output", test.params)
+
+		if got, err := formatValue(p, item); err != nil || string(got) != test.want {
+			t.Errorf(`formatValue(%v) = %s, %v, want %s`, test.params, got, err, test.want)
+		}
+	}
+
+	// Schema-less json messages contain whole datum.
+	p := newPlugin(t, "output", map[string]interface{}{"output": []interface{}{"news"}, "format": "json"})
+
+	got, err := formatValue(p, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message struct{ RSS struct{ TITLE string } }
+	if err := json.Unmarshal(got, &message); err != nil || message.RSS.TITLE != "hello" {
+		t.Errorf(`formatValue() without schema = %s, %v`, got, err)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	p := newPlugin(t, "output", map[string]interface{}{
+		"output":            []interface{}{"news"},
+		"format":            "raw",
+		"message_key":       "{{ .RSS.LINK }}",
+		"message_partition": "{{ .DATA.TEXT0 }}",
+		"headers":           map[string]interface{}{"title": "{{ .RSS.TITLE }}", "source": "rss"},
+	})
+
+	topic := "news"
+
+	tests := []struct {
+		item      *core.Datum
+		key       []byte
+		partition int32
+		err       bool
+	}{
+		{&core.Datum{DATA: core.Data{TEXT0: "3"}, RSS: core.Rss{LINK: "a", TITLE: "hello"}}, []byte("a"), 3, false},
+		{&core.Datum{RSS: core.Rss{TITLE: "hello"}}, nil, kafka.PartitionAny, false},
+		{&core.Datum{DATA: core.Data{TEXT0: "-1"}}, nil, kafka.PartitionAny, true},
+		{&core.Datum{DATA: core.Data{TEXT0: "first"}}, nil, kafka.PartitionAny, true},
+	}
+
+	for _, test := range tests {
+		message := kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny}}
+		err := formatMessage(p, test.item, &message)
+
+		if (err != nil) != test.err || (err == nil && (!reflect.DeepEqual(message.Key, test.key) ||
+			message.TopicPartition.Partition != test.partition)) {
+			t.Errorf(`formatMessage(%v) = %q, %d, %v`, test.item.DATA.TEXT0, message.Key,
+				message.TopicPartition.Partition, err)
+		}
+	}
+
+	message := kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny}}
+	if err := formatMessage(p, tests[0].item, &message); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []kafka.Header{{Key: "source", Value: []byte("rss")}, {Key: "title", Value: []byte("hello")}}
+	if !reflect.DeepEqual(message.Headers, want) {
+		t.Errorf(`formatMessage() headers = %v, want %v`, message.Headers, want)
+	}
+}
+
+func TestTrackOffset(t *testing.T) {
+	p := &Plugin{
+		OffsetsFirst: make(map[string]kafka.TopicPartition),
+		OffsetsNext:  make(map[string]kafka.TopicPartition),
+	}
+
+	a, b := "a", "b"
+
+	for _, partition := range []kafka.TopicPartition{
+		{Topic: &a, Partition: 0, Offset: 10},
+		{Topic: &a, Partition: 0, Offset: 11},
+		{Topic: &a, Partition: 1, Offset: 5},
+		{Topic: &b, Partition: 0, Offset: 7},
+		{Topic: &a, Partition: 0, Offset: 12},
+	} {
+		trackOffset(p, partition)
+	}
+
+	offsets := func(m map[string]kafka.TopicPartition) map[string]kafka.Offset {
+		result := make(map[string]kafka.Offset)
+		for k, v := range m {
+			result[k] = v.Offset
+		}
+		return result
+	}
+
+	if got, want := offsets(p.OffsetsFirst), map[string]kafka.Offset{"a:0": 10, "a:1": 5, "b:0": 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf(`trackOffset() first = %v, want %v`, got, want)
+	}
+
+	if got, want := offsets(p.OffsetsNext), map[string]kafka.Offset{"a:0": 13, "a:1": 6, "b:0": 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf(`trackOffset() next = %v, want %v`, got, want)
+	}
+
+	// Offsets are reset after commit.
+	p.OptionPersistent = true
+
+	if err := p.Commit(); err != nil || len(p.OffsetsFirst) != 0 || len(p.OffsetsNext) != 0 {
+		t.Errorf(`Commit() = %v, %v, %v`, err, p.OffsetsFirst, p.OffsetsNext)
+	}
+}
+
+func TestParseSeek(t *testing.T) {
+	tests := []struct {
+		seek   string
+		offset kafka.Offset
+		time   bool
+		err    bool
+	}{
+		{"beginning", kafka.OffsetBeginning, false, false},
+		{"latest", kafka.OffsetEnd, false, false},
+		{"100", 100, false, false},
+		{"2024-01-01T00:00:00Z", 0, true, false},
+		{"1h", 0, true, false},
+		{"-1", 0, false, true},
+		{"yesterday", 0, false, true},
+	}
+
+	for _, test := range tests {
+		offset, tm, err := parseSeek(test.seek)
+		if offset != test.offset || tm.IsZero() == test.time || (err != nil) != test.err {
+			t.Errorf(`parseSeek(%q) = %v, %v, %v`, test.seek, offset, tm, err)
+		}
+	}
+}
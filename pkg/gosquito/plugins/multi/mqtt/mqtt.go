@@ -0,0 +1,885 @@
+package mqttMulti
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	tmpl "text/template"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+)
+
+const (
+	PLUGIN_NAME = "mqtt"
+
+	DEFAULT_BUFFER_SIZE     = 1000
+	DEFAULT_CLEAN_SESSION   = true
+	DEFAULT_QOS             = 0
+	DEFAULT_RETAINED_INPUT  = true
+	DEFAULT_RETAINED_OUTPUT = false
+	DEFAULT_SERVER          = "tcp://127.0.0.1:1883"
+	DEFAULT_SSL_VERIFY      = true
+	DEFAULT_TIMEOUT         = 10
+	DEFAULT_TRIGGER         = false
+)
+
+var (
+	ERROR_BUFFER_FULL      = errors.New("buffer is full: %d")
+	ERROR_CONNECT_ERROR    = errors.New("cannot connect: %v")
+	ERROR_PAYLOAD_INVALID  = errors.New("payload invalid: %v")
+	ERROR_PUBLISH_ERROR    = errors.New("cannot publish: %v")
+	ERROR_QOS_UNKNOWN      = errors.New("qos must be 0, 1 or 2: %d")
+	ERROR_SERVER_UNKNOWN   = errors.New("server scheme unknown: %s")
+	ERROR_SUBSCRIBE_ERROR  = errors.New("cannot subscribe: %v")
+	ERROR_TEMPLATE_INVALID = errors.New("template invalid: %v")
+	ERROR_TIMEOUT          = errors.New("timeout exceeded: %ds")
+)
+
+func convertValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// newClient creates client, input client subscribes to topics on every (re)connect.
+func newClient(p *Plugin) paho.Client {
+	options := paho.NewClientOptions().
+		AddBroker(p.OptionServer).
+		SetAutoReconnect(true).
+		SetCleanSession(p.OptionCleanSession).
+		SetClientID(p.OptionClientId).
+		SetConnectTimeout(time.Duration(p.OptionTimeout) * time.Second).
+		SetPassword(p.OptionPassword).
+		SetTLSConfig(&tls.Config{InsecureSkipVerify: !p.OptionSSLVerify}).
+		SetUsername(p.OptionUsername)
+
+	options.SetConnectionLostHandler(func(c paho.Client, err error) {
+		core.LogInputPlugin(p.clientLogFields, p.OptionServer, fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err))
+	})
+
+	if p.PluginType == "input" {
+		// Keep trying to connect in background, messages are buffered after connect.
+		options.SetConnectRetry(true)
+		options.SetOnConnectHandler(func(c paho.Client) {
+			subscribe(p, c)
+		})
+	}
+
+	return paho.NewClient(options)
+}
+
+func runQuery(query *gojq.Query, payload interface{}) ([]interface{}, error) {
+	temp := make([]interface{}, 0)
+
+	iter := query.Run(payload)
+
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return temp, err
+		}
+
+		temp = append(temp, v)
+	}
+
+	return temp, nil
+}
+
+// subscribe subscribes to topics, every topic filter is a separate source.
+func subscribe(p *Plugin, c paho.Client) {
+	for _, topic := range p.OptionInput {
+		token := c.Subscribe(topic, byte(p.OptionQoS), p.handleMessage(topic))
+
+		if !token.WaitTimeout(time.Duration(p.OptionTimeout) * time.Second) {
+			core.LogInputPlugin(p.clientLogFields, topic,
+				fmt.Errorf(ERROR_SUBSCRIBE_ERROR.Error(), fmt.Errorf(ERROR_TIMEOUT.Error(), p.OptionTimeout)))
+		} else if err := token.Error(); err != nil {
+			core.LogInputPlugin(p.clientLogFields, topic, fmt.Errorf(ERROR_SUBSCRIBE_ERROR.Error(), err))
+		}
+	}
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	Buffer  []*core.Datum
+	Client  paho.Client
+	Pending []*core.Datum
+
+	clientLogFields log.Fields
+
+	OptionBufferSize      int
+	OptionCleanSession    bool
+	OptionClientId        string
+	OptionFields          map[string]string
+	OptionFieldsQuery     map[string]*gojq.Query
+	OptionInput           []string
+	OptionOutput          []string
+	OptionOutputTemplate  []*tmpl.Template
+	OptionPassword        string
+	OptionPayload         string
+	OptionPayloadTemplate *tmpl.Template
+	OptionQoS             int
+	OptionRetained        bool
+	OptionSSLVerify       bool
+	OptionServer          string
+	OptionTimeFormat      string
+	OptionTimeFormatA     string
+	OptionTimeFormatB     string
+	OptionTimeFormatC     string
+	OptionTimeZone        *time.Location
+	OptionTimeZoneA       *time.Location
+	OptionTimeZoneB       *time.Location
+	OptionTimeZoneC       *time.Location
+	OptionTimeout         int
+	OptionTrigger         bool
+	OptionUsername        string
+}
+
+// Commit forgets data of successful flow run.
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.Pending = make([]*core.Datum, 0)
+
+	return nil
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+
+	if err := core.PluginLoadState(p.Flow.FlowStateDir, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	// Take buffered data.
+	p.m.Lock()
+	temp := p.Buffer
+	p.Buffer = make([]*core.Datum, 0)
+	p.Pending = append(p.Pending, temp...)
+	p.m.Unlock()
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+
+	sourceNewStat := make(map[string]int32)
+
+	for _, item := range temp {
+		if item.TIME.After(flowStates[item.SOURCE]) {
+			flowStates[item.SOURCE] = item.TIME
+		}
+		sourceNewStat[item.SOURCE] += 1
+	}
+
+	for _, source := range p.OptionInput {
+		core.LogInputPlugin(p.LogFields, source,
+			fmt.Sprintf("last update: %s, received data: %d", flowStates[source], sourceNewStat[source]))
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	if p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates) {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback returns data of failed flow run into buffer.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.Buffer = append(p.Pending, p.Buffer...)
+	p.Pending = make([]*core.Datum, 0)
+
+	return nil
+}
+
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	sendStatus := true
+
+	// Connect once, client reconnects automatically.
+	if !p.Client.IsConnected() {
+		token := p.Client.Connect()
+
+		if !token.WaitTimeout(time.Duration(p.OptionTimeout) * time.Second) {
+			return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), fmt.Errorf(ERROR_TIMEOUT.Error(), p.OptionTimeout))
+		} else if err := token.Error(); err != nil {
+			return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+		}
+	}
+
+	for _, item := range data {
+		// Whole datum is sent if payload template isn't set.
+		var payload string
+
+		if p.OptionPayloadTemplate != nil {
+			s, err := core.ExtractTemplateIntoString(item, p.OptionPayloadTemplate)
+			if err != nil {
+				return err
+			}
+			payload = s
+
+		} else {
+			m, err := core.PruneDatum(item)
+			if err != nil {
+				return err
+			}
+
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			payload = string(b)
+		}
+
+		for _, t := range p.OptionOutputTemplate {
+			topic, err := core.ExtractTemplateIntoString(item, t)
+			if err != nil {
+				return err
+			}
+
+			token := p.Client.Publish(topic, byte(p.OptionQoS), p.OptionRetained, payload)
+
+			if !token.WaitTimeout(time.Duration(p.OptionTimeout) * time.Second) {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, topic,
+					fmt.Errorf(ERROR_PUBLISH_ERROR.Error(), fmt.Errorf(ERROR_TIMEOUT.Error(), p.OptionTimeout)))
+			} else if err := token.Error(); err != nil {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, topic, fmt.Errorf(ERROR_PUBLISH_ERROR.Error(), err))
+			}
+		}
+	}
+
+	if !sendStatus {
+		return core.ERROR_SEND_FAIL
+	}
+
+	return nil
+}
+
+func (p *Plugin) handleMessage(source string) paho.MessageHandler {
+	return func(c paho.Client, m paho.Message) {
+		// Skip retained (last known) messages if needed.
+		if m.Retained() && !p.OptionRetained {
+			return
+		}
+
+		item, err := p.parseMessage(source, m)
+		if err != nil {
+			core.LogInputPlugin(p.clientLogFields, source, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err))
+			return
+		}
+
+		// Keep data until next receive.
+		p.m.Lock()
+		if len(p.Buffer) >= p.OptionBufferSize {
+			p.m.Unlock()
+			core.LogInputPlugin(p.clientLogFields, source, fmt.Errorf(ERROR_BUFFER_FULL.Error(), p.OptionBufferSize))
+			return
+		}
+		p.Buffer = append(p.Buffer, item)
+		p.m.Unlock()
+
+		// Run flow immediately.
+		if p.OptionTrigger {
+			p.Flow.Trigger()
+		}
+	}
+}
+
+func (p *Plugin) parseMessage(source string, m paho.Message) (*core.Datum, error) {
+	currentTime := time.Now().UTC()
+	u, _ := uuid.NewRandom()
+
+	item := &core.Datum{
+		FLOW:        p.Flow.FlowName,
+		PLUGIN:      p.PluginName,
+		SOURCE:      source,
+		TIME:        currentTime,
+		TIMEFORMAT:  currentTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+		TIMEFORMATA: currentTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+		TIMEFORMATB: currentTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+		TIMEFORMATC: currentTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+		UUID:        u,
+
+		MQTT: core.Mqtt{
+			MESSAGEID: fmt.Sprintf("%d", m.MessageID()),
+			PAYLOAD:   string(m.Payload()),
+			QOS:       fmt.Sprintf("%d", m.Qos()),
+			RETAINED:  fmt.Sprintf("%v", m.Retained()),
+			TOPIC:     m.Topic(),
+		},
+
+		WARNINGS: make([]string, 0),
+	}
+
+	if len(p.OptionFieldsQuery) == 0 {
+		return item, nil
+	}
+
+	// Map JSON payload into datum fields.
+	var payload interface{}
+	if err := json.Unmarshal(m.Payload(), &payload); err != nil {
+		return item, err
+	}
+
+	for field, query := range p.OptionFieldsQuery {
+		values, err := runQuery(query, payload)
+		if err != nil {
+			return item, err
+		}
+
+		rv, _ := core.ReflectDatumField(item, field)
+
+		switch rv.Kind() {
+		case reflect.String:
+			if len(values) > 0 {
+				rv.SetString(convertValue(values[0]))
+			}
+		case reflect.Slice:
+			for _, v := range values {
+				rv.Set(reflect.Append(rv, reflect.ValueOf(convertValue(v))))
+			}
+		}
+	}
+
+	return item, nil
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		Buffer:     make([]*core.Datum, 0),
+		Pending:    make([]*core.Datum, 0),
+	}
+
+	// Client callbacks are called concurrently with flow runs (which update "run" field).
+	plugin.clientLogFields = log.Fields{}
+	for k, v := range plugin.LogFields {
+		plugin.clientLogFields[k] = v
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// "0" - will be set if parameter is set somehow (defaults, template, config etc.).
+	availableParams := map[string]int{
+		"cred":     -1,
+		"template": -1,
+		"timeout":  -1,
+
+		"client_id":  -1,
+		"password":   -1,
+		"qos":        -1,
+		"retained":   -1,
+		"server":     -1,
+		"ssl_verify": -1,
+		"username":   -1,
+	}
+
+	switch pluginConfig.PluginType {
+	case "input":
+		availableParams["buffer_size"] = -1
+		availableParams["clean_session"] = -1
+		availableParams["expire_action"] = -1
+		availableParams["expire_action_delay"] = -1
+		availableParams["expire_action_timeout"] = -1
+		availableParams["expire_interval"] = -1
+		availableParams["fields"] = -1
+		availableParams["input"] = 1
+		availableParams["time_format"] = -1
+		availableParams["time_format_a"] = -1
+		availableParams["time_format_b"] = -1
+		availableParams["time_format_c"] = -1
+		availableParams["time_zone"] = -1
+		availableParams["time_zone_a"] = -1
+		availableParams["time_zone_b"] = -1
+		availableParams["time_zone_c"] = -1
+		availableParams["trigger"] = -1
+
+	case "output":
+		availableParams["output"] = 1
+		availableParams["payload"] = -1
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// password.
+	setPassword := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["password"] = 0
+			plugin.OptionPassword = core.GetCredValue(v, vault)
+		}
+	}
+	setPassword(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.password", cred)))
+	setPassword((*pluginConfig.PluginParams)["password"])
+
+	// username.
+	setUsername := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["username"] = 0
+			plugin.OptionUsername = core.GetCredValue(v, vault)
+		}
+	}
+	setUsername(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.username", cred)))
+	setUsername((*pluginConfig.PluginParams)["username"])
+	core.ShowPluginParam(plugin.LogFields, "username", plugin.OptionUsername)
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	switch pluginConfig.PluginType {
+	case "input":
+		// buffer_size.
+		setBufferSize := func(p interface{}) {
+			if v, b := core.IsInt(p); b {
+				availableParams["buffer_size"] = 0
+				plugin.OptionBufferSize = v
+			}
+		}
+		setBufferSize(DEFAULT_BUFFER_SIZE)
+		setBufferSize(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.buffer_size", template)))
+		setBufferSize((*pluginConfig.PluginParams)["buffer_size"])
+		core.ShowPluginParam(plugin.LogFields, "buffer_size", plugin.OptionBufferSize)
+
+		// clean_session.
+		setCleanSession := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["clean_session"] = 0
+				plugin.OptionCleanSession = v
+			}
+		}
+		setCleanSession(DEFAULT_CLEAN_SESSION)
+		setCleanSession(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.clean_session", template)))
+		setCleanSession((*pluginConfig.PluginParams)["clean_session"])
+		core.ShowPluginParam(plugin.LogFields, "clean_session", plugin.OptionCleanSession)
+
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+		// fields.
+		templateFields, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.fields", template)))
+		configFields, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["fields"])
+
+		mergedFields := make(map[string]string, 0)
+		mergedFieldsQuery := make(map[string]*gojq.Query, 0)
+
+		for k, v := range templateFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range configFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range mergedFields {
+			if _, err := core.ReflectDatumField(&core.Datum{}, k); err != nil {
+				return &Plugin{}, err
+			}
+
+			query, err := gojq.Parse(v)
+			if err != nil {
+				return &Plugin{}, err
+			}
+
+			mergedFieldsQuery[k] = query
+		}
+
+		if len(mergedFields) > 0 {
+			availableParams["fields"] = 0
+		}
+
+		plugin.OptionFields = mergedFields
+		plugin.OptionFieldsQuery = mergedFieldsQuery
+		core.ShowPluginParam(plugin.LogFields, "fields", plugin.OptionFields)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+		// retained.
+		setRetained := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["retained"] = 0
+				plugin.OptionRetained = v
+			}
+		}
+		setRetained(DEFAULT_RETAINED_INPUT)
+		setRetained(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.retained", template)))
+		setRetained((*pluginConfig.PluginParams)["retained"])
+		core.ShowPluginParam(plugin.LogFields, "retained", plugin.OptionRetained)
+
+		// time_format.
+		setTimeFormat := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format"] = 0
+				plugin.OptionTimeFormat = v
+			}
+		}
+		setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+		setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+		core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+		// time_format_a.
+		setTimeFormatA := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_a"] = 0
+				plugin.OptionTimeFormatA = v
+			}
+		}
+		setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+		setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+		// time_format_b.
+		setTimeFormatB := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_b"] = 0
+				plugin.OptionTimeFormatB = v
+			}
+		}
+		setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+		setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+		// time_format_c.
+		setTimeFormatC := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_c"] = 0
+				plugin.OptionTimeFormatC = v
+			}
+		}
+		setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+		setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+		// time_zone.
+		setTimeZone := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone"] = 0
+				plugin.OptionTimeZone = v
+			}
+		}
+		setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+		setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+		// time_zone_a.
+		setTimeZoneA := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_a"] = 0
+				plugin.OptionTimeZoneA = v
+			}
+		}
+		setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+		setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+		// time_zone_b.
+		setTimeZoneB := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_b"] = 0
+				plugin.OptionTimeZoneB = v
+			}
+		}
+		setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+		setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+		// time_zone_c.
+		setTimeZoneC := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_c"] = 0
+				plugin.OptionTimeZoneC = v
+			}
+		}
+		setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+		setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+		// trigger.
+		setTrigger := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["trigger"] = 0
+				plugin.OptionTrigger = v
+			}
+		}
+		setTrigger(DEFAULT_TRIGGER)
+		setTrigger(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.trigger", template)))
+		setTrigger((*pluginConfig.PluginParams)["trigger"])
+		core.ShowPluginParam(plugin.LogFields, "trigger", plugin.OptionTrigger)
+
+	case "output":
+		// Output session isn't needed between runs.
+		plugin.OptionCleanSession = true
+
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
+
+		for _, v := range plugin.OptionOutput {
+			if t, err := tmpl.New("output").Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				plugin.OptionOutputTemplate = append(plugin.OptionOutputTemplate, t)
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		// payload.
+		setPayload := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["payload"] = 0
+				plugin.OptionPayload = v
+			}
+		}
+		setPayload(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.payload", template)))
+		setPayload((*pluginConfig.PluginParams)["payload"])
+		core.ShowPluginParam(plugin.LogFields, "payload", plugin.OptionPayload)
+
+		if plugin.OptionPayload != "" {
+			if t, err := tmpl.New("payload").Funcs(core.TemplateFuncMap).Parse(plugin.OptionPayload); err == nil {
+				plugin.OptionPayloadTemplate = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		// retained.
+		setRetained := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["retained"] = 0
+				plugin.OptionRetained = v
+			}
+		}
+		setRetained(DEFAULT_RETAINED_OUTPUT)
+		setRetained(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.retained", template)))
+		setRetained((*pluginConfig.PluginParams)["retained"])
+		core.ShowPluginParam(plugin.LogFields, "retained", plugin.OptionRetained)
+	}
+
+	// client_id.
+	setClientId := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["client_id"] = 0
+			plugin.OptionClientId = v
+		}
+	}
+	// Unique default identifier, otherwise flow replicas disconnect each other.
+	clientSuffix, _ := uuid.NewRandom()
+	setClientId(fmt.Sprintf("%s-%s-%s", plugin.Flow.FlowName, plugin.PluginType, clientSuffix.String()[:8]))
+	setClientId(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.client_id", template)))
+	setClientId((*pluginConfig.PluginParams)["client_id"])
+	core.ShowPluginParam(plugin.LogFields, "client_id", plugin.OptionClientId)
+
+	// qos.
+	setQoS := func(p interface{}) {
+		if v, b := core.IsInt(p, true); b {
+			availableParams["qos"] = 0
+			plugin.OptionQoS = v
+		}
+	}
+	setQoS(DEFAULT_QOS)
+	setQoS(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.qos", template)))
+	setQoS((*pluginConfig.PluginParams)["qos"])
+	core.ShowPluginParam(plugin.LogFields, "qos", plugin.OptionQoS)
+
+	// server.
+	setServer := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["server"] = 0
+			plugin.OptionServer = v
+		}
+	}
+	setServer(DEFAULT_SERVER)
+	setServer(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.server", template)))
+	setServer((*pluginConfig.PluginParams)["server"])
+	core.ShowPluginParam(plugin.LogFields, "server", plugin.OptionServer)
+
+	// ssl_verify.
+	setSSLVerify := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["ssl_verify"] = 0
+			plugin.OptionSSLVerify = v
+		}
+	}
+	setSSLVerify(DEFAULT_SSL_VERIFY)
+	setSSLVerify(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ssl_verify", template)))
+	setSSLVerify((*pluginConfig.PluginParams)["ssl_verify"])
+	core.ShowPluginParam(plugin.LogFields, "ssl_verify", plugin.OptionSSLVerify)
+
+	// timeout.
+	setTimeout := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["timeout"] = 0
+			plugin.OptionTimeout = v
+		}
+	}
+	setTimeout(DEFAULT_TIMEOUT)
+	setTimeout(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.timeout", template)))
+	setTimeout((*pluginConfig.PluginParams)["timeout"])
+	core.ShowPluginParam(plugin.LogFields, "timeout", plugin.OptionTimeout)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	if plugin.OptionQoS > 2 {
+		return &Plugin{}, fmt.Errorf(ERROR_QOS_UNKNOWN.Error(), plugin.OptionQoS)
+	}
+
+	if u, err := url.Parse(plugin.OptionServer); err != nil || !core.IsValueInSlice(u.Scheme,
+		&[]string{"mqtt", "mqtts", "ssl", "tcp", "tls", "ws", "wss"}) {
+		return &Plugin{}, fmt.Errorf(ERROR_SERVER_UNKNOWN.Error(), plugin.OptionServer)
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// MQTT.
+
+	plugin.Client = newClient(&plugin)
+
+	// Start receiving messages in background, client keeps reconnecting if server isn't available.
+	if plugin.PluginType == "input" {
+		plugin.Client.Connect()
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
@@ -0,0 +1,129 @@
+package mqttMulti
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/spf13/viper"
+)
+
+func newServer(t *testing.T) (*mqtt.Server, string) {
+	server := mqtt.New(&mqtt.Options{InlineClient: true})
+
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	return server, "tcp://" + listener.Address()
+}
+
+func newPlugin(t *testing.T, server string) *Plugin {
+	appConfig := viper.New()
+	appConfig.Set(core.VIPER_DEFAULT_EXPIRE_INTERVAL, "1d")
+	appConfig.Set(core.VIPER_DEFAULT_PLUGIN_TIMEOUT, 10)
+	appConfig.Set(core.VIPER_DEFAULT_TIME_ZONE, "UTC")
+	core.SetStateBackend(appConfig)
+
+	params := map[string]interface{}{
+		"input":  []interface{}{"test/#"},
+		"server": server,
+	}
+
+	p, err := Init(&core.PluginConfig{
+		AppConfig:    appConfig,
+		Flow:         &core.Flow{FlowName: "mqtt", FlowStateDir: t.TempDir()},
+		PluginParams: &params,
+		PluginType:   "input",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Client.Disconnect(0) })
+
+	return p
+}
+
+// waitBuffer waits until client buffers n messages.
+func waitBuffer(t *testing.T, p *Plugin, n int) {
+	for i := 0; i < 100; i++ {
+		p.m.Lock()
+		size := len(p.Buffer)
+		p.m.Unlock()
+
+		if size >= n {
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf(`Plugin.Buffer size != %d`, n)
+}
+
+func TestClientId(t *testing.T) {
+	_, address := newServer(t)
+
+	p1 := newPlugin(t, address)
+	p2 := newPlugin(t, address)
+
+	if p1.OptionClientId == p2.OptionClientId {
+		t.Errorf(`Init() client_id isn't unique: %s`, p1.OptionClientId)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	server, address := newServer(t)
+	p := newPlugin(t, address)
+
+	// Wait for subscription.
+	for i := 0; i < 100 && len(server.Topics.Subscribers("test/a").Subscriptions) == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := server.Publish("test/a", []byte("hello"), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitBuffer(t, p, 1)
+
+	data, err := p.Receive()
+	if err != nil || len(data) != 1 || data[0].MQTT.PAYLOAD != "hello" {
+		t.Fatalf(`Receive() = %v, %v`, data, err)
+	}
+
+	// Data of failed flow run is received again.
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 1 || data[0].MQTT.PAYLOAD != "hello" {
+		t.Errorf(`Receive() after Rollback() = %v, %v`, data, err)
+	}
+
+	if err := p.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 0 {
+		t.Errorf(`Receive() after Commit() = %v, %v`, data, err)
+	}
+}
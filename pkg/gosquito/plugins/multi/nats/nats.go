@@ -0,0 +1,1084 @@
+package natsMulti
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	tmpl "text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	PLUGIN_NAME = "nats"
+
+	DEFAULT_ACK_WAIT    = "5m"
+	DEFAULT_BUFFER_SIZE = 1000
+	DEFAULT_JETSTREAM   = false
+	DEFAULT_SERVER      = "nats://127.0.0.1:4222"
+	DEFAULT_SSL_VERIFY  = true
+	DEFAULT_TIMEOUT     = 10
+	DEFAULT_TRIGGER     = false
+)
+
+var (
+	ERROR_ACK_ERROR        = errors.New("cannot ack message: %v")
+	ERROR_BUFFER_FULL      = errors.New("buffer is full: %d")
+	ERROR_CONNECT_ERROR    = errors.New("cannot connect: %v")
+	ERROR_FETCH_ERROR      = errors.New("cannot fetch messages: %v")
+	ERROR_PAYLOAD_INVALID  = errors.New("payload invalid: %v")
+	ERROR_PUBLISH_ERROR    = errors.New("cannot publish: %v")
+	ERROR_SERVER_UNKNOWN   = errors.New("server scheme unknown: %s")
+	ERROR_SUBSCRIBE_ERROR  = errors.New("cannot subscribe: %v")
+	ERROR_TEMPLATE_INVALID = errors.New("template invalid: %v")
+)
+
+// connect creates connection once, connection is restored automatically.
+func connect(p *Plugin) error {
+	if p.Conn != nil {
+		return nil
+	}
+
+	options := []nats.Option{
+		nats.Name(p.OptionClientName),
+		nats.MaxReconnects(-1),
+		nats.Timeout(time.Duration(p.OptionTimeout) * time.Second),
+		nats.DisconnectErrHandler(func(c *nats.Conn, err error) {
+			if err != nil {
+				core.LogInputPlugin(p.clientLogFields, p.OptionServer, fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err))
+			}
+		}),
+		nats.ErrorHandler(func(c *nats.Conn, s *nats.Subscription, err error) {
+			if s != nil {
+				core.LogInputPlugin(p.clientLogFields, s.Subject, err)
+			} else {
+				core.LogInputPlugin(p.clientLogFields, p.OptionServer, err)
+			}
+		}),
+	}
+
+	// Input keeps trying to connect in background, core subscriptions are sent after connect.
+	if p.PluginType == "input" {
+		options = append(options, nats.RetryOnFailedConnect(true))
+	}
+
+	if p.OptionUsername != "" {
+		options = append(options, nats.UserInfo(p.OptionUsername, p.OptionPassword))
+	}
+
+	if p.OptionToken != "" {
+		options = append(options, nats.Token(p.OptionToken))
+	}
+
+	if strings.Contains(p.OptionServer, "tls://") {
+		options = append(options, nats.Secure(&tls.Config{InsecureSkipVerify: !p.OptionSSLVerify}))
+	}
+
+	conn, err := nats.Connect(p.OptionServer, options...)
+	if err != nil {
+		return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+	}
+
+	if p.OptionJetStream {
+		js, err := conn.JetStream()
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf(ERROR_CONNECT_ERROR.Error(), err)
+		}
+		p.JetStream = js
+	}
+
+	p.Conn = conn
+
+	return nil
+}
+
+func convertValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// getDurable returns consumer name of subject, characters not allowed in names are replaced.
+func getDurable(p *Plugin, subject string) string {
+	r := strings.NewReplacer(".", "_", "*", "-", ">", "--", "/", "_", "\\", "_")
+
+	return fmt.Sprintf("%s-%s", p.OptionDurable, r.Replace(subject))
+}
+
+// getSubscription creates durable pull consumer for subject once.
+func getSubscription(p *Plugin, subject string) (*nats.Subscription, error) {
+	if s, ok := p.Subscriptions[subject]; ok {
+		return s, nil
+	}
+
+	// Every subject has its own consumer, name doesn't depend on subjects' order.
+	durable := getDurable(p, subject)
+
+	options := []nats.SubOpt{
+		nats.AckExplicit(),
+		nats.AckWait(time.Duration(p.OptionAckWait) * time.Millisecond),
+	}
+
+	if p.OptionStream != "" {
+		options = append(options, nats.BindStream(p.OptionStream))
+	}
+
+	s, err := p.JetStream.PullSubscribe(subject, durable, options...)
+	if err != nil {
+		return nil, fmt.Errorf(ERROR_SUBSCRIBE_ERROR.Error(), err)
+	}
+
+	p.Subscriptions[subject] = s
+
+	return s, nil
+}
+
+func runQuery(query *gojq.Query, payload interface{}) ([]interface{}, error) {
+	temp := make([]interface{}, 0)
+
+	iter := query.Run(payload)
+
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return temp, err
+		}
+
+		temp = append(temp, v)
+	}
+
+	return temp, nil
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	Buffer        []*core.Datum
+	Conn          *nats.Conn
+	JetStream     nats.JetStreamContext
+	Pending       []*nats.Msg
+	PendingData   []*core.Datum
+	Subscriptions map[string]*nats.Subscription
+
+	clientLogFields log.Fields
+
+	OptionAckWait         int64
+	OptionBufferSize      int
+	OptionClientName      string
+	OptionDurable         string
+	OptionFields          map[string]string
+	OptionFieldsQuery     map[string]*gojq.Query
+	OptionHeaders         map[string]string
+	OptionHeadersTemplate map[string]*tmpl.Template
+	OptionInput           []string
+	OptionJetStream       bool
+	OptionOutput          []string
+	OptionOutputTemplate  []*tmpl.Template
+	OptionPassword        string
+	OptionPayload         string
+	OptionPayloadTemplate *tmpl.Template
+	OptionQueue           string
+	OptionSSLVerify       bool
+	OptionServer          string
+	OptionStream          string
+	OptionTimeFormat      string
+	OptionTimeFormatA     string
+	OptionTimeFormatB     string
+	OptionTimeFormatC     string
+	OptionTimeZone        *time.Location
+	OptionTimeZoneA       *time.Location
+	OptionTimeZoneB       *time.Location
+	OptionTimeZoneC       *time.Location
+	OptionTimeout         int
+	OptionToken           string
+	OptionTrigger         bool
+	OptionUsername        string
+}
+
+// Commit acknowledges JetStream messages (forgets core data) after successful flow run.
+func (p *Plugin) Commit() error {
+	var err error
+
+	p.m.Lock()
+	p.PendingData = make([]*core.Datum, 0)
+	p.m.Unlock()
+
+	for _, m := range p.Pending {
+		if e := m.AckSync(nats.AckWait(time.Duration(p.OptionTimeout) * time.Second)); e != nil {
+			err = fmt.Errorf(ERROR_ACK_ERROR.Error(), e)
+			core.LogInputPlugin(p.LogFields, m.Subject, err)
+		}
+	}
+
+	p.Pending = make([]*nats.Msg, 0)
+
+	return err
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+
+	if err := core.PluginLoadState(p.Flow.FlowStateDir, &data); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	temp := make([]*core.Datum, 0)
+
+	if p.OptionJetStream {
+		// Fetch pending messages, messages are acknowledged after flow run.
+		for _, subject := range p.OptionInput {
+			s, err := getSubscription(p, subject)
+			if err != nil {
+				return temp, err
+			}
+
+			messages, err := s.Fetch(p.OptionBufferSize, nats.MaxWait(time.Duration(p.OptionTimeout)*time.Second))
+			if err != nil && !errors.Is(err, nats.ErrTimeout) {
+				return temp, fmt.Errorf(ERROR_FETCH_ERROR.Error(), err)
+			}
+
+			for _, m := range messages {
+				// Invalid messages are never redelivered.
+				item, err := p.parseMessage(subject, m)
+				if err != nil {
+					core.LogInputPlugin(p.LogFields, subject, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err))
+
+					if err := m.Term(); err != nil {
+						core.LogInputPlugin(p.LogFields, subject, fmt.Errorf(ERROR_ACK_ERROR.Error(), err))
+					}
+
+					continue
+				}
+
+				p.Pending = append(p.Pending, m)
+				temp = append(temp, item)
+			}
+		}
+
+	} else {
+		// Take buffered data.
+		p.m.Lock()
+		temp = p.Buffer
+		p.Buffer = make([]*core.Datum, 0)
+		p.PendingData = append(p.PendingData, temp...)
+		p.m.Unlock()
+	}
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+
+	sourceNewStat := make(map[string]int32)
+
+	for _, item := range temp {
+		if item.TIME.After(flowStates[item.SOURCE]) {
+			flowStates[item.SOURCE] = item.TIME
+		}
+		sourceNewStat[item.SOURCE] += 1
+	}
+
+	for _, source := range p.OptionInput {
+		core.LogInputPlugin(p.LogFields, source,
+			fmt.Sprintf("last update: %s, received data: %d", flowStates[source], sourceNewStat[source]))
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	if p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates) {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+// Rollback returns JetStream messages for redelivery (core data into buffer) after failed flow run.
+func (p *Plugin) Rollback() error {
+	var err error
+
+	p.m.Lock()
+	p.Buffer = append(p.PendingData, p.Buffer...)
+	p.PendingData = make([]*core.Datum, 0)
+	p.m.Unlock()
+
+	for _, m := range p.Pending {
+		if e := m.Nak(); e != nil {
+			err = fmt.Errorf(ERROR_ACK_ERROR.Error(), e)
+			core.LogInputPlugin(p.LogFields, m.Subject, err)
+		}
+	}
+
+	p.Pending = make([]*nats.Msg, 0)
+
+	return err
+}
+
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	sendStatus := true
+
+	if err := connect(p); err != nil {
+		return err
+	}
+
+	for _, item := range data {
+		// Whole datum is sent if payload template isn't set.
+		var payload string
+
+		if p.OptionPayloadTemplate != nil {
+			s, err := core.ExtractTemplateIntoString(item, p.OptionPayloadTemplate)
+			if err != nil {
+				return err
+			}
+			payload = s
+
+		} else {
+			m, err := core.PruneDatum(item)
+			if err != nil {
+				return err
+			}
+
+			b, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+			payload = string(b)
+		}
+
+		headers, err := core.ExtractTemplateMapIntoStringMap(item, p.OptionHeadersTemplate)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range p.OptionOutputTemplate {
+			subject, err := core.ExtractTemplateIntoString(item, t)
+			if err != nil {
+				return err
+			}
+
+			message := nats.NewMsg(subject)
+			message.Data = []byte(payload)
+
+			for k, v := range headers {
+				message.Header.Set(k, v)
+			}
+
+			// JetStream confirms every message.
+			if p.OptionJetStream {
+				_, err = p.JetStream.PublishMsg(message, nats.AckWait(time.Duration(p.OptionTimeout)*time.Second))
+			} else {
+				err = p.Conn.PublishMsg(message)
+			}
+
+			if err != nil {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, subject, fmt.Errorf(ERROR_PUBLISH_ERROR.Error(), err))
+			}
+		}
+	}
+
+	// Core messages are confirmed by flushing connection.
+	if !p.OptionJetStream {
+		if err := p.Conn.FlushTimeout(time.Duration(p.OptionTimeout) * time.Second); err != nil {
+			sendStatus = false
+			core.LogOutputPlugin(p.LogFields, p.OptionServer, fmt.Errorf(ERROR_PUBLISH_ERROR.Error(), err))
+		}
+	}
+
+	if !sendStatus {
+		return core.ERROR_SEND_FAIL
+	}
+
+	return nil
+}
+
+func (p *Plugin) handleMessage(source string) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		item, err := p.parseMessage(source, m)
+		if err != nil {
+			core.LogInputPlugin(p.clientLogFields, source, fmt.Errorf(ERROR_PAYLOAD_INVALID.Error(), err))
+			return
+		}
+
+		// Keep data until next receive.
+		p.m.Lock()
+		if len(p.Buffer) >= p.OptionBufferSize {
+			p.m.Unlock()
+			core.LogInputPlugin(p.clientLogFields, source, fmt.Errorf(ERROR_BUFFER_FULL.Error(), p.OptionBufferSize))
+			return
+		}
+		p.Buffer = append(p.Buffer, item)
+		p.m.Unlock()
+
+		// Run flow immediately.
+		if p.OptionTrigger {
+			p.Flow.Trigger()
+		}
+	}
+}
+
+func (p *Plugin) parseMessage(source string, m *nats.Msg) (*core.Datum, error) {
+	currentTime := time.Now().UTC()
+	u, _ := uuid.NewRandom()
+
+	// Message headers.
+	messageHeaders := make([]string, 0)
+	for k, values := range m.Header {
+		for _, v := range values {
+			messageHeaders = append(messageHeaders, fmt.Sprintf("%s: %s", k, v))
+		}
+	}
+	sort.Strings(messageHeaders)
+
+	// JetStream metadata.
+	var sequence, stream string
+
+	if meta, err := m.Metadata(); err == nil {
+		currentTime = meta.Timestamp.UTC()
+		sequence = fmt.Sprintf("%d", meta.Sequence.Stream)
+		stream = meta.Stream
+	}
+
+	item := &core.Datum{
+		FLOW:        p.Flow.FlowName,
+		PLUGIN:      p.PluginName,
+		SOURCE:      source,
+		TIME:        currentTime,
+		TIMEFORMAT:  currentTime.In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+		TIMEFORMATA: currentTime.In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+		TIMEFORMATB: currentTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+		TIMEFORMATC: currentTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+		UUID:        u,
+
+		NATS: core.Nats{
+			HEADERS:  messageHeaders,
+			PAYLOAD:  string(m.Data),
+			REPLY:    m.Reply,
+			SEQUENCE: sequence,
+			STREAM:   stream,
+			SUBJECT:  m.Subject,
+		},
+
+		WARNINGS: make([]string, 0),
+	}
+
+	if len(p.OptionFieldsQuery) == 0 {
+		return item, nil
+	}
+
+	// Map JSON payload into datum fields.
+	var payload interface{}
+	if err := json.Unmarshal(m.Data, &payload); err != nil {
+		return item, err
+	}
+
+	for field, query := range p.OptionFieldsQuery {
+		values, err := runQuery(query, payload)
+		if err != nil {
+			return item, err
+		}
+
+		rv, _ := core.ReflectDatumField(item, field)
+
+		switch rv.Kind() {
+		case reflect.String:
+			if len(values) > 0 {
+				rv.SetString(convertValue(values[0]))
+			}
+		case reflect.Slice:
+			for _, v := range values {
+				rv.Set(reflect.Append(rv, reflect.ValueOf(convertValue(v))))
+			}
+		}
+	}
+
+	return item, nil
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName:    PLUGIN_NAME,
+		PluginType:    pluginConfig.PluginType,
+		Buffer:        make([]*core.Datum, 0),
+		Pending:       make([]*nats.Msg, 0),
+		PendingData:   make([]*core.Datum, 0),
+		Subscriptions: make(map[string]*nats.Subscription, 0),
+	}
+
+	// Client callbacks are called concurrently with flow runs (which update "run" field).
+	plugin.clientLogFields = log.Fields{}
+	for k, v := range plugin.LogFields {
+		plugin.clientLogFields[k] = v
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// "0" - will be set if parameter is set somehow (defaults, template, config etc.).
+	availableParams := map[string]int{
+		"cred":     -1,
+		"template": -1,
+		"timeout":  -1,
+
+		"client_name": -1,
+		"jetstream":   -1,
+		"password":    -1,
+		"server":      -1,
+		"ssl_verify":  -1,
+		"token":       -1,
+		"username":    -1,
+	}
+
+	switch pluginConfig.PluginType {
+	case "input":
+		availableParams["ack_wait"] = -1
+		availableParams["buffer_size"] = -1
+		availableParams["durable"] = -1
+		availableParams["expire_action"] = -1
+		availableParams["expire_action_delay"] = -1
+		availableParams["expire_action_timeout"] = -1
+		availableParams["expire_interval"] = -1
+		availableParams["fields"] = -1
+		availableParams["input"] = 1
+		availableParams["queue"] = -1
+		availableParams["stream"] = -1
+		availableParams["time_format"] = -1
+		availableParams["time_format_a"] = -1
+		availableParams["time_format_b"] = -1
+		availableParams["time_format_c"] = -1
+		availableParams["time_zone"] = -1
+		availableParams["time_zone_a"] = -1
+		availableParams["time_zone_b"] = -1
+		availableParams["time_zone_c"] = -1
+		availableParams["trigger"] = -1
+
+	case "output":
+		availableParams["headers"] = -1
+		availableParams["output"] = 1
+		availableParams["payload"] = -1
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// password.
+	setPassword := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["password"] = 0
+			plugin.OptionPassword = core.GetCredValue(v, vault)
+		}
+	}
+	setPassword(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.password", cred)))
+	setPassword((*pluginConfig.PluginParams)["password"])
+
+	// token.
+	setToken := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["token"] = 0
+			plugin.OptionToken = core.GetCredValue(v, vault)
+		}
+	}
+	setToken(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.token", cred)))
+	setToken((*pluginConfig.PluginParams)["token"])
+
+	// username.
+	setUsername := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["username"] = 0
+			plugin.OptionUsername = core.GetCredValue(v, vault)
+		}
+	}
+	setUsername(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.username", cred)))
+	setUsername((*pluginConfig.PluginParams)["username"])
+	core.ShowPluginParam(plugin.LogFields, "username", plugin.OptionUsername)
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	switch pluginConfig.PluginType {
+	case "input":
+		// ack_wait.
+		setAckWait := func(p interface{}) {
+			if v, b := core.IsInterval(p); b {
+				availableParams["ack_wait"] = 0
+				plugin.OptionAckWait = v
+			}
+		}
+		setAckWait(DEFAULT_ACK_WAIT)
+		setAckWait(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ack_wait", template)))
+		setAckWait((*pluginConfig.PluginParams)["ack_wait"])
+		core.ShowPluginParam(plugin.LogFields, "ack_wait", plugin.OptionAckWait)
+
+		// buffer_size.
+		setBufferSize := func(p interface{}) {
+			if v, b := core.IsInt(p); b {
+				availableParams["buffer_size"] = 0
+				plugin.OptionBufferSize = v
+			}
+		}
+		setBufferSize(DEFAULT_BUFFER_SIZE)
+		setBufferSize(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.buffer_size", template)))
+		setBufferSize((*pluginConfig.PluginParams)["buffer_size"])
+		core.ShowPluginParam(plugin.LogFields, "buffer_size", plugin.OptionBufferSize)
+
+		// durable.
+		setDurable := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["durable"] = 0
+				plugin.OptionDurable = v
+			}
+		}
+		setDurable(plugin.Flow.FlowName)
+		setDurable(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.durable", template)))
+		setDurable((*pluginConfig.PluginParams)["durable"])
+		core.ShowPluginParam(plugin.LogFields, "durable", plugin.OptionDurable)
+
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+		// fields.
+		templateFields, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.fields", template)))
+		configFields, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["fields"])
+
+		mergedFields := make(map[string]string, 0)
+		mergedFieldsQuery := make(map[string]*gojq.Query, 0)
+
+		for k, v := range templateFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range configFields {
+			mergedFields[k] = fmt.Sprintf("%s", v)
+		}
+
+		for k, v := range mergedFields {
+			if _, err := core.ReflectDatumField(&core.Datum{}, k); err != nil {
+				return &Plugin{}, err
+			}
+
+			query, err := gojq.Parse(v)
+			if err != nil {
+				return &Plugin{}, err
+			}
+
+			mergedFieldsQuery[k] = query
+		}
+
+		if len(mergedFields) > 0 {
+			availableParams["fields"] = 0
+		}
+
+		plugin.OptionFields = mergedFields
+		plugin.OptionFieldsQuery = mergedFieldsQuery
+		core.ShowPluginParam(plugin.LogFields, "fields", plugin.OptionFields)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+		// queue.
+		setQueue := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["queue"] = 0
+				plugin.OptionQueue = v
+			}
+		}
+		setQueue(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.queue", template)))
+		setQueue((*pluginConfig.PluginParams)["queue"])
+		core.ShowPluginParam(plugin.LogFields, "queue", plugin.OptionQueue)
+
+		// stream.
+		setStream := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["stream"] = 0
+				plugin.OptionStream = v
+			}
+		}
+		setStream(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.stream", template)))
+		setStream((*pluginConfig.PluginParams)["stream"])
+		core.ShowPluginParam(plugin.LogFields, "stream", plugin.OptionStream)
+
+		// time_format.
+		setTimeFormat := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format"] = 0
+				plugin.OptionTimeFormat = v
+			}
+		}
+		setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+		setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+		core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+		// time_format_a.
+		setTimeFormatA := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_a"] = 0
+				plugin.OptionTimeFormatA = v
+			}
+		}
+		setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+		setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+		// time_format_b.
+		setTimeFormatB := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_b"] = 0
+				plugin.OptionTimeFormatB = v
+			}
+		}
+		setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+		setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+		// time_format_c.
+		setTimeFormatC := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_c"] = 0
+				plugin.OptionTimeFormatC = v
+			}
+		}
+		setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+		setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+		// time_zone.
+		setTimeZone := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone"] = 0
+				plugin.OptionTimeZone = v
+			}
+		}
+		setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+		setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+		// time_zone_a.
+		setTimeZoneA := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_a"] = 0
+				plugin.OptionTimeZoneA = v
+			}
+		}
+		setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+		setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+		// time_zone_b.
+		setTimeZoneB := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_b"] = 0
+				plugin.OptionTimeZoneB = v
+			}
+		}
+		setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+		setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+		// time_zone_c.
+		setTimeZoneC := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_c"] = 0
+				plugin.OptionTimeZoneC = v
+			}
+		}
+		setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+		setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+		// trigger.
+		setTrigger := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["trigger"] = 0
+				plugin.OptionTrigger = v
+			}
+		}
+		setTrigger(DEFAULT_TRIGGER)
+		setTrigger(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.trigger", template)))
+		setTrigger((*pluginConfig.PluginParams)["trigger"])
+		core.ShowPluginParam(plugin.LogFields, "trigger", plugin.OptionTrigger)
+
+	case "output":
+		// headers.
+		templateHeaders, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.headers", template)))
+		configHeaders, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["headers"])
+		mergedHeaders := make(map[string]string, 0)
+		mergedHeadersTemplate := make(map[string]*tmpl.Template, 0)
+
+		for k, v := range templateHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range configHeaders {
+			mergedHeaders[k] = fmt.Sprintf("%v", v)
+		}
+
+		for k, v := range mergedHeaders {
+			if t, err := tmpl.New(k).Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				mergedHeadersTemplate[k] = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		plugin.OptionHeaders = mergedHeaders
+		plugin.OptionHeadersTemplate = mergedHeadersTemplate
+		core.ShowPluginParam(plugin.LogFields, "headers", plugin.OptionHeaders)
+
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
+
+		for _, v := range plugin.OptionOutput {
+			if t, err := tmpl.New("output").Funcs(core.TemplateFuncMap).Parse(v); err == nil {
+				plugin.OptionOutputTemplate = append(plugin.OptionOutputTemplate, t)
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+
+		// payload.
+		setPayload := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["payload"] = 0
+				plugin.OptionPayload = v
+			}
+		}
+		setPayload(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.payload", template)))
+		setPayload((*pluginConfig.PluginParams)["payload"])
+		core.ShowPluginParam(plugin.LogFields, "payload", plugin.OptionPayload)
+
+		if plugin.OptionPayload != "" {
+			if t, err := tmpl.New("payload").Funcs(core.TemplateFuncMap).Parse(plugin.OptionPayload); err == nil {
+				plugin.OptionPayloadTemplate = t
+			} else {
+				return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+			}
+		}
+	}
+
+	// client_name.
+	setClientName := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["client_name"] = 0
+			plugin.OptionClientName = v
+		}
+	}
+	setClientName(fmt.Sprintf("%s-%s", plugin.Flow.FlowName, plugin.PluginType))
+	setClientName(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.client_name", template)))
+	setClientName((*pluginConfig.PluginParams)["client_name"])
+	core.ShowPluginParam(plugin.LogFields, "client_name", plugin.OptionClientName)
+
+	// jetstream.
+	setJetStream := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["jetstream"] = 0
+			plugin.OptionJetStream = v
+		}
+	}
+	setJetStream(DEFAULT_JETSTREAM)
+	setJetStream(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.jetstream", template)))
+	setJetStream((*pluginConfig.PluginParams)["jetstream"])
+	core.ShowPluginParam(plugin.LogFields, "jetstream", plugin.OptionJetStream)
+
+	// server.
+	setServer := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["server"] = 0
+			plugin.OptionServer = v
+		}
+	}
+	setServer(DEFAULT_SERVER)
+	setServer(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.server", template)))
+	setServer((*pluginConfig.PluginParams)["server"])
+	core.ShowPluginParam(plugin.LogFields, "server", plugin.OptionServer)
+
+	// ssl_verify.
+	setSSLVerify := func(p interface{}) {
+		if v, b := core.IsBool(p); b {
+			availableParams["ssl_verify"] = 0
+			plugin.OptionSSLVerify = v
+		}
+	}
+	setSSLVerify(DEFAULT_SSL_VERIFY)
+	setSSLVerify(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.ssl_verify", template)))
+	setSSLVerify((*pluginConfig.PluginParams)["ssl_verify"])
+	core.ShowPluginParam(plugin.LogFields, "ssl_verify", plugin.OptionSSLVerify)
+
+	// timeout.
+	setTimeout := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["timeout"] = 0
+			plugin.OptionTimeout = v
+		}
+	}
+	setTimeout(DEFAULT_TIMEOUT)
+	setTimeout(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.timeout", template)))
+	setTimeout((*pluginConfig.PluginParams)["timeout"])
+	core.ShowPluginParam(plugin.LogFields, "timeout", plugin.OptionTimeout)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	// Several servers might be set as comma separated list.
+	for _, server := range strings.Split(plugin.OptionServer, ",") {
+		if u, err := url.Parse(strings.TrimSpace(server)); err != nil || !core.IsValueInSlice(u.Scheme,
+			&[]string{"nats", "tls", "ws", "wss"}) {
+			return &Plugin{}, fmt.Errorf(ERROR_SERVER_UNKNOWN.Error(), server)
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// NATS.
+
+	// Start receiving core messages in background, JetStream consumers are created on first receive.
+	if plugin.PluginType == "input" {
+		if err := connect(&plugin); err != nil {
+			return &Plugin{}, err
+		}
+
+		if !plugin.OptionJetStream {
+			for _, subject := range plugin.OptionInput {
+				if _, err := plugin.Conn.QueueSubscribe(subject, plugin.OptionQueue, plugin.handleMessage(subject)); err != nil {
+					return &Plugin{}, fmt.Errorf(ERROR_SUBSCRIBE_ERROR.Error(), err)
+				}
+			}
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
@@ -0,0 +1,190 @@
+package natsMulti
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
+)
+
+func newServer(t *testing.T) *server.Server {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("server isn't ready")
+	}
+
+	return s
+}
+
+func newPlugin(t *testing.T, params map[string]interface{}) *Plugin {
+	appConfig := viper.New()
+	appConfig.Set(core.VIPER_DEFAULT_EXPIRE_INTERVAL, "1d")
+	appConfig.Set(core.VIPER_DEFAULT_TIME_ZONE, "UTC")
+	core.SetStateBackend(appConfig)
+
+	p, err := Init(&core.PluginConfig{
+		AppConfig:    appConfig,
+		Flow:         &core.Flow{FlowName: "nats", FlowStateDir: t.TempDir()},
+		PluginParams: &params,
+		PluginType:   "input",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Conn.Close)
+
+	return p
+}
+
+func publish(t *testing.T, s *server.Server, subject string, payloads ...string) {
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, payload := range payloads {
+		if err := conn.Publish(subject, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoreRollback(t *testing.T) {
+	s := newServer(t)
+	p := newPlugin(t, map[string]interface{}{
+		"input":  []interface{}{"news.>"},
+		"server": s.ClientURL(),
+	})
+
+	if err := p.Conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, s, "news.a", "hello")
+
+	for i := 0; i < 100; i++ {
+		p.m.Lock()
+		size := len(p.Buffer)
+		p.m.Unlock()
+
+		if size > 0 {
+			break
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	data, err := p.Receive()
+	if err != nil || len(data) != 1 || data[0].NATS.PAYLOAD != "hello" {
+		t.Fatalf(`Receive() = %v, %v`, data, err)
+	}
+
+	// Data of failed flow run is received again.
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 1 || data[0].NATS.PAYLOAD != "hello" {
+		t.Errorf(`Receive() after Rollback() = %v, %v`, data, err)
+	}
+
+	if err := p.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 0 {
+		t.Errorf(`Receive() after Commit() = %v, %v`, data, err)
+	}
+}
+
+func TestGetDurable(t *testing.T) {
+	p := &Plugin{OptionDurable: "news"}
+
+	tests := map[string]string{
+		"orders":       "news-orders",
+		"orders.*":     "news-orders_-",
+		"orders.>":     "news-orders_--",
+		"orders.*.new": "news-orders_-_new",
+	}
+
+	for subject, durable := range tests {
+		if v := getDurable(p, subject); v != durable {
+			t.Errorf(`getDurable(%q) = %q, want %q`, subject, v, durable)
+		}
+	}
+}
+
+func TestJetStream(t *testing.T) {
+	s := newServer(t)
+	p := newPlugin(t, map[string]interface{}{
+		"fields":    map[string]interface{}{"data.text0": ".title"},
+		"input":     []interface{}{"news.a", "news.b"},
+		"jetstream": true,
+		"server":    s.ClientURL(),
+		"timeout":   1,
+	})
+
+	if _, err := p.JetStream.AddStream(&nats.StreamConfig{Name: "news", Subjects: []string{"news.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, s, "news.a", `{"title": "hello"}`, "invalid")
+	publish(t, s, "news.b", `{"title": "world"}`)
+
+	data, err := p.Receive()
+	if err != nil || len(data) != 2 {
+		t.Fatalf(`Receive() = %v, %v`, data, err)
+	}
+
+	// Every subject has its own consumer.
+	for _, durable := range []string{"nats-news_a", "nats-news_b"} {
+		if _, err := p.JetStream.ConsumerInfo("news", durable); err != nil {
+			t.Errorf(`ConsumerInfo(%q) = %v`, durable, err)
+		}
+	}
+
+	// Invalid message is terminated, only valid message waits for acknowledgement.
+	if info, err := p.JetStream.ConsumerInfo("news", "nats-news_a"); err != nil || info.NumAckPending != 1 {
+		t.Errorf(`ConsumerInfo() = %v, %v`, info, err)
+	}
+
+	// Valid messages are redelivered.
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 2 {
+		t.Fatalf(`Receive() after Rollback() = %v, %v`, data, err)
+	}
+
+	if err := p.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = p.Receive()
+	if err != nil || len(data) != 0 {
+		t.Errorf(`Receive() after Commit() = %v, %v`, data, err)
+	}
+}
@@ -59,6 +59,11 @@ func restyClient(p *Plugin) *resty.Client {
 	// Set timeout.
 	client.SetTimeout(time.Duration(p.OptionTimeout) * time.Second)
 
+	// Respect rate limits for remote host.
+	client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		return p.Flow.RateLimitWait(r.Context(), r.URL)
+	})
+
 	// Set user_agent.
 	client.SetHeader("User-Agent", p.OptionUserAgent)
 
@@ -70,6 +75,8 @@ type Plugin struct {
 
 	Flow *core.Flow
 
+	Expire *core.ExpireCheck
+
 	LogFields log.Fields
 
 	RestyClient *resty.Client
@@ -83,11 +90,6 @@ type Plugin struct {
 	OptionBearerToken         string
 	OptionBody                string
 	OptionBodyTemplate        *tmpl.Template
-	OptionExpireAction        []string
-	OptionExpireActionDelay   int64
-	OptionExpireActionTimeout int
-	OptionExpireInterval      int64
-	OptionExpireLast          int64
 	OptionHeaders             map[string]string
 	OptionHeadersTemplate     map[string]*tmpl.Template
 	OptionInclude             bool
@@ -282,6 +284,13 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 
 		var resp *resty.Response
 
+		// Skip sources with backoff/quarantine.
+		if !p.Flow.GetHealth().Allow(source) {
+			core.LogInputPlugin(p.LogFields, source,
+				fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+			continue
+		}
+
 		// Check if we work with source first time.
 		if v, ok := flowStates[source]; ok {
 			sourceLastTime = v
@@ -300,6 +309,8 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 			TIMEFORMATB: itemTime.In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
 			TIMEFORMATC: itemTime.In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
 			UUID:        u,
+
+			VARS: p.Flow.FlowVars,
 		}
 
 		// Format body.
@@ -333,6 +344,8 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 		}
 
 		if err == nil && !(resp.StatusCode() < 200 || resp.StatusCode() >= 300) {
+			p.Flow.GetHealth().Success(source)
+
 			itemBody := fmt.Sprintf("%s", resp.Body())
 
 			// Process only new items. Two methods:
@@ -399,7 +412,12 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 				fmt.Sprintf("last update: %s, received data: %d, new data: %v", sourceLastTime, 1, itemNew))
 
 		} else {
+			if err == nil {
+				err = fmt.Errorf("http error: %s", resp.Status())
+			}
+
 			failedSources = append(failedSources, source)
+			p.Flow.GetHealth().Fail(source, err)
 			core.LogInputPlugin(p.LogFields, source, fmt.Errorf("%s %v", p.OptionMethod, err))
 			continue
 		}
@@ -411,39 +429,7 @@ func (p *Plugin) Receive() ([]*core.Datum, error) {
 	}
 
 	// Check every source for expiration.
-	sourcesExpired := false
-
-    // Check if any source is expired.
-    for _, source := range p.OptionInput {
-        sourceTime := flowStates[source]
-		
-        if (currentTime.Unix() - sourceTime.Unix()) > p.OptionExpireInterval / 1000 {
-			sourcesExpired = true
-            
-            core.LogInputPlugin(p.LogFields, source, 
-                fmt.Sprintf("source expired: %v", currentTime.Sub(sourceTime)))
-
-			// Execute command if expire delay exceeded.
-			// ExpireLast keeps last execution timestamp.
-			if (currentTime.Unix() - p.OptionExpireLast) > p.OptionExpireActionDelay / 1000 {
-				p.OptionExpireLast = currentTime.Unix()
-
-				// Execute command with args.
-				// We don't worry about command return code.
-				if len(p.OptionExpireAction) > 0 {
-					cmd := p.OptionExpireAction[0]
-					args := []string{p.Flow.FlowName, source, fmt.Sprintf("%v", sourceTime.Unix())}
-					args = append(args, p.OptionExpireAction[1:]...)
-
-					output, err := core.ExecWithTimeout(cmd, args, p.OptionExpireActionTimeout)
-
-					core.LogInputPlugin(p.LogFields, source, fmt.Sprintf(
-						"source expired action: command: %s, arguments: %v, output: %s, error: %v",
-						cmd, args, output, err))
-				}
-			}
-		}
-    }
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
 
 	// Inform about sources failures.
 	if len(failedSources) > 0 {
@@ -644,53 +630,8 @@ func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
 	switch pluginConfig.PluginType {
 
 	case "input":
-		// expire_action.
-		setExpireAction := func(p interface{}) {
-			if v, b := core.IsSliceOfString(p); b {
-				availableParams["expire_action"] = 0
-				plugin.OptionExpireAction = v
-			}
-		}
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(core.VIPER_DEFAULT_EXPIRE_ACTION))
-		setExpireAction(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.expire_action", template)))
-		setExpireAction((*pluginConfig.PluginParams)["expire_action"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action", plugin.OptionExpireAction)
-
-		// expire_action_delay.
-		setExpireActionDelay := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_action_delay"] = 0
-				plugin.OptionExpireActionDelay = v
-			}
-		}
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_ACTION_DELAY))
-		setExpireActionDelay(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_delay", template)))
-		setExpireActionDelay((*pluginConfig.PluginParams)["expire_action_delay"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_delay", plugin.OptionExpireActionDelay)
-
-		// expire_action_timeout.
-		setExpireActionTimeout := func(p interface{}) {
-			if v, b := core.IsInt(p); b {
-				availableParams["expire_action_timeout"] = 0
-				plugin.OptionExpireActionTimeout = v
-			}
-		}
-		setExpireActionTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_EXPIRE_ACTION_TIMEOUT))
-		setExpireActionTimeout(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_action_timeout", template)))
-		setExpireActionTimeout((*pluginConfig.PluginParams)["expire_action_timeout"])
-		core.ShowPluginParam(plugin.LogFields, "expire_action_timeout", plugin.OptionExpireActionTimeout)
-
-		// expire_interval.
-		setExpireInterval := func(p interface{}) {
-			if v, b := core.IsInterval(p); b {
-				availableParams["expire_interval"] = 0
-				plugin.OptionExpireInterval = v
-			}
-		}
-		setExpireInterval(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_EXPIRE_INTERVAL))
-		setExpireInterval(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.expire_interval", template)))
-		setExpireInterval((*pluginConfig.PluginParams)["expire_interval"])
-		core.ShowPluginParam(plugin.LogFields, "expire_interval", plugin.OptionExpireInterval)
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+	plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
 
 		// input.
 		setInput := func(p interface{}) {
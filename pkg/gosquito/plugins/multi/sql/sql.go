@@ -0,0 +1,1093 @@
+package sqlMulti
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	tmpl "text/template"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	PLUGIN_NAME = "sql"
+
+	DEFAULT_BATCH        = 0
+	DEFAULT_CREATE_TABLE = false
+	DEFAULT_CURSOR_START = "0"
+	DEFAULT_STATE_PREFIX = "cursor:"
+	DEFAULT_TIME_FORMAT  = "2006-01-02 15:04:05.999999999-07:00"
+)
+
+var (
+	ERROR_CREATE_TABLE     = errors.New("table creation is supported by sqlite only: %s")
+	ERROR_CURSOR_NOT_FOUND = errors.New("cursor column not found: %s")
+	ERROR_DSN_UNKNOWN      = errors.New("dsn scheme unknown: %s")
+	ERROR_FIELDS_EMPTY     = errors.New("fields are not set")
+	ERROR_TEMPLATE_INVALID = errors.New("template invalid: %v")
+)
+
+// buildStatement generates INSERT (or UPSERT if conflict keys are set) statement for table.
+func buildStatement(p *Plugin, driver string, table string) string {
+	placeholders := make([]string, len(p.columns))
+	updates := make([]string, 0)
+
+	for i, column := range p.columns {
+		placeholders[i] = placeholder(driver, i+1)
+
+		if core.IsValueInSlice(column, &p.OptionUpsert) {
+			continue
+		}
+
+		if driver == "mysql" {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(p.columns, ", "), strings.Join(placeholders, ", "))
+
+	if len(p.OptionUpsert) == 0 {
+		return statement
+	}
+
+	switch {
+	case driver == "mysql" && len(updates) > 0:
+		return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", statement, strings.Join(updates, ", "))
+	case driver == "mysql":
+		return fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s = %s", statement, p.OptionUpsert[0], p.OptionUpsert[0])
+	case len(updates) > 0:
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s",
+			statement, strings.Join(p.OptionUpsert, ", "), strings.Join(updates, ", "))
+	default:
+		return fmt.Sprintf("%s ON CONFLICT (%s) DO NOTHING", statement, strings.Join(p.OptionUpsert, ", "))
+	}
+}
+
+// compareCursor compares cursor values as numbers, times or text (whichever both values are).
+func compareCursor(a string, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if x, err := time.Parse(DEFAULT_TIME_FORMAT, a); err == nil {
+		if y, err := time.Parse(DEFAULT_TIME_FORMAT, b); err == nil {
+			return x.Compare(y)
+		}
+	}
+
+	return strings.Compare(a, b)
+}
+
+// convertValue represents column value as string, time values are kept comparable across drivers.
+func convertValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(t)
+	case string:
+		return t
+	case time.Time:
+		return t.Format(DEFAULT_TIME_FORMAT)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// createTable creates table (sqlite only) with text columns, conflict keys become primary key.
+func createTable(p *Plugin, db *sql.DB, table string) error {
+	columns := make([]string, 0)
+
+	for _, column := range p.columns {
+		columns = append(columns, fmt.Sprintf("%s TEXT", column))
+	}
+
+	if len(p.OptionUpsert) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(p.OptionUpsert, ", ")))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.OptionTimeout)*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(columns, ", ")))
+
+	return err
+}
+
+// cursorArg passes integer cursors as numbers, everything else as text.
+func cursorArg(cursor string) interface{} {
+	if v, err := strconv.ParseInt(cursor, 10, 64); err == nil {
+		return v
+	}
+
+	return cursor
+}
+
+// execBatch executes statements for data, batches are executed within a transaction.
+func execBatch(p *Plugin, db *sql.DB, statement string, data []*core.Datum) error {
+	var tx *sql.Tx
+	var err error
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.OptionTimeout)*time.Second)
+	defer cancel()
+
+	if p.OptionBatch > 0 {
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range data {
+		args := make([]interface{}, len(p.columns))
+		for i, column := range p.columns {
+			args[i] = fieldValue(item, p.OptionFields[column])
+		}
+
+		if tx != nil {
+			_, err = tx.ExecContext(ctx, statement, args...)
+		} else {
+			_, err = db.ExecContext(ctx, statement, args...)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if tx == nil {
+		return err
+	}
+
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// renderStatement renders custom statement for table, only table is available inside template (no datum fields).
+func renderStatement(statement string, table string) (string, error) {
+	var b bytes.Buffer
+
+	t, err := tmpl.New("statement").Funcs(core.TemplateFuncMap).
+		Funcs(tmpl.FuncMap{"table": func() string { return table }}).Parse(statement)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.Execute(&b, struct{}{}); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// fieldValue represents datum field as column value, slices are kept as JSON arrays.
+func fieldValue(item *core.Datum, field string) string {
+	rv, err := core.ReflectDatumField(item, field)
+	if err != nil {
+		return ""
+	}
+
+	switch v := rv.Interface().(type) {
+	case string:
+		return v
+	case []string:
+		if v == nil {
+			v = make([]string, 0)
+		}
+		j, _ := json.Marshal(v)
+		return string(j)
+	case time.Time:
+		return v.Format(DEFAULT_TIME_FORMAT)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// parseDSN detects driver by DSN scheme and injects credentials:
+// postgres://host:5432/db, mysql://host:3306/db, sqlite3:///path/to/db.
+func parseDSN(dsn string, username string, password string) (string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		if username != "" {
+			u.User = url.UserPassword(username, password)
+		}
+
+		return "postgres", u.String(), nil
+
+	case "mysql":
+		config, err := mysql.ParseDSN(fmt.Sprintf("tcp(%s)%s?%s", u.Host, u.Path, u.RawQuery))
+		if err != nil {
+			return "", "", err
+		}
+
+		if u.User != nil {
+			config.User = u.User.Username()
+			config.Passwd, _ = u.User.Password()
+		}
+
+		if username != "" {
+			config.User = username
+			config.Passwd = password
+		}
+
+		return "mysql", config.FormatDSN(), nil
+
+	case "sqlite", "sqlite3":
+		file := fmt.Sprintf("file:%s%s", u.Host, u.Path)
+
+		if u.RawQuery != "" {
+			file = fmt.Sprintf("%s?%s", file, u.RawQuery)
+		}
+
+		return "sqlite3", file, nil
+	}
+
+	return "", "", fmt.Errorf(ERROR_DSN_UNKNOWN.Error(), u.Scheme)
+}
+
+// placeholder returns driver specific query parameter.
+func placeholder(driver string, n int) string {
+	if driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+
+	return "?"
+}
+
+func queryRows(p *Plugin, db *sql.DB, query string, cursor string) ([]*core.Datum, string, error) {
+	temp := make([]*core.Datum, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.OptionTimeout)*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, cursorArg(cursor))
+	if err != nil {
+		return temp, cursor, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return temp, cursor, err
+	}
+
+	cursorIndex := -1
+	for i, column := range columns {
+		if column == p.OptionCursor {
+			cursorIndex = i
+		}
+	}
+
+	if cursorIndex < 0 {
+		return temp, cursor, fmt.Errorf(ERROR_CURSOR_NOT_FOUND.Error(), p.OptionCursor)
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return temp, cursor, err
+		}
+
+		row := make(map[string]string, len(columns))
+		rowValues := make([]string, len(columns))
+
+		for i, column := range columns {
+			rowValues[i] = convertValue(values[i])
+			row[column] = rowValues[i]
+		}
+
+		j, err := json.Marshal(row)
+		if err != nil {
+			return temp, cursor, err
+		}
+
+		// Rows might be unordered, the greatest cursor value is kept.
+		if compareCursor(rowValues[cursorIndex], cursor) > 0 {
+			cursor = rowValues[cursorIndex]
+		}
+
+		u, _ := uuid.NewRandom()
+
+		datum := core.Datum{
+			FLOW:        p.Flow.FlowName,
+			PLUGIN:      p.PluginName,
+			SOURCE:      query,
+			TIME:        time.Now().UTC(),
+			TIMEFORMAT:  time.Now().UTC().In(p.OptionTimeZone).Format(p.OptionTimeFormat),
+			TIMEFORMATA: time.Now().UTC().In(p.OptionTimeZoneA).Format(p.OptionTimeFormatA),
+			TIMEFORMATB: time.Now().UTC().In(p.OptionTimeZoneB).Format(p.OptionTimeFormatB),
+			TIMEFORMATC: time.Now().UTC().In(p.OptionTimeZoneC).Format(p.OptionTimeFormatC),
+			TIMEZONE:    p.OptionTimeZone,
+			TIMEZONEA:   p.OptionTimeZoneA,
+			TIMEZONEB:   p.OptionTimeZoneB,
+			TIMEZONEC:   p.OptionTimeZoneC,
+			UUID:        u,
+
+			SQL: core.Sql{
+				COLUMNS: columns,
+				CURSOR:  rowValues[cursorIndex],
+				ROW:     string(j),
+				VALUES:  rowValues,
+			},
+
+			WARNINGS: make([]string, 0),
+		}
+
+		// Map columns into datum fields.
+		for column, field := range p.OptionFields {
+			if v, ok := row[column]; ok {
+				rv, _ := core.ReflectDatumField(&datum, field)
+				rv.SetString(v)
+			}
+		}
+
+		temp = append(temp, &datum)
+	}
+
+	return temp, cursor, rows.Err()
+}
+
+type Plugin struct {
+	m sync.Mutex
+
+	Flow *core.Flow
+
+	Expire *core.ExpireCheck
+
+	LogFields log.Fields
+
+	PluginName string
+	PluginType string
+
+	columns    []string
+	cursors    map[string]string
+	pending    map[string]string
+	statements map[string]string
+
+	OptionBatch       int
+	OptionCreateTable bool
+	OptionCursor      string
+	OptionCursorStart string
+	OptionDSN         string
+	OptionFields      map[string]string
+	OptionForce       bool
+	OptionInput       []string
+	OptionOutput      []string
+	OptionPassword    string
+	OptionStatement   string
+	OptionTimeFormat  string
+	OptionTimeFormatA string
+	OptionTimeFormatB string
+	OptionTimeFormatC string
+	OptionTimeZone    *time.Location
+	OptionTimeZoneA   *time.Location
+	OptionTimeZoneB   *time.Location
+	OptionTimeZoneC   *time.Location
+	OptionTimeout     int
+	OptionUpsert      []string
+	OptionUsername    string
+}
+
+// Commit saves queries' cursors of the last run (cursors never expire).
+func (p *Plugin) Commit() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	db, err := core.OpenStateStore(p.Flow.FlowStateDir)
+	if err != nil {
+		return err
+	}
+
+	for query, cursor := range p.pending {
+		if err := db.Put(DEFAULT_STATE_PREFIX+query, []byte(cursor), 0); err != nil {
+			_ = db.Close()
+			return err
+		}
+
+		p.cursors[query] = cursor
+	}
+
+	p.pending = make(map[string]string, 0)
+
+	return db.Close()
+}
+
+func (p *Plugin) FlowLog(message interface{}) {
+	f := make(map[string]interface{}, len(p.LogFields))
+
+	for k, v := range p.LogFields {
+		f[k] = v
+	}
+
+	_, ok := message.(error)
+
+	if ok {
+		f["error"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Warn(core.LOG_FLOW_WARN)
+	} else {
+		f["data"] = fmt.Sprintf("%v", message)
+		log.WithFields(f).Debug(core.LOG_FLOW_STAT)
+	}
+}
+
+func (p *Plugin) GetInput() []string {
+	return p.OptionInput
+}
+
+func (p *Plugin) GetName() string {
+	return p.PluginName
+}
+
+func (p *Plugin) GetOutput() []string {
+	return p.OptionOutput
+}
+
+// LoadState reads sources' timestamps and queries' cursors (prefixed keys).
+func (p *Plugin) LoadState() (map[string]time.Time, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	data := make(map[string]time.Time, 0)
+	p.cursors = make(map[string]string, 0)
+	p.pending = make(map[string]string, 0)
+
+	db, err := core.OpenStateStore(p.Flow.FlowStateDir)
+	if err != nil {
+		return data, err
+	}
+	defer db.Close()
+
+	err = db.Iterate(func(key string, value []byte) error {
+		if query, ok := strings.CutPrefix(key, DEFAULT_STATE_PREFIX); ok {
+			p.cursors[query] = string(value)
+			return nil
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, string(value))
+		data[key] = timestamp
+
+		return err
+	})
+
+	return data, err
+}
+
+func (p *Plugin) Receive() ([]*core.Datum, error) {
+	failedSources := make([]string, 0)
+	temp := make([]*core.Datum, 0)
+	p.LogFields["run"] = p.Flow.GetRunID()
+
+	// Load flow sources' states.
+	flowStates, err := p.LoadState()
+	if err != nil {
+		return temp, err
+	}
+	core.LogInputPlugin(p.LogFields, "all", fmt.Sprintf("states loaded: %d", len(flowStates)))
+
+	// Connection is shared across queries.
+	driver, dsn, err := parseDSN(p.OptionDSN, p.OptionUsername, p.OptionPassword)
+	if err != nil {
+		return temp, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return temp, err
+	}
+	defer db.Close()
+
+	// Fetch data from sources.
+	for _, source := range p.OptionInput {
+		var sourceLastTime time.Time
+
+		// Skip sources with backoff/quarantine.
+		if !p.Flow.GetHealth().Allow(source) {
+			core.LogInputPlugin(p.LogFields, source,
+				fmt.Errorf(core.ERROR_SOURCE_BACKOFF.Error(), p.Flow.GetHealth().NextRetry(source)))
+			continue
+		}
+
+		// Check if we work with source first time.
+		if v, ok := flowStates[source]; ok {
+			sourceLastTime = v
+		} else {
+			sourceLastTime = time.Unix(0, 0)
+		}
+
+		// Start from the beginning if forced.
+		cursor, ok := p.cursors[source]
+		if !ok || p.OptionForce {
+			cursor = p.OptionCursorStart
+		}
+
+		data, cursor, err := queryRows(p, db, source, cursor)
+		if err != nil {
+			failedSources = append(failedSources, source)
+			p.Flow.GetHealth().Fail(source, err)
+			core.LogInputPlugin(p.LogFields, source, err)
+			continue
+		}
+		p.Flow.GetHealth().Success(source)
+
+		if len(data) > 0 {
+			sourceLastTime = time.Now().UTC()
+		}
+
+		temp = append(temp, data...)
+
+		// Cursor is saved after successful flow run (Commit).
+		p.m.Lock()
+		p.pending[source] = cursor
+		p.m.Unlock()
+
+		// always update source timestamp.
+		flowStates[source] = sourceLastTime
+
+		core.LogInputPlugin(p.LogFields, source, fmt.Sprintf("last update: %s, cursor: %s, new data: %d",
+			sourceLastTime, cursor, len(data)))
+	}
+
+	// Save updated flow states.
+	if err := p.SaveState(flowStates); err != nil {
+		return temp, err
+	}
+
+	// Check every source for expiration.
+	sourcesExpired := p.Expire.Check(p.Flow, p.LogFields, p.OptionInput, flowStates)
+
+	// Inform about sources failures.
+	if len(failedSources) > 0 {
+		return temp, core.ERROR_FLOW_SOURCE_FAIL
+	}
+
+	// Inform about expiration.
+	if sourcesExpired {
+		return temp, core.ERROR_FLOW_EXPIRE
+	}
+
+	return temp, nil
+}
+
+func (p *Plugin) Send(data []*core.Datum) error {
+	p.LogFields["run"] = p.Flow.GetRunID()
+	sendStatus := true
+
+	driver, dsn, err := parseDSN(p.OptionDSN, p.OptionUsername, p.OptionPassword)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Every datum is a dedicated batch without transactions.
+	size := p.OptionBatch
+	if size <= 0 {
+		size = 1
+	}
+
+	for _, table := range p.OptionOutput {
+		statement, ok := p.statements[table]
+		if !ok {
+			statement = buildStatement(p, driver, table)
+		}
+
+		if p.OptionCreateTable {
+			if err := createTable(p, db, table); err != nil {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, table, err)
+				continue
+			}
+		}
+
+		for i := 0; i < len(data); i += size {
+			batch := data[i:min(i+size, len(data))]
+
+			if err := execBatch(p, db, statement, batch); err != nil {
+				sendStatus = false
+				core.LogOutputPlugin(p.LogFields, table, err)
+			} else {
+				core.LogOutputPlugin(p.LogFields, table, fmt.Sprintf("rows: %d", len(batch)))
+			}
+		}
+	}
+
+	if !sendStatus {
+		return core.ERROR_SEND_FAIL
+	}
+
+	return nil
+}
+
+// Rollback discards queries' cursors of the last run, rows are received again next time.
+func (p *Plugin) Rollback() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.pending = make(map[string]string, 0)
+
+	return nil
+}
+
+// SaveState writes sources' timestamps.
+func (p *Plugin) SaveState(data map[string]time.Time) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return core.PluginSaveState(p.Flow.FlowStateDir, &data, 0)
+}
+
+func Init(pluginConfig *core.PluginConfig) (*Plugin, error) {
+	// -----------------------------------------------------------------------------------------------------------------
+
+	plugin := Plugin{
+		Flow: pluginConfig.Flow,
+		LogFields: log.Fields{
+			"hash":   pluginConfig.Flow.FlowHash,
+			"run":    pluginConfig.Flow.GetRunID(),
+			"flow":   pluginConfig.Flow.FlowName,
+			"file":   pluginConfig.Flow.FlowFile,
+			"plugin": PLUGIN_NAME,
+			"type":   pluginConfig.PluginType,
+		},
+		PluginName: PLUGIN_NAME,
+		PluginType: pluginConfig.PluginType,
+		cursors:    make(map[string]string, 0),
+		pending:    make(map[string]string, 0),
+		statements: make(map[string]string, 0),
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// All available parameters of the plugin:
+	// "-1" - not strictly required.
+	// "1" - strictly required.
+	// Will be set to "0" if parameter is set somehow (defaults, template, config).
+
+	availableParams := map[string]int{
+		"cred":     -1,
+		"template": -1,
+		"timeout":  -1,
+
+		"dsn":      1,
+		"fields":   -1,
+		"password": -1,
+		"username": -1,
+	}
+
+	switch pluginConfig.PluginType {
+	case "input":
+		availableParams["cursor"] = 1
+		availableParams["cursor_start"] = -1
+		availableParams["expire_action"] = -1
+		availableParams["expire_action_delay"] = -1
+		availableParams["expire_action_timeout"] = -1
+		availableParams["expire_interval"] = -1
+		availableParams["force"] = -1
+		availableParams["input"] = 1
+		availableParams["time_format"] = -1
+		availableParams["time_format_a"] = -1
+		availableParams["time_format_b"] = -1
+		availableParams["time_format_c"] = -1
+		availableParams["time_zone"] = -1
+		availableParams["time_zone_a"] = -1
+		availableParams["time_zone_b"] = -1
+		availableParams["time_zone_c"] = -1
+
+	case "output":
+		availableParams["batch"] = -1
+		availableParams["create_table"] = -1
+		availableParams["output"] = 1
+		availableParams["statement"] = -1
+		availableParams["upsert"] = -1
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Get plugin specific settings.
+
+	cred, _ := core.IsString((*pluginConfig.PluginParams)["cred"])
+	template, _ := core.IsString((*pluginConfig.PluginParams)["template"])
+
+	vault, err := core.GetVault(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.vault", cred)))
+	if err != nil {
+		return &plugin, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// dsn.
+	setDSN := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["dsn"] = 0
+			plugin.OptionDSN = core.GetCredValue(v, vault)
+		}
+	}
+	setDSN(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.dsn", cred)))
+	setDSN((*pluginConfig.PluginParams)["dsn"])
+
+	// password.
+	setPassword := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["password"] = 0
+			plugin.OptionPassword = core.GetCredValue(v, vault)
+		}
+	}
+	setPassword(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.password", cred)))
+	setPassword((*pluginConfig.PluginParams)["password"])
+
+	// username.
+	setUsername := func(p interface{}) {
+		if v, b := core.IsString(p); b {
+			availableParams["username"] = 0
+			plugin.OptionUsername = core.GetCredValue(v, vault)
+		}
+	}
+	setUsername(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.username", cred)))
+	setUsername((*pluginConfig.PluginParams)["username"])
+	core.ShowPluginParam(plugin.LogFields, "username", plugin.OptionUsername)
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	// fields.
+	templateFields, _ := core.IsMapWithStringAsKey(pluginConfig.AppConfig.GetStringMap(fmt.Sprintf("%s.fields", template)))
+	configFields, _ := core.IsMapWithStringAsKey((*pluginConfig.PluginParams)["fields"])
+	mergedFields := make(map[string]string, 0)
+
+	for k, v := range templateFields {
+		if s, b := core.IsString(v); b {
+			mergedFields[k] = s
+		}
+	}
+
+	for k, v := range configFields {
+		if s, b := core.IsString(v); b {
+			mergedFields[k] = s
+		}
+	}
+
+	plugin.OptionFields = mergedFields
+
+	core.ShowPluginParam(plugin.LogFields, "fields", plugin.OptionFields)
+
+	switch pluginConfig.PluginType {
+	case "input":
+		// cursor.
+		setCursor := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["cursor"] = 0
+				plugin.OptionCursor = v
+			}
+		}
+		setCursor(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.cursor", template)))
+		setCursor((*pluginConfig.PluginParams)["cursor"])
+		core.ShowPluginParam(plugin.LogFields, "cursor", plugin.OptionCursor)
+
+		// cursor_start.
+		setCursorStart := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["cursor_start"] = 0
+				plugin.OptionCursorStart = v
+			}
+		}
+		setCursorStart(DEFAULT_CURSOR_START)
+		setCursorStart(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.cursor_start", template)))
+		setCursorStart((*pluginConfig.PluginParams)["cursor_start"])
+		core.ShowPluginParam(plugin.LogFields, "cursor_start", plugin.OptionCursorStart)
+
+		// expire_action, expire_action_delay, expire_action_timeout, expire_interval.
+		plugin.Expire = core.NewExpireCheck(pluginConfig, template, availableParams, plugin.LogFields)
+
+		// force.
+		setForce := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["force"] = 0
+				plugin.OptionForce = v
+			}
+		}
+		setForce(core.DEFAULT_FORCE_INPUT)
+		setForce(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.force", template)))
+		setForce((*pluginConfig.PluginParams)["force"])
+		core.ShowPluginParam(plugin.LogFields, "force", plugin.OptionForce)
+
+		// input.
+		setInput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["input"] = 0
+				plugin.OptionInput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setInput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.input", template)))
+		setInput((*pluginConfig.PluginParams)["input"])
+		core.ShowPluginParam(plugin.LogFields, "input", plugin.OptionInput)
+
+		// time_format.
+		setTimeFormat := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format"] = 0
+				plugin.OptionTimeFormat = v
+			}
+		}
+		setTimeFormat(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormat(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format", template)))
+		setTimeFormat((*pluginConfig.PluginParams)["time_format"])
+		core.ShowPluginParam(plugin.LogFields, "time_format", plugin.OptionTimeFormat)
+
+		// time_format_a.
+		setTimeFormatA := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_a"] = 0
+				plugin.OptionTimeFormatA = v
+			}
+		}
+		setTimeFormatA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_a", template)))
+		setTimeFormatA((*pluginConfig.PluginParams)["time_format_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_a", plugin.OptionTimeFormatA)
+
+		// time_format_b.
+		setTimeFormatB := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_b"] = 0
+				plugin.OptionTimeFormatB = v
+			}
+		}
+		setTimeFormatB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_b", template)))
+		setTimeFormatB((*pluginConfig.PluginParams)["time_format_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_b", plugin.OptionTimeFormatB)
+
+		// time_format_c.
+		setTimeFormatC := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["time_format_c"] = 0
+				plugin.OptionTimeFormatC = v
+			}
+		}
+		setTimeFormatC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_FORMAT))
+		setTimeFormatC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_format_c", template)))
+		setTimeFormatC((*pluginConfig.PluginParams)["time_format_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_format_c", plugin.OptionTimeFormatC)
+
+		// time_zone.
+		setTimeZone := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone"] = 0
+				plugin.OptionTimeZone = v
+			}
+		}
+		setTimeZone(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZone(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone", template)))
+		setTimeZone((*pluginConfig.PluginParams)["time_zone"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone", plugin.OptionTimeZone)
+
+		// time_zone_a.
+		setTimeZoneA := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_a"] = 0
+				plugin.OptionTimeZoneA = v
+			}
+		}
+		setTimeZoneA(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneA(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_a", template)))
+		setTimeZoneA((*pluginConfig.PluginParams)["time_zone_a"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_a", plugin.OptionTimeZoneA)
+
+		// time_zone_b.
+		setTimeZoneB := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_b"] = 0
+				plugin.OptionTimeZoneB = v
+			}
+		}
+		setTimeZoneB(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneB(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_b", template)))
+		setTimeZoneB((*pluginConfig.PluginParams)["time_zone_b"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_b", plugin.OptionTimeZoneB)
+
+		// time_zone_c.
+		setTimeZoneC := func(p interface{}) {
+			if v, b := core.IsTimeZone(p); b {
+				availableParams["time_zone_c"] = 0
+				plugin.OptionTimeZoneC = v
+			}
+		}
+		setTimeZoneC(pluginConfig.AppConfig.GetString(core.VIPER_DEFAULT_TIME_ZONE))
+		setTimeZoneC(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.time_zone_c", template)))
+		setTimeZoneC((*pluginConfig.PluginParams)["time_zone_c"])
+		core.ShowPluginParam(plugin.LogFields, "time_zone_c", plugin.OptionTimeZoneC)
+
+	case "output":
+		// batch.
+		setBatch := func(p interface{}) {
+			if v, b := core.IsInt(p); b {
+				availableParams["batch"] = 0
+				plugin.OptionBatch = v
+			}
+		}
+		setBatch(DEFAULT_BATCH)
+		setBatch(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.batch", template)))
+		setBatch((*pluginConfig.PluginParams)["batch"])
+		core.ShowPluginParam(plugin.LogFields, "batch", plugin.OptionBatch)
+
+		// create_table.
+		setCreateTable := func(p interface{}) {
+			if v, b := core.IsBool(p); b {
+				availableParams["create_table"] = 0
+				plugin.OptionCreateTable = v
+			}
+		}
+		setCreateTable(DEFAULT_CREATE_TABLE)
+		setCreateTable(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.create_table", template)))
+		setCreateTable((*pluginConfig.PluginParams)["create_table"])
+		core.ShowPluginParam(plugin.LogFields, "create_table", plugin.OptionCreateTable)
+
+		// output.
+		setOutput := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["output"] = 0
+				plugin.OptionOutput = core.ExtractConfigVariableIntoArray(pluginConfig.AppConfig, v)
+			}
+		}
+		setOutput(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.output", template)))
+		setOutput((*pluginConfig.PluginParams)["output"])
+		core.ShowPluginParam(plugin.LogFields, "output", plugin.OptionOutput)
+
+		// statement.
+		setStatement := func(p interface{}) {
+			if v, b := core.IsString(p); b {
+				availableParams["statement"] = 0
+				plugin.OptionStatement = v
+			}
+		}
+		setStatement(pluginConfig.AppConfig.GetString(fmt.Sprintf("%s.statement", template)))
+		setStatement((*pluginConfig.PluginParams)["statement"])
+		core.ShowPluginParam(plugin.LogFields, "statement", plugin.OptionStatement)
+
+		// Statement is rendered once per table, values are passed as parameters only.
+		if plugin.OptionStatement != "" {
+			for _, table := range plugin.OptionOutput {
+				statement, err := renderStatement(plugin.OptionStatement, table)
+				if err != nil {
+					return &Plugin{}, fmt.Errorf(ERROR_TEMPLATE_INVALID.Error(), err)
+				}
+				plugin.statements[table] = statement
+			}
+		}
+
+		// upsert.
+		setUpsert := func(p interface{}) {
+			if v, b := core.IsSliceOfString(p); b {
+				availableParams["upsert"] = 0
+				plugin.OptionUpsert = v
+			}
+		}
+		setUpsert(pluginConfig.AppConfig.GetStringSlice(fmt.Sprintf("%s.upsert", template)))
+		setUpsert((*pluginConfig.PluginParams)["upsert"])
+		core.ShowPluginParam(plugin.LogFields, "upsert", plugin.OptionUpsert)
+	}
+
+	// timeout.
+	setTimeout := func(p interface{}) {
+		if v, b := core.IsInt(p); b {
+			availableParams["timeout"] = 0
+			plugin.OptionTimeout = v
+		}
+	}
+	setTimeout(pluginConfig.AppConfig.GetInt(core.VIPER_DEFAULT_PLUGIN_TIMEOUT))
+	setTimeout(pluginConfig.AppConfig.GetInt(fmt.Sprintf("%s.timeout", template)))
+	setTimeout((*pluginConfig.PluginParams)["timeout"])
+	core.ShowPluginParam(plugin.LogFields, "timeout", plugin.OptionTimeout)
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Check required and unknown parameters.
+
+	if err := core.CheckPluginParams(&availableParams, pluginConfig.PluginParams); err != nil {
+		return &Plugin{}, err
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+	// Additional checks.
+
+	if _, _, err := parseDSN(plugin.OptionDSN, plugin.OptionUsername, plugin.OptionPassword); err != nil {
+		return &Plugin{}, err
+	}
+
+	fields := make([]string, 0)
+	for column, field := range plugin.OptionFields {
+		plugin.columns = append(plugin.columns, column)
+		fields = append(fields, field)
+	}
+	sort.Strings(plugin.columns)
+	sort.Strings(fields)
+
+	switch pluginConfig.PluginType {
+	case "input":
+		if err := core.IsDatumFieldsString(&fields); err != nil {
+			return &Plugin{}, err
+		}
+
+	case "output":
+		if len(fields) == 0 {
+			return &Plugin{}, ERROR_FIELDS_EMPTY
+		}
+
+		for _, field := range fields {
+			if _, err := core.ReflectDatumField(&core.Datum{}, field); err != nil {
+				return &Plugin{}, err
+			}
+		}
+
+		if driver, _, _ := parseDSN(plugin.OptionDSN, "", ""); plugin.OptionCreateTable && driver != "sqlite3" {
+			return &Plugin{}, fmt.Errorf(ERROR_CREATE_TABLE.Error(), driver)
+		}
+	}
+
+	// -----------------------------------------------------------------------------------------------------------------
+
+	return &plugin, nil
+}
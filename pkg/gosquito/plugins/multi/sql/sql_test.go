@@ -0,0 +1,276 @@
+package sqlMulti
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"github.com/spf13/viper"
+)
+
+func newPlugin(t *testing.T, pluginType string, stateDir string, params map[string]interface{}) *Plugin {
+	appConfig := viper.New()
+	appConfig.Set(core.VIPER_DEFAULT_EXPIRE_INTERVAL, "1d")
+	appConfig.Set(core.VIPER_DEFAULT_PLUGIN_TIMEOUT, 10)
+	appConfig.Set(core.VIPER_DEFAULT_TIME_ZONE, "UTC")
+	core.SetStateBackend(appConfig)
+
+	p, err := Init(&core.PluginConfig{
+		AppConfig:    appConfig,
+		Flow:         &core.Flow{FlowName: "sql", FlowStateDir: stateDir},
+		PluginParams: &params,
+		PluginType:   pluginType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestBuildStatement(t *testing.T) {
+	tests := []struct {
+		driver string
+		upsert []string
+		want   string
+	}{
+		{"sqlite3", nil, "INSERT INTO news (link, title) VALUES (?, ?)"},
+		{"postgres", nil, "INSERT INTO news (link, title) VALUES ($1, $2)"},
+		{"sqlite3", []string{"link"},
+			"INSERT INTO news (link, title) VALUES (?, ?) ON CONFLICT (link) DO UPDATE SET title = excluded.title"},
+		{"postgres", []string{"link"},
+			"INSERT INTO news (link, title) VALUES ($1, $2) ON CONFLICT (link) DO UPDATE SET title = excluded.title"},
+		{"postgres", []string{"link", "title"},
+			"INSERT INTO news (link, title) VALUES ($1, $2) ON CONFLICT (link, title) DO NOTHING"},
+		{"mysql", []string{"link"},
+			"INSERT INTO news (link, title) VALUES (?, ?) ON DUPLICATE KEY UPDATE title = VALUES(title)"},
+		{"mysql", []string{"link", "title"},
+			"INSERT INTO news (link, title) VALUES (?, ?) ON DUPLICATE KEY UPDATE link = link"},
+	}
+
+	for _, test := range tests {
+		p := &Plugin{columns: []string{"link", "title"}, OptionUpsert: test.upsert}
+
+		if got := buildStatement(p, test.driver, "news"); got != test.want {
+			t.Errorf(`buildStatement(%q, %v) = %q, want %q`, test.driver, test.upsert, got, test.want)
+		}
+	}
+}
+
+func TestCompareCursor(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"9", "10", -1},
+		{"10", "9", 1},
+		{"1.5", "1.50", 0},
+		{"2024-01-02 00:00:00+00:00", "2024-01-01 23:00:00-02:00", -1},
+		{"2024-01-01 00:00:00+00:00", "2024-01-01 03:00:00+03:00", 0},
+		{"b", "a", 1},
+		{"10", "a", -1},
+	}
+
+	for _, test := range tests {
+		if got := compareCursor(test.a, test.b); got != test.want {
+			t.Errorf(`compareCursor(%q, %q) = %d, want %d`, test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCursorArg(t *testing.T) {
+	tests := []struct {
+		cursor string
+		want   interface{}
+	}{
+		{"0", int64(0)},
+		{"42", int64(42)},
+		{"1.5", "1.5"},
+		{"2024-01-01 00:00:00+00:00", "2024-01-01 00:00:00+00:00"},
+	}
+
+	for _, test := range tests {
+		if got := cursorArg(test.cursor); got != test.want {
+			t.Errorf(`cursorArg(%q) = %#v, want %#v`, test.cursor, got, test.want)
+		}
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		username string
+		password string
+		driver   string
+		want     string
+		err      bool
+	}{
+		{"postgres://db:5432/news?sslmode=disable", "", "",
+			"postgres", "postgres://db:5432/news?sslmode=disable", false},
+		{"postgresql://old:old@db:5432/news", "user", "p@ss",
+			"postgres", "postgresql://user:p%40ss@db:5432/news", false},
+		{"mysql://db:3306/news", "user", "p@ss",
+			"mysql", "user:p@ss@tcp(db:3306)/news", false},
+		{"mysql://old:secret@db:3306/news?parseTime=true", "", "",
+			"mysql", "old:secret@tcp(db:3306)/news?parseTime=true", false},
+		{"sqlite3:///data/news.db", "user", "pass",
+			"sqlite3", "file:/data/news.db", false},
+		{"sqlite:///data/news.db?mode=ro", "", "",
+			"sqlite3", "file:/data/news.db?mode=ro", false},
+		{"oracle://db/news", "", "", "", "", true},
+	}
+
+	for _, test := range tests {
+		driver, dsn, err := parseDSN(test.dsn, test.username, test.password)
+		if driver != test.driver || dsn != test.want || (err != nil) != test.err {
+			t.Errorf(`parseDSN(%q) = %q, %q, %v`, test.dsn, driver, dsn, err)
+		}
+	}
+}
+
+func TestExecBatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "news.db")
+	p := newPlugin(t, "output", t.TempDir(), map[string]interface{}{
+		"dsn":          "sqlite3://" + file,
+		"output":       []interface{}{"news"},
+		"fields":       map[string]interface{}{"link": "rss.link", "title": "rss.title"},
+		"create_table": true,
+		"upsert":       []interface{}{"link"},
+		"batch":        10,
+	})
+
+	db, err := sql.Open("sqlite3", "file:"+file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := createTable(p, db, "news"); err != nil {
+		t.Fatal(err)
+	}
+
+	statement := buildStatement(p, "sqlite3", "news")
+
+	data := []*core.Datum{
+		{RSS: core.Rss{LINK: "a", TITLE: "first"}},
+		{RSS: core.Rss{LINK: "b", TITLE: "second"}},
+		{RSS: core.Rss{LINK: "a", TITLE: "updated"}},
+	}
+
+	if err := execBatch(p, db, statement, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed batch is rolled back as a whole.
+	if err := execBatch(p, db, "INSERT INTO news (link, title) VALUES (?, ?)",
+		[]*core.Datum{{RSS: core.Rss{LINK: "c"}}, {RSS: core.Rss{LINK: "a"}}}); err == nil {
+		t.Errorf(`execBatch() with duplicate key = %v`, err)
+	}
+
+	rows, err := db.Query("SELECT link, title FROM news ORDER BY link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := make([]string, 0)
+	for rows.Next() {
+		var link, title string
+		if err := rows.Scan(&link, &title); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, link+":"+title)
+	}
+
+	if want := []string{"a:updated", "b:second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf(`execBatch() rows = %v, want %v`, got, want)
+	}
+}
+
+func TestReceiveCursor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "news.db")
+	stateDir := t.TempDir()
+
+	db, err := sql.Open("sqlite3", "file:"+file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE news (id INTEGER, title TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	output := newPlugin(t, "output", t.TempDir(), map[string]interface{}{
+		"dsn":    "sqlite3://" + file,
+		"output": []interface{}{"news"},
+		"fields": map[string]interface{}{"id": "data.text0", "title": "rss.title"},
+	})
+
+	send := func(items ...[2]string) {
+		data := make([]*core.Datum, 0)
+		for _, item := range items {
+			data = append(data, &core.Datum{DATA: core.Data{TEXT0: item[0]}, RSS: core.Rss{TITLE: item[1]}})
+		}
+
+		if err := output.Send(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := func() *Plugin {
+		return newPlugin(t, "input", stateDir, map[string]interface{}{
+			"dsn":    "sqlite3://" + file,
+			"cursor": "id",
+			"input":  []interface{}{"SELECT id, title FROM news WHERE id > ? ORDER BY id"},
+			"fields": map[string]interface{}{"title": "data.text0"},
+		})
+	}
+
+	receive := func(p *Plugin) []string {
+		data, err := p.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		titles := make([]string, 0)
+		for _, item := range data {
+			titles = append(titles, item.DATA.TEXT0)
+		}
+
+		return titles
+	}
+
+	send([2]string{"1", "first"}, [2]string{"2", "second"})
+
+	p := input()
+
+	if got := receive(p); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf(`Receive() = %v`, got)
+	}
+
+	// Rows of failed flow run are received again.
+	if err := p.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := receive(p); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf(`Receive() after Rollback() = %v`, got)
+	}
+
+	if err := p.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	send([2]string{"3", "third"})
+
+	// Cursor is kept across plugin instances.
+	p = input()
+
+	if got := receive(p); !reflect.DeepEqual(got, []string{"third"}) {
+		t.Errorf(`Receive() after Commit() = %v`, got)
+	}
+}
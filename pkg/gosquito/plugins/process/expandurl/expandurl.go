@@ -1,6 +1,7 @@
 package expandurlProcess
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/livelace/gosquito/pkg/gosquito/core"
@@ -59,6 +60,11 @@ func getRedirectFromServer(p *Plugin, url string) (string, bool) {
 		Timeout:       time.Duration(p.OptionTimeout) * time.Second,
 	}
 
+	// Respect rate limits for remote host.
+	if err := p.Flow.RateLimitWait(context.Background(), url); err != nil {
+		return url, false
+	}
+
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("User-Agent", p.OptionUserAgent)
 	resp, _ := client.Do(req)
@@ -21,7 +21,7 @@ var (
 	ERROR_FETCH_ERROR = errors.New("fetch error: %s %s %v")
 )
 
-func fetchData(url string, dst string, timeout int) error {
+func fetchData(flow *core.Flow, url string, dst string, timeout int) error {
 	// context.
 	c := make(chan error, 0)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -32,6 +32,12 @@ func fetchData(url string, dst string, timeout int) error {
 
 	// background.
 	go func() {
+		// Respect rate limits for remote host.
+		if err := flow.RateLimitWait(ctx, url); err != nil {
+			c <- err
+			return
+		}
+
 		err := client.Get()
 		c <- err
 	}()
@@ -113,7 +119,7 @@ func (p *Plugin) Process(data []*core.Datum) ([]*core.Datum, error) {
 			switch ri.Kind() {
 			case reflect.String:
 				savePath := filepath.Join(outputDir, item.UUID.String(), path.Base(ri.String()))
-				err := fetchData(ri.String(), savePath, p.OptionTimeout)
+				err := fetchData(p.Flow, ri.String(), savePath, p.OptionTimeout)
 
 				if err == nil {
 					ro.SetString(savePath)
@@ -126,7 +132,7 @@ func (p *Plugin) Process(data []*core.Datum) ([]*core.Datum, error) {
 			case reflect.Slice:
 				for i := 0; i < ri.Len(); i++ {
 					savePath := filepath.Join(outputDir, item.UUID.String(), path.Base(ri.Index(i).String()))
-					err := fetchData(ri.Index(i).String(), savePath, p.OptionTimeout)
+					err := fetchData(p.Flow, ri.Index(i).String(), savePath, p.OptionTimeout)
 
 					if err == nil {
 						ro.Set(reflect.Append(ro, reflect.ValueOf(savePath)))
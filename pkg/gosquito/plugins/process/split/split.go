@@ -108,6 +108,8 @@ func (p *Plugin) Process(data []*core.Datum) ([]*core.Datum, error) {
 				TIMEFORMATC: item.TIMEFORMATC,
 				UUID:        u,
 
+				VARS: item.VARS,
+
 				WARNINGS: item.WARNINGS,
 			}
 
@@ -160,6 +160,14 @@ func processBatch(p *Plugin, batchTask *BatchTask) {
 		core.LogProcessPlugin(p.LogFields, fmt.Errorf("%s", message))
 	}
 
+	// Respect rate limits for remote hosts, pages are fetched by server at once.
+	for _, u := range batchTask.Input {
+		if err := p.Flow.RateLimitWait(context.Background(), u); err != nil {
+			logAndSetFail(fmt.Sprintf("batch: %d, rate limit error: %s", batchTask.ID, err))
+			return
+		}
+	}
+
 	// Connect to server.
 	conn, err := grpc.Dial(batchTask.Server, grpc.WithInsecure(), grpc.WithBlock())
 	if conn == nil || err != nil {
@@ -0,0 +1,161 @@
+package gosquito
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	log "github.com/livelace/logrus"
+	"net/http"
+	"os"
+	"time"
+)
+
+func parseReplayTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	// Absolute time.
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	// Relative time (interval ago).
+	if v, b := core.IsInterval(s); b {
+		return time.Now().Add(-time.Duration(v) * time.Millisecond), nil
+	}
+
+	return time.Time{}, fmt.Errorf(core.ERROR_REPLAY_TIME.Error(), s)
+}
+
+// replayFlow passes archived input data of flow runs within time range through "process" and "output" plugins.
+func replayFlow(flow *core.Flow, from time.Time, to time.Time) (int, error) {
+	data, err := flow.GetArchive().Read(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data) == 0 {
+		return 0, core.ERROR_NO_NEW_DATA
+	}
+
+	if !flow.Lock() {
+		return 0, fmt.Errorf(core.ERROR_FLOW_LOCKED.Error(), flow.FlowName)
+	}
+	defer flow.Unlock()
+
+	log.WithFields(log.Fields{
+		"hash": flow.FlowHash,
+		"run":  flow.GetRunID(),
+		"flow": flow.FlowName,
+		"from": from,
+		"to":   to,
+		"data": len(data),
+	}).Info(core.LOG_FLOW_REPLAY)
+
+	for _, item := range data {
+		item.FLOW = flow.FlowName
+		item.VARS = flow.FlowVars
+	}
+
+	return len(data), sendFlow(flow, data, flow.GetLease())
+}
+
+// replayHandler replays flow inside running instance (admin_token is required):
+// curl -X POST -H "Authorization: Bearer <admin_token>" "http://127.0.0.1:8080/replay?flow=flow1&from=2024-01-01T00:00:00Z&to=1h"
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("flow")
+
+	flow := core.GetFlowByName(name)
+	if flow == nil {
+		http.Error(w, fmt.Sprintf(core.ERROR_FLOW_NOT_FOUND.Error(), name), http.StatusNotFound)
+		return
+	}
+
+	from, err := parseReplayTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseReplayTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count, err := replayFlow(flow, from, to)
+	if err != nil && err != core.ERROR_NO_NEW_DATA {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"flow": name, "data": count})
+}
+
+// RunReplay replays flow without running other flows:
+// gosquito replay -flow flow1 -from 1d [-to 2024-01-01T00:00:00Z]
+func RunReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	flowName := fs.String("flow", "", "flow name")
+	fromArg := fs.String("from", "", "start of time range (RFC3339 or interval ago)")
+	toArg := fs.String("to", "", "end of time range (RFC3339 or interval ago)")
+	_ = fs.Parse(args)
+
+	from, fromErr := parseReplayTime(*fromArg)
+	to, toErr := parseReplayTime(*toArg)
+
+	if *flowName == "" || fromErr != nil || toErr != nil {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Get app config.
+	appConfig := core.GetAppConfig()
+
+	// Set log level.
+	ll, _ := log.ParseLevel(appConfig.GetString(core.VIPER_DEFAULT_LOG_LEVEL))
+	log.SetLevel(ll)
+
+	// Load only replayed flow.
+	// Input plugin isn't initialized (listeners, consumers etc. mustn't receive live data).
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_DISABLE, []string{})
+	appConfig.Set(core.VIPER_DEFAULT_FLOW_ENABLE, []string{*flowName})
+
+	var flow *core.Flow
+	for _, f := range loadFlow(appConfig, flowPlugins{Output: true}) {
+		if f.FlowName == *flowName {
+			flow = f
+		}
+	}
+
+	if flow == nil {
+		log.WithFields(log.Fields{
+			"flow":  *flowName,
+			"error": fmt.Errorf(core.ERROR_FLOW_NOT_FOUND.Error(), *flowName),
+		}).Error(core.LOG_FLOW_REPLAY)
+		os.Exit(1)
+	}
+
+	count, err := replayFlow(flow, from, to)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"flow":  *flowName,
+			"data":  count,
+			"error": err,
+		}).Error(core.LOG_FLOW_REPLAY)
+		os.Exit(1)
+	}
+
+	log.WithFields(log.Fields{
+		"flow": *flowName,
+		"data": count,
+	}).Info(core.LOG_FLOW_REPLAY)
+}
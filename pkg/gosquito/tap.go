@@ -0,0 +1,105 @@
+package gosquito
+
+import (
+	"fmt"
+	"github.com/livelace/gosquito/pkg/gosquito/core"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tapHandler streams datums of running flow as server-sent events (debugging, admin_token is required):
+// curl -N -H "Authorization: Bearer <admin_token>" "http://127.0.0.1:8080/tap?flow=flow1&stage=process&id=0&sample=0.1&fields=rss.title,data.text0"
+func tapHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("flow")
+
+	if core.GetFlowByName(name) == nil {
+		http.Error(w, fmt.Sprintf(core.ERROR_FLOW_NOT_FOUND.Error(), name), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	subscriber := &core.TapSubscriber{
+		Fields:   make([]string, 0),
+		Flow:     name,
+		PluginID: -1,
+		Sample:   1,
+		Stage:    query.Get("stage"),
+	}
+
+	// stage.
+	if subscriber.Stage != "" && subscriber.Stage != "process" && subscriber.Stage != "output" {
+		http.Error(w, fmt.Sprintf(core.ERROR_TAP_PARAM.Error(), "stage"), http.StatusBadRequest)
+		return
+	}
+
+	// id.
+	if v := query.Get("id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 0 {
+			http.Error(w, fmt.Sprintf(core.ERROR_TAP_PARAM.Error(), "id"), http.StatusBadRequest)
+			return
+		}
+		subscriber.PluginID = id
+	}
+
+	// sample.
+	if v := query.Get("sample"); v != "" {
+		sample, err := strconv.ParseFloat(v, 64)
+		if err != nil || sample <= 0 || sample > 1 {
+			http.Error(w, fmt.Sprintf(core.ERROR_TAP_PARAM.Error(), "sample"), http.StatusBadRequest)
+			return
+		}
+		subscriber.Sample = sample
+	}
+
+	// fields.
+	if v := query.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			if _, err := core.ReflectDatumField(&core.Datum{}, field); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			subscriber.Fields = append(subscriber.Fields, field)
+		}
+	}
+
+	core.TapSubscribe(subscriber, core.DEFAULT_TAP_BUFFER)
+	defer core.TapUnsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive, _ := core.IsInterval(core.DEFAULT_TAP_KEEPALIVE)
+	ticker := time.NewTicker(time.Duration(keepalive) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event := <-subscriber.C:
+			if _, err := fmt.Fprintf(w, "event: datum\ndata: %s\n\n", event); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-ticker.C:
+			if _, err := fmt.Fprintf(w, ": dropped %d\n\n", subscriber.GetDropped()); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}